@@ -3,19 +3,42 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"net/mail"
+	"net/url"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/go-chi/cors"
 	"go.uber.org/zap"
 
+	"github.com/codyonesock/rest_weather/internal/admin"
+	"github.com/codyonesock/rest_weather/internal/alerts"
+	"github.com/codyonesock/rest_weather/internal/auth"
+	"github.com/codyonesock/rest_weather/internal/cache"
 	"github.com/codyonesock/rest_weather/internal/config"
+	"github.com/codyonesock/rest_weather/internal/email"
+	"github.com/codyonesock/rest_weather/internal/graphqlapi"
+	"github.com/codyonesock/rest_weather/internal/grpcapi"
+	"github.com/codyonesock/rest_weather/internal/health"
 	"github.com/codyonesock/rest_weather/internal/logger"
+	"github.com/codyonesock/rest_weather/internal/metrics"
+	"github.com/codyonesock/rest_weather/internal/prewarm"
+	"github.com/codyonesock/rest_weather/internal/ratelimit"
+	"github.com/codyonesock/rest_weather/internal/reload"
 	"github.com/codyonesock/rest_weather/internal/routes"
 	"github.com/codyonesock/rest_weather/internal/storage"
+	"github.com/codyonesock/rest_weather/internal/stream"
+	"github.com/codyonesock/rest_weather/internal/tracing"
 	"github.com/codyonesock/rest_weather/internal/weather"
+	"github.com/codyonesock/rest_weather/internal/webhooks"
+	"github.com/codyonesock/rest_weather/internal/ws"
 )
 
 const (
@@ -25,8 +48,17 @@ const (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
+
+	os.Exit(run())
+}
+
+// run starts the server and blocks until it has shut down, returning the exit code.
+func run() int {
 	cfg := loadConfig()
-	logger := initializeLogger(cfg)
+	logger, level := initializeLogger(cfg)
 
 	defer func() {
 		if err := logger.Sync(); err != nil {
@@ -34,8 +66,147 @@ func main() {
 		}
 	}()
 
-	weatherService := initializeServices(cfg, logger)
-	startServer(cfg, logger, weatherService)
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(signalCtx)
+	defer cancel()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.TracingExporter, cfg.TracingEndpoint)
+	if err != nil {
+		logger.Error("Failed to set up tracing", zap.Error(err))
+		return 1
+	}
+
+	metricsService := metrics.NewMetricsService()
+	storageService, weatherService := initializeServices(cfg, logger, metricsService)
+
+	// workers tracks background goroutines that must finish before exiting.
+	var workers sync.WaitGroup
+
+	reloadService := reload.NewReloadService(logger, level, weatherService, config.LoadConfig, cfg)
+	startWorker(&workers, func() { reloadService.Run(ctx) })
+
+	authService := auth.NewAuthService(logger, storageService, cfg.AdminToken, cfg.AuthRequired)
+	if cfg.JWKSURL != "" {
+		authService.JWT = auth.NewJWTVerifier(cfg.JWKSURL, cfg.JWTIssuer, cfg.JWTAudience, cfg.JWKSRefreshInterval)
+	}
+
+	if cfg.PrewarmInterval > 0 {
+		prewarmService := prewarm.NewPrewarmService(logger, weatherService, storageService,
+			cfg.PrewarmInterval, cfg.PrewarmJitter, cfg.PrewarmConcurrency)
+		startWorker(&workers, func() { prewarmService.Run(ctx) })
+	}
+
+	streamService := stream.NewStreamService(logger, weatherService, cfg.StreamRefreshInterval, cfg.StreamHeartbeatInterval)
+	startWorker(&workers, func() { streamService.Run(ctx) })
+
+	webhooksService := webhooks.NewWebhooksService(logger, weatherService, streamService, storageService,
+		cfg.WebhookTimeout, cfg.WebhookMaxAttempts, cfg.WebhookBackoff)
+	webhooksService.AllowPrivate = cfg.WebhookAllowPrivate
+	startWorker(&workers, func() { webhooksService.Run(ctx) })
+
+	alertsService := alerts.NewAlertsService(logger, weatherService, storageService, cfg.AlertInterval)
+	alertsService.Notifiers = append(alertsService.Notifiers, webhooksService)
+
+	emailService := initializeEmail(cfg, logger, weatherService, storageService)
+	if cfg.SMTPHost != "" {
+		alertsService.Notifiers = append(alertsService.Notifiers, emailService)
+		startWorker(&workers, func() { emailService.Run(ctx) })
+	}
+
+	if cfg.AlertInterval > 0 {
+		startWorker(&workers, func() { alertsService.Run(ctx) })
+	}
+
+	wsService := ws.NewWSService(logger, weatherService, streamService, cfg.WSMaxSubscriptions, cfg.WSPingInterval)
+	wsService.OriginPatterns = originHosts(cfg.CORSAllowedOrigins)
+
+	// grpcErr holds the gRPC server's failure, which also stops the HTTP server.
+	grpcErr := make(chan error, 1)
+
+	// gRPC calls share the REST budgets.
+	readLimiter := ratelimit.NewLimiter(cfg.ReadRateLimit, cfg.ReadRateBurst)
+	writeLimiter := ratelimit.NewLimiter(cfg.WriteRateLimit, cfg.WriteRateBurst)
+
+	if cfg.GRPCPort != "" {
+		grpcService := grpcapi.NewGRPCService(logger, weatherService, streamService, authService)
+		grpcService.ReadLimiter, grpcService.WriteLimiter = readLimiter, writeLimiter
+		startWorker(&workers, func() {
+			if err := grpcService.Serve(ctx, cfg.GRPCPort, cfg.ShutdownTimeout); err != nil {
+				grpcErr <- err
+
+				cancel()
+			}
+		})
+	}
+
+	healthService := initializeHealth(cfg, logger, storageService, weatherService)
+	serverErr := startServer(ctx, cfg, logger, routes.Services{
+		Weather:  weatherService,
+		Admin:    admin.NewAdminService(logger, level),
+		Metrics:  metricsService,
+		Health:   healthService,
+		Auth:     authService,
+		Stream:   streamService,
+		WS:       wsService,
+		GraphQL:  graphqlapi.NewGraphQLService(logger, weatherService),
+		Alerts:   alertsService,
+		Webhooks: webhooksService,
+		Email:    emailService,
+
+		ReadLimiter:  readLimiter,
+		WriteLimiter: writeLimiter,
+		CORS:         initializeCORS(cfg),
+	})
+
+	cancel()
+	workers.Wait()
+
+	exitCode := 0
+
+	if err := storageService.Close(); err != nil {
+		logger.Error("Error closing storage", zap.Error(err))
+
+		exitCode = 1
+	}
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelFlush()
+
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Error("Error flushing traces", zap.Error(err))
+
+		exitCode = 1
+	}
+
+	if serverErr != nil {
+		logger.Error("Server stopped with an error", zap.Error(serverErr))
+
+		exitCode = 1
+	}
+
+	select {
+	case err := <-grpcErr:
+		logger.Error("gRPC server stopped with an error", zap.Error(err))
+
+		exitCode = 1
+	default:
+	}
+
+	logger.Info("Shutdown complete", zap.Int("exitCode", exitCode))
+
+	return exitCode
+}
+
+// startWorker runs fn in a goroutine tracked by workers.
+func startWorker(workers *sync.WaitGroup, fn func()) {
+	workers.Add(1)
+
+	go func() {
+		defer workers.Done()
+		fn()
+	}()
 }
 
 // loadConfig loads the config.
@@ -46,23 +217,55 @@ func loadConfig() *config.Config {
 		os.Exit(1)
 	}
 
+	if err := config.Validate(); err != nil {
+		zap.L().Fatal("Invalid config", zap.Error(err))
+		os.Exit(1)
+	}
+
 	return config
 }
 
-// initializeLogger sets up the zap logger.
-func initializeLogger(config *config.Config) *zap.Logger {
-	logger, err := logger.CreateLogger(config.LogLevel)
+// runConfigCommand handles the config subcommands and returns an exit code.
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: weatherApp config print [flags]")
+		return 2
+	}
+
+	cfg, err := config.Load(args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return 1
+	}
+
+	if err := cfg.Print(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error printing config: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// initializeLogger sets up the zap logger and returns it with its adjustable level.
+func initializeLogger(config *config.Config) (*zap.Logger, zap.AtomicLevel) {
+	logger, level, err := logger.CreateLogger(config.LogLevel)
 	if err != nil {
 		zap.L().Fatal("Failed to initialize logger", zap.Error(err))
 		os.Exit(1)
 	}
 
-	return logger
+	return logger, level
 }
 
-// initializeServices sets up services and returns a weatherService.
-func initializeServices(cfg *config.Config, logger *zap.Logger) *weather.Service {
+// initializeServices sets up services and returns the storageService and weatherService.
+func initializeServices(
+	cfg *config.Config,
+	logger *zap.Logger,
+	metricsService *metrics.Service,
+) (*storage.Service, *weather.Service) {
 	storageService := storage.NewStorageService(cfg.DatabaseURL, logger)
+	storageService.Metrics = metricsService
+
 	weatherService := weather.NewWeatherService(
 		logger,
 		storageService,
@@ -70,14 +273,97 @@ func initializeServices(cfg *config.Config, logger *zap.Logger) *weather.Service
 		cfg.ForecastWeatherAPIURL,
 		cfg.GeocodeAPIURL,
 	)
+	weatherService.Metrics = metricsService
+	weatherService.GeocodeCache = cache.NewCache[weather.Coordinates]("geocode", cfg.GeocodeCacheTTL, metricsService)
+	weatherService.WeatherCache = cache.NewCache[[]byte]("weather", cfg.WeatherCacheTTL, metricsService)
+
+	return storageService, weatherService
+}
+
+// initializeHealth registers the readiness checks for storage and the upstream APIs.
+func initializeHealth(
+	cfg *config.Config,
+	logger *zap.Logger,
+	storageService *storage.Service,
+	weatherService *weather.Service,
+) *health.Service {
+	healthService := health.NewHealthService(logger, cfg.HealthCacheTTL)
+	healthService.AddCheck("storage", storageService.Check)
+	healthService.AddCheck("weather_api", health.HTTPCheck(http.DefaultClient, func() string {
+		currentWeatherAPIURL, _, _ := weatherService.APIURLs()
+		return currentWeatherAPIURL
+	}))
+	healthService.AddCheck("geocode_api", health.HTTPCheck(http.DefaultClient, func() string {
+		_, _, geocodeAPIURL := weatherService.APIURLs()
+		return geocodeAPIURL
+	}))
+
+	return healthService
+}
 
-	return weatherService
+// initializeEmail builds the email service. Without SMTPHost it has no mailer, so users can't opt in.
+func initializeEmail(
+	cfg *config.Config,
+	logger *zap.Logger,
+	weatherService *weather.Service,
+	storageService *storage.Service,
+) *email.Service {
+	if cfg.SMTPHost == "" {
+		return email.NewEmailService(logger, weatherService, storageService, nil, nil,
+			cfg.EmailDigestHour, cfg.EmailMaxAttempts, cfg.EmailBackoff)
+	}
+
+	// Validate already checked the sender when SMTP is enabled.
+	from, _ := mail.ParseAddress(cfg.SMTPFrom)
+	mailer := email.NewMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPTLS, cfg.SMTPTimeout)
+
+	return email.NewEmailService(logger, weatherService, storageService, mailer, from,
+		cfg.EmailDigestHour, cfg.EmailMaxAttempts, cfg.EmailBackoff)
+}
+
+// initializeCORS builds the CORS handler, or returns nil when no origins are allowed.
+func initializeCORS(cfg *config.Config) *cors.Cors {
+	if len(cfg.CORSAllowedOrigins) == 0 {
+		return nil
+	}
+
+	return cors.New(cors.Options{ //nolint:exhaustruct // optional fields
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedMethods:   cfg.CORSAllowedMethods,
+		AllowedHeaders:   cfg.CORSAllowedHeaders,
+		ExposedHeaders:   []string{"X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           int(cfg.CORSMaxAge.Seconds()),
+	})
 }
 
-// startServer sets up the routes and starts the server.
-func startServer(cfg *config.Config, logger *zap.Logger, weatherService *weather.Service) {
+// originHosts turns the CORS origins into the host patterns WebSocket upgrades are checked against.
+func originHosts(origins []string) []string {
+	hosts := []string{}
+
+	for _, origin := range origins {
+		if origin == "*" {
+			hosts = append(hosts, origin)
+			continue
+		}
+
+		if parsed, err := url.Parse(origin); err == nil && parsed.Host != "" {
+			hosts = append(hosts, parsed.Host)
+		}
+	}
+
+	return hosts
+}
+
+// startServer sets up the routes and serves until ctx is done, then drains in-flight requests.
+func startServer(
+	ctx context.Context,
+	cfg *config.Config,
+	logger *zap.Logger,
+	services routes.Services,
+) error {
 	r := chi.NewRouter()
-	routes.RegisterRoutes(r, weatherService)
+	routes.RegisterRoutes(r, services)
 
 	logger.Info("Server running", zap.String("port", cfg.Port))
 	server := &http.Server{
@@ -88,7 +374,26 @@ func startServer(cfg *config.Config, logger *zap.Logger, weatherService *weather
 		IdleTimeout:  idleTimeout,
 	}
 
-	if err := server.ListenAndServe(); err != nil {
-		logger.Fatal("Error starting server", zap.Error(err))
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("error starting server: %w", err)
+	case <-ctx.Done():
 	}
+
+	logger.Info("Shutting down, draining requests", zap.Duration("timeout", cfg.ShutdownTimeout))
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("error draining requests: %w", err)
+	}
+
+	return nil
 }
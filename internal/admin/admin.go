@@ -0,0 +1,85 @@
+// Package admin handles operator-only endpoints.
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/logger"
+)
+
+// ErrInvalidBody is returned when the request body isn't valid JSON.
+var ErrInvalidBody = errors.New("invalid request body")
+
+// LogLevelResponse is the body returned and accepted by the log level endpoint.
+type LogLevelResponse struct {
+	Level string `json:"level"`
+}
+
+// Service handles dependencies and config.
+// Access control for the admin routes is done by the auth package.
+type Service struct {
+	Logger *zap.Logger
+	Level  zap.AtomicLevel
+}
+
+// NewAdminService creates a new instance of Service.
+func NewAdminService(l *zap.Logger, level zap.AtomicLevel) *Service {
+	return &Service{
+		Logger: l,
+		Level:  level,
+	}
+}
+
+// GetLogLevel returns the current log level.
+func (s *Service) GetLogLevel(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(s.logLevel()); err != nil {
+		s.Logger.Error("Error encoding response", zap.Error(err))
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateLogLevel changes the log level of the running server.
+func (s *Service) UpdateLogLevel(w http.ResponseWriter, r *http.Request) error {
+	log := logger.FromContext(r.Context(), s.Logger)
+
+	var reqBody LogLevelResponse
+
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		log.Warn("Invalid request body", zap.Error(err))
+		return fmt.Errorf("%w: %w", ErrInvalidBody, err)
+	}
+
+	level, err := logger.ParseLevel(reqBody.Level)
+	if err != nil {
+		log.Warn("Invalid log level", zap.String("level", reqBody.Level))
+		return fmt.Errorf("failed to parse log level: %w", err)
+	}
+
+	previous := s.logLevel()
+	s.Level.SetLevel(level)
+	log.Info("Log level changed", zap.String("old", previous.Level), zap.String("new", s.logLevel().Level))
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(s.logLevel()); err != nil {
+		log.Error("Error encoding response", zap.Error(err))
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+
+	return nil
+}
+
+// logLevel returns the current level in the same format as LOG_LEVEL.
+func (s *Service) logLevel() LogLevelResponse {
+	return LogLevelResponse{Level: strings.ToUpper(s.Level.String())}
+}
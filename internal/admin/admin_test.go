@@ -0,0 +1,74 @@
+package admin_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/admin"
+)
+
+func setupAdminService() (*admin.Service, zap.AtomicLevel) {
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	return admin.NewAdminService(zap.NewNop(), level), level
+}
+
+func TestUpdateLogLevel(t *testing.T) {
+	t.Parallel()
+
+	adminService, level := setupAdminService()
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/loglevel", bytes.NewBufferString(`{"level": "WARN"}`))
+	rec := httptest.NewRecorder()
+
+	if err := adminService.UpdateLogLevel(rec, req); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if level.Level() != zap.WarnLevel {
+		t.Errorf("expected level to be warn, got %v", level.Level())
+	}
+
+	var response admin.LogLevelResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if response.Level != "WARN" {
+		t.Errorf("expected level to be 'WARN', got %v", response.Level)
+	}
+}
+
+func TestUpdateLogLevelInvalid(t *testing.T) {
+	t.Parallel()
+
+	adminService, level := setupAdminService()
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/loglevel", bytes.NewBufferString(`{"level": "LOUD"}`))
+	rec := httptest.NewRecorder()
+
+	if err := adminService.UpdateLogLevel(rec, req); err == nil {
+		t.Fatal("expected an error for an invalid level")
+	}
+
+	if level.Level() != zap.InfoLevel {
+		t.Errorf("expected level to stay info, got %v", level.Level())
+	}
+}
+
+func TestUpdateLogLevelInvalidBody(t *testing.T) {
+	t.Parallel()
+
+	adminService, _ := setupAdminService()
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/loglevel", bytes.NewBufferString(`{"level": `))
+
+	if err := adminService.UpdateLogLevel(httptest.NewRecorder(), req); !errors.Is(err, admin.ErrInvalidBody) {
+		t.Errorf("expected %v, got %v", admin.ErrInvalidBody, err)
+	}
+}
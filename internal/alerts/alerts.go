@@ -0,0 +1,527 @@
+// Package alerts lets users define threshold rules on the weather of a city and checks them
+// on a schedule, recording an event the first time a rule's condition holds in an episode.
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/logger"
+	"github.com/codyonesock/rest_weather/internal/shared"
+	"github.com/codyonesock/rest_weather/internal/storage"
+	"github.com/codyonesock/rest_weather/internal/weather"
+)
+
+// Metrics a rule can watch. The first two are current conditions, the rest daily forecasts.
+const (
+	MetricTemperature    = "temperature"
+	MetricWindSpeed      = "wind_speed"
+	MetricTemperatureMax = "temperature_max"
+	MetricTemperatureMin = "temperature_min"
+	MetricWindSpeedMax   = "wind_speed_max"
+)
+
+// Operators comparing a metric to a rule's threshold.
+const (
+	OperatorAbove = "above"
+	OperatorBelow = "below"
+)
+
+const (
+	// MaxRules is how many rules a user may have.
+	MaxRules = 50
+	// maxEvents caps the fired events kept in a user's history.
+	maxEvents = 100
+	// maxDays is the longest forecast window, open-meteo's default forecast length.
+	maxDays = 7
+)
+
+// err113 demands no dynamic errors!
+var (
+	ErrInvalidBody       = errors.New("invalid request body")
+	ErrCityRequired      = errors.New("city is required")
+	ErrInvalidMetric     = errors.New("invalid metric")
+	ErrInvalidOperator   = errors.New("operator must be above or below")
+	ErrInvalidUnits      = errors.New("units must be metric or imperial")
+	ErrInvalidDays       = errors.New("invalid days")
+	ErrTooManyRules      = errors.New("too many alert rules")
+	ErrRuleNotFound      = errors.New("alert rule not found")
+	ErrMetricUnavailable = errors.New("metric not in the forecast")
+)
+
+// RuleRequest is the body to create or replace a rule.
+// Units default to the user's units and Days to 1 for forecast metrics.
+type RuleRequest struct {
+	City      string  `json:"city"`
+	Metric    string  `json:"metric"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+	Units     string  `json:"units"`
+	Days      int     `json:"days"`
+}
+
+// Notifier is told about every event once it's saved. ctx identifies the rule's user.
+// NotifyAlert is called from the evaluator, so it must hand the event off rather than send it.
+type Notifier interface {
+	NotifyAlert(ctx context.Context, event shared.AlertEvent)
+}
+
+// Service handles dependencies and config.
+// mu serializes rule edits with the evaluator saving what fired.
+type Service struct {
+	mu        sync.Mutex
+	Logger    *zap.Logger
+	Weather   *weather.Service
+	Store     storage.AlertStore
+	Interval  time.Duration
+	Notifiers []Notifier
+}
+
+// NewAlertsService creates a new instance of Service that evaluates rules every interval.
+func NewAlertsService(
+	l *zap.Logger,
+	weatherService *weather.Service,
+	store storage.AlertStore,
+	interval time.Duration,
+) *Service {
+	return &Service{
+		mu:        sync.Mutex{},
+		Logger:    l,
+		Weather:   weatherService,
+		Store:     store,
+		Interval:  interval,
+		Notifiers: nil,
+	}
+}
+
+// ListRules writes the rules of the user.
+func (s *Service) ListRules(w http.ResponseWriter, r *http.Request) error {
+	alerts, err := s.load(r.Context())
+	if err != nil {
+		return err
+	}
+
+	return shared.WriteJSON(w, http.StatusOK, alerts.Rules)
+}
+
+// GetRule writes the rule with id.
+func (s *Service) GetRule(w http.ResponseWriter, r *http.Request, id string) error {
+	alerts, err := s.load(r.Context())
+	if err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(alerts.Rules, func(rule shared.AlertRule) bool { return rule.ID == id })
+	if i == -1 {
+		return fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+
+	return shared.WriteJSON(w, http.StatusOK, alerts.Rules[i])
+}
+
+// CreateRule adds a rule from the request body.
+func (s *Service) CreateRule(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	rule, err := s.decodeRule(r)
+	if err != nil {
+		return err
+	}
+
+	rule.ID = shared.NewID()
+	rule.CreatedAt = time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alerts, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(alerts.Rules) >= MaxRules {
+		return fmt.Errorf("%w, at most %d", ErrTooManyRules, MaxRules)
+	}
+
+	alerts.Rules = append(alerts.Rules, rule)
+	if err := s.save(ctx, alerts); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx, s.Logger).Info("Alert rule created", zap.String("id", rule.ID), zap.String("city", rule.City))
+
+	return shared.WriteJSON(w, http.StatusCreated, rule)
+}
+
+// UpdateRule replaces the condition of the rule with id, which starts a new episode.
+func (s *Service) UpdateRule(w http.ResponseWriter, r *http.Request, id string) error {
+	ctx := r.Context()
+
+	rule, err := s.decodeRule(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alerts, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(alerts.Rules, func(rule shared.AlertRule) bool { return rule.ID == id })
+	if i == -1 {
+		return fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+
+	rule.ID, rule.CreatedAt = id, alerts.Rules[i].CreatedAt
+	alerts.Rules[i] = rule
+
+	if err := s.save(ctx, alerts); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx, s.Logger).Info("Alert rule updated", zap.String("id", id))
+
+	return shared.WriteJSON(w, http.StatusOK, rule)
+}
+
+// DeleteRule removes the rule with id. Its events are kept.
+func (s *Service) DeleteRule(w http.ResponseWriter, r *http.Request, id string) error {
+	ctx := r.Context()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alerts, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(alerts.Rules, func(rule shared.AlertRule) bool { return rule.ID == id })
+	if i == -1 {
+		return fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+
+	alerts.Rules = slices.Delete(alerts.Rules, i, i+1)
+	if err := s.save(ctx, alerts); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx, s.Logger).Info("Alert rule deleted", zap.String("id", id))
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// ListEvents writes the events of the user, newest first.
+func (s *Service) ListEvents(w http.ResponseWriter, r *http.Request) error {
+	alerts, err := s.load(r.Context())
+	if err != nil {
+		return err
+	}
+
+	slices.Reverse(alerts.Events)
+
+	return shared.WriteJSON(w, http.StatusOK, alerts.Events)
+}
+
+// Run evaluates the rules every Interval until ctx is done.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Evaluate(ctx); err != nil {
+				s.Logger.Error("Failed to evaluate alert rules", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Evaluate checks every user's rules against freshly fetched weather. A rule whose condition
+// holds fires an event unless it's already firing; a firing rule whose condition no longer holds
+// is reset so it can fire again. Rules of cities that can't be fetched are left as they are.
+func (s *Service) Evaluate(ctx context.Context) error {
+	users, err := s.Store.AlertUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list alert users: %w", err)
+	}
+
+	// Users following the same city share its lookups.
+	ctx = weather.WithBatch(ctx)
+
+	for _, userID := range users {
+		s.evaluateUser(shared.WithUserID(ctx, userID))
+	}
+
+	return nil
+}
+
+// observation is the fresh weather of a city.
+type observation struct {
+	current  *weather.CurrentWeatherResponse
+	forecast *weather.ForecastResponse
+}
+
+// crossing is the value that met a rule's condition, and the forecast day it's for.
+type crossing struct {
+	value float64
+	date  string
+}
+
+// evaluateUser evaluates the rules of the user in ctx. The weather is fetched without holding
+// mu; the rules are then reloaded so edits made meanwhile are kept. The notifiers are told
+// about the fired events once mu is released.
+func (s *Service) evaluateUser(ctx context.Context) {
+	log := s.Logger.With(zap.String("user", shared.UserIDFromContext(ctx)))
+
+	alerts, err := s.load(ctx)
+	if err != nil {
+		log.Error("Failed to load alert rules", zap.Error(err))
+		return
+	}
+
+	observations := map[string]observation{}
+
+	for _, rule := range alerts.Rules {
+		key := strings.ToLower(rule.City)
+		if _, ok := observations[key]; ok {
+			continue
+		}
+
+		current, forecast, err := s.Weather.Refresh(ctx, rule.City)
+		if err != nil {
+			log.Warn("Failed to fetch weather for alert rules", zap.String("city", rule.City), zap.Error(err))
+			continue
+		}
+
+		observations[key] = observation{current: current, forecast: forecast}
+	}
+
+	for _, event := range s.apply(ctx, log, observations) {
+		for _, notifier := range s.Notifiers {
+			notifier.NotifyAlert(ctx, event)
+		}
+	}
+}
+
+// apply checks the rules of the user in ctx against observations under mu, saving the rules
+// that changed state, and returns the events that fired.
+func (s *Service) apply(ctx context.Context, log *zap.Logger, observations map[string]observation) []shared.AlertEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alerts, err := s.load(ctx)
+	if err != nil {
+		log.Error("Failed to load alert rules", zap.Error(err))
+		return nil
+	}
+
+	now := time.Now().UTC()
+	fired := []shared.AlertEvent{}
+	changed := false
+
+	for i := range alerts.Rules {
+		rule := &alerts.Rules[i]
+
+		obs, ok := observations[strings.ToLower(rule.City)]
+		if !ok {
+			continue
+		}
+
+		crossed, err := check(*rule, obs)
+		if err != nil {
+			log.Warn("Failed to evaluate alert rule", zap.String("id", rule.ID), zap.Error(err))
+			continue
+		}
+
+		switch {
+		case crossed != nil && !rule.Firing:
+			rule.Firing = true
+			event := newEvent(*rule, *crossed, now)
+			alerts.Events = append(alerts.Events, event)
+			fired = append(fired, event)
+			changed = true
+
+			log.Info("Alert fired", zap.String("id", rule.ID), zap.String("city", rule.City), zap.Float64("value", crossed.value))
+		case crossed == nil && rule.Firing:
+			rule.Firing = false
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if len(alerts.Events) > maxEvents {
+		alerts.Events = alerts.Events[len(alerts.Events)-maxEvents:]
+	}
+
+	if err := s.save(ctx, alerts); err != nil {
+		log.Error("Failed to save alert state", zap.Error(err))
+		return nil
+	}
+
+	return fired
+}
+
+// check returns the first value of obs meeting rule's condition, or nil if none does.
+func check(rule shared.AlertRule, obs observation) (*crossing, error) {
+	var (
+		values  []float64
+		dates   []string
+		convert = weather.Temperature
+	)
+
+	daily := obs.forecast.Daily
+
+	switch rule.Metric {
+	case MetricTemperature:
+		values = []float64{obs.current.CurrentWeather.Temperature}
+	case MetricWindSpeed:
+		values, convert = []float64{obs.current.CurrentWeather.Windspeed}, weather.WindSpeed
+	case MetricTemperatureMax:
+		values, dates = daily.MaxTemps, daily.Dates
+	case MetricTemperatureMin:
+		values, dates = daily.MinTemps, daily.Dates
+	case MetricWindSpeedMax:
+		if len(daily.MaxWindSpeeds) == 0 {
+			return nil, fmt.Errorf("%w: add wind_speed_10m_max to the forecast URL's daily list", ErrMetricUnavailable)
+		}
+
+		values, dates, convert = daily.MaxWindSpeeds, daily.Dates, weather.WindSpeed
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMetric, rule.Metric)
+	}
+
+	if dates != nil {
+		values = values[:min(rule.Days, len(values))]
+	}
+
+	for i, value := range values {
+		value = convert(value, rule.Units)
+
+		if (rule.Operator == OperatorAbove && value > rule.Threshold) ||
+			(rule.Operator == OperatorBelow && value < rule.Threshold) {
+			c := crossing{value: value, date: ""}
+			if i < len(dates) {
+				c.date = dates[i]
+			}
+
+			return &c, nil
+		}
+	}
+
+	return nil, nil //nolint:nilnil // no crossing isn't an error
+}
+
+// decodeRule reads and validates a rule from the request body, filling in the defaults.
+func (s *Service) decodeRule(r *http.Request) (shared.AlertRule, error) {
+	var req RuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return shared.AlertRule{}, fmt.Errorf("%w: %w", ErrInvalidBody, err)
+	}
+
+	rule := shared.AlertRule{
+		ID:        "",
+		City:      strings.TrimSpace(req.City),
+		Metric:    req.Metric,
+		Operator:  req.Operator,
+		Threshold: req.Threshold,
+		Units:     req.Units,
+		Days:      req.Days,
+		CreatedAt: time.Time{},
+		Firing:    false,
+	}
+
+	if rule.City == "" {
+		return rule, ErrCityRequired
+	}
+
+	switch rule.Metric {
+	case MetricTemperature, MetricWindSpeed:
+		if rule.Days != 0 {
+			return rule, fmt.Errorf("%w: only forecast metrics take days", ErrInvalidDays)
+		}
+	case MetricTemperatureMax, MetricTemperatureMin, MetricWindSpeedMax:
+		if rule.Days == 0 {
+			rule.Days = 1
+		}
+
+		if rule.Days < 1 || rule.Days > maxDays {
+			return rule, fmt.Errorf("%w: must be 1 to %d", ErrInvalidDays, maxDays)
+		}
+	default:
+		return rule, fmt.Errorf("%w: %q", ErrInvalidMetric, rule.Metric)
+	}
+
+	if rule.Operator != OperatorAbove && rule.Operator != OperatorBelow {
+		return rule, ErrInvalidOperator
+	}
+
+	if rule.Units == "" {
+		userData, err := s.Weather.UserData(r.Context())
+		if err != nil {
+			return rule, fmt.Errorf("failed to load user units: %w", err)
+		}
+
+		rule.Units = userData.Units
+	}
+
+	if rule.Units != weather.UnitsMetric && rule.Units != weather.UnitsImperial {
+		return rule, ErrInvalidUnits
+	}
+
+	return rule, nil
+}
+
+func (s *Service) load(ctx context.Context) (shared.Alerts, error) {
+	alerts, err := s.Store.LoadAlerts(ctx)
+	if err != nil {
+		logger.FromContext(ctx, s.Logger).Error("Error loading alerts", zap.Error(err))
+		return shared.Alerts{}, fmt.Errorf("failed to load alerts: %w", err)
+	}
+
+	return alerts, nil
+}
+
+func (s *Service) save(ctx context.Context, alerts shared.Alerts) error {
+	if err := s.Store.SaveAlerts(ctx, alerts); err != nil {
+		logger.FromContext(ctx, s.Logger).Error("Error saving alerts", zap.Error(err))
+		return fmt.Errorf("failed to save alerts: %w", err)
+	}
+
+	return nil
+}
+
+// newEvent records rule firing on c at now.
+func newEvent(rule shared.AlertRule, c crossing, now time.Time) shared.AlertEvent {
+	return shared.AlertEvent{
+		ID:        shared.NewID(),
+		RuleID:    rule.ID,
+		City:      rule.City,
+		Metric:    rule.Metric,
+		Operator:  rule.Operator,
+		Threshold: rule.Threshold,
+		Value:     c.value,
+		Units:     rule.Units,
+		Date:      c.date,
+		FiredAt:   now,
+	}
+}
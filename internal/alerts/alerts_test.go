@@ -0,0 +1,239 @@
+package alerts_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/alerts"
+	"github.com/codyonesock/rest_weather/internal/shared"
+	"github.com/codyonesock/rest_weather/internal/weather"
+	"github.com/codyonesock/rest_weather/internal/weathertest"
+)
+
+// setupAlerts returns an alerts service backed by a fake upstream forecasting three days.
+func setupAlerts(t *testing.T) (*alerts.Service, *weathertest.Upstream) {
+	t.Helper()
+
+	upstream := weathertest.NewUpstream(t)
+	upstream.SetForecast(weathertest.Forecast{
+		Dates:        []string{"2026-10-18", "2026-10-19", "2026-10-20"},
+		MinTemps:     []float64{1, 2, 3},
+		MaxTemps:     []float64{12, 14, 16},
+		WeatherCodes: []int{0, 0, 0},
+	})
+
+	weatherService, storageService := upstream.NewWeatherService(t)
+
+	return alerts.NewAlertsService(zap.NewNop(), weatherService, storageService, time.Hour), upstream
+}
+
+func createRule(t *testing.T, service *alerts.Service, ctx context.Context, body string) shared.AlertRule {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/user/alerts", strings.NewReader(body))
+
+	if err := service.CreateRule(rec, req); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", rec.Code)
+	}
+
+	var rule shared.AlertRule
+	if err := json.NewDecoder(rec.Body).Decode(&rule); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	return rule
+}
+
+func listEvents(t *testing.T, service *alerts.Service, ctx context.Context) []shared.AlertEvent {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	if err := service.ListEvents(rec, httptest.NewRequestWithContext(ctx, http.MethodGet, "/user/alerts/events", nil)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var events []shared.AlertEvent
+	if err := json.NewDecoder(rec.Body).Decode(&events); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	return events
+}
+
+func TestEvaluateFiresOncePerEpisode(t *testing.T) {
+	t.Parallel()
+
+	service, upstream := setupAlerts(t)
+	ctx := context.Background()
+
+	rule := createRule(t, service, ctx, `{"city": "halifax", "metric": "temperature", "operator": "above", "threshold": 20}`)
+	if rule.Units != weather.UnitsMetric || rule.Days != 0 || rule.Firing {
+		t.Errorf("expected a metric rule that isn't firing, got %+v", rule)
+	}
+
+	for _, step := range []struct {
+		temperature float64
+		events      int
+	}{
+		{10, 0}, // below the threshold
+		{25, 1}, // crosses it
+		{27, 1}, // still above, same episode
+		{15, 1}, // clears
+		{22, 2}, // crosses again
+	} {
+		upstream.SetTemperature(step.temperature)
+
+		if err := service.Evaluate(ctx); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if events := listEvents(t, service, ctx); len(events) != step.events {
+			t.Fatalf("expected %d events at %g°C, got %d", step.events, step.temperature, len(events))
+		}
+	}
+
+	events := listEvents(t, service, ctx)
+	if events[0].Value != 22 || events[1].Value != 25 || events[0].RuleID != rule.ID {
+		t.Errorf("expected the newest event first, got %+v", events)
+	}
+}
+
+func TestEvaluateForecastRulesInTheirUnits(t *testing.T) {
+	t.Parallel()
+
+	service, _ := setupAlerts(t)
+	ctx := shared.WithUserID(context.Background(), "alice")
+
+	// 16°C is 60.8°F, on the third day.
+	createRule(t, service, ctx, `{"city": "halifax", "metric": "temperature_max", "operator": "above", "threshold": 60, "units": "imperial", "days": 3}`)
+	createRule(t, service, ctx, `{"city": "halifax", "metric": "temperature_max", "operator": "above", "threshold": 60, "units": "imperial"}`)
+
+	if err := service.Evaluate(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	events := listEvents(t, service, ctx)
+	if len(events) != 1 || events[0].Date != "2026-10-20" || events[0].Value < 60.7 || events[0].Value > 60.9 {
+		t.Fatalf("expected one event for the third day, got %+v", events)
+	}
+
+	// Other users don't see alice's events.
+	if events := listEvents(t, service, context.Background()); len(events) != 0 {
+		t.Errorf("expected no events for the default user, got %+v", events)
+	}
+}
+
+// notifierFunc adapts a function to alerts.Notifier.
+type notifierFunc func(ctx context.Context, event shared.AlertEvent)
+
+func (f notifierFunc) NotifyAlert(ctx context.Context, event shared.AlertEvent) { f(ctx, event) }
+
+func TestNotifiersCanEditRules(t *testing.T) {
+	t.Parallel()
+
+	service, upstream := setupAlerts(t)
+	ctx := context.Background()
+
+	createRule(t, service, ctx, `{"city": "halifax", "metric": "temperature", "operator": "above", "threshold": 20}`)
+	upstream.SetTemperature(25)
+
+	notified := 0
+	service.Notifiers = []alerts.Notifier{notifierFunc(func(ctx context.Context, _ shared.AlertEvent) {
+		notified++
+		// Deadlocks if the evaluator still holds the rules lock.
+		createRule(t, service, ctx, `{"city": "berlin", "metric": "temperature", "operator": "below", "threshold": 0}`)
+	})}
+
+	if err := service.Evaluate(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if notified != 1 {
+		t.Errorf("expected one notification, got %d", notified)
+	}
+}
+
+func TestRuleCRUD(t *testing.T) {
+	t.Parallel()
+
+	service, _ := setupAlerts(t)
+	ctx := context.Background()
+
+	rule := createRule(t, service, ctx, `{"city": "halifax", "metric": "wind_speed_max", "operator": "above", "threshold": 50}`)
+	if rule.Days != 1 {
+		t.Errorf("expected forecast rules to default to 1 day, got %d", rule.Days)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/user/alerts/"+rule.ID,
+		strings.NewReader(`{"city": "berlin", "metric": "temperature", "operator": "below", "threshold": 0}`))
+
+	if err := service.UpdateRule(rec, req, rule.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	if err := service.GetRule(rec, httptest.NewRequest(http.MethodGet, "/user/alerts/"+rule.ID, nil), rule.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var got shared.AlertRule
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if got.City != "berlin" || got.Operator != alerts.OperatorBelow || !got.CreatedAt.Equal(rule.CreatedAt) {
+		t.Errorf("expected the updated rule, got %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	if err := service.DeleteRule(rec, httptest.NewRequest(http.MethodDelete, "/user/alerts/"+rule.ID, nil), rule.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", rec.Code)
+	}
+
+	err := service.DeleteRule(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/user/alerts/"+rule.ID, nil), rule.ID)
+	if !errors.Is(err, alerts.ErrRuleNotFound) {
+		t.Errorf("expected %v, got %v", alerts.ErrRuleNotFound, err)
+	}
+}
+
+func TestCreateRuleValidation(t *testing.T) {
+	t.Parallel()
+
+	service, _ := setupAlerts(t)
+
+	for _, tc := range []struct {
+		body string
+		want error
+	}{
+		{`{"city": `, alerts.ErrInvalidBody},
+		{`{"city": " ", "metric": "temperature", "operator": "above"}`, alerts.ErrCityRequired},
+		{`{"city": "halifax", "metric": "humidity", "operator": "above"}`, alerts.ErrInvalidMetric},
+		{`{"city": "halifax", "metric": "temperature", "operator": "equals"}`, alerts.ErrInvalidOperator},
+		{`{"city": "halifax", "metric": "temperature", "operator": "above", "units": "kelvin"}`, alerts.ErrInvalidUnits},
+		{`{"city": "halifax", "metric": "temperature", "operator": "above", "days": 2}`, alerts.ErrInvalidDays},
+		{`{"city": "halifax", "metric": "temperature_min", "operator": "below", "days": 8}`, alerts.ErrInvalidDays},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/user/alerts", strings.NewReader(tc.body))
+		if err := service.CreateRule(httptest.NewRecorder(), req); !errors.Is(err, tc.want) {
+			t.Errorf("expected %v for %s, got %v", tc.want, tc.body, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Package atom writes RFC 4287 Atom feeds.
+package atom
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ContentType is the media type of an Atom feed.
+const ContentType = "application/atom+xml; charset=utf-8"
+
+// Link is an atom:link.
+type Link struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+// Person is an atom:author.
+type Person struct {
+	Name string `xml:"name"`
+}
+
+// Text is a plain text construct such as atom:summary.
+type Text struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Entry is an atom:entry. ID must stay the same across feeds so readers update the entry.
+type Entry struct {
+	ID      string    `xml:"id"`
+	Title   string    `xml:"title"`
+	Updated time.Time `xml:"updated"`
+	Links   []Link    `xml:"link"`
+	Summary Text      `xml:"summary"`
+}
+
+// Feed is an atom:feed.
+type Feed struct {
+	XMLName xml.Name  `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string    `xml:"id"`
+	Title   string    `xml:"title"`
+	Updated time.Time `xml:"updated"`
+	Author  Person    `xml:"author"`
+	Links   []Link    `xml:"link"`
+	Entries []Entry   `xml:"entry"`
+}
+
+// PlainText returns a text construct of type text.
+func PlainText(body string) Text {
+	return Text{Type: "text", Body: body}
+}
+
+// Encode writes f to w as an XML document.
+func Encode(w io.Writer, f Feed) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed to write feed: %w", err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(f); err != nil {
+		return fmt.Errorf("failed to encode feed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package atom_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codyonesock/rest_weather/internal/atom"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	updated := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+
+	var b strings.Builder
+
+	err := atom.Encode(&b, atom.Feed{
+		XMLName: xml.Name{Space: "", Local: ""},
+		ID:      "urn:rest-weather:feed",
+		Title:   "Saved cities",
+		Updated: updated,
+		Author:  atom.Person{Name: "rest_weather"},
+		Links:   []atom.Link{{Rel: "self", Type: atom.ContentType, Href: "https://example.com/user/feed.atom"}},
+		Entries: []atom.Entry{{
+			ID:      "urn:rest-weather:city:halifax",
+			Title:   "halifax: Rain & 12.5 °C",
+			Updated: updated,
+			Links:   nil,
+			Summary: atom.PlainText("Now: Rain"),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<feed xmlns="http://www.w3.org/2005/Atom">`,
+		`<updated>2026-10-18T12:00:00Z</updated>`,
+		`<title>halifax: Rain &amp; 12.5 °C</title>`,
+		`<summary type="text">Now: Rain</summary>`,
+		`<link rel="self" type="application/atom+xml; charset=utf-8" href="https://example.com/user/feed.atom"></link>`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("expected feed to contain %q, got %s", want, b.String())
+		}
+	}
+
+	var decoded atom.Feed
+	if err := xml.Unmarshal([]byte(b.String()), &decoded); err != nil {
+		t.Fatalf("expected valid XML, got %v", err)
+	}
+
+	if len(decoded.Entries) != 1 || decoded.Entries[0].ID != "urn:rest-weather:city:halifax" {
+		t.Errorf("expected the halifax entry, got %+v", decoded.Entries)
+	}
+}
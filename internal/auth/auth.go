@@ -0,0 +1,394 @@
+// Package auth handles API key and JWT bearer authentication and scope checks.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/logger"
+	"github.com/codyonesock/rest_weather/internal/shared"
+	"github.com/codyonesock/rest_weather/internal/storage"
+)
+
+// Scopes that can be granted to an API key.
+const (
+	ScopeReadWeather = "read:weather"
+	ScopeWriteUser   = "write:user"
+	ScopeAdmin       = "admin"
+)
+
+const (
+	// APIKeyHeader is an alternative to Authorization: Bearer for sending API keys.
+	APIKeyHeader = "X-API-Key"
+	// AnonymousID is the KeyID of requests without credentials when auth isn't required.
+	AnonymousID = "anonymous"
+)
+
+const (
+	// keyPrefix marks API keys (wk_<id>_<secret>) so they are easy to spot in logs and secret scanners.
+	keyPrefix      = "wk"
+	keyParts       = 3
+	keyIDBytes     = 8
+	keySecretBytes = 24
+	adminTokenID   = "admin-token"
+)
+
+// err113 demands no dynamic errors!
+var (
+	ErrInvalidScope  = errors.New("invalid scope")
+	ErrNameRequired  = errors.New("name is required")
+	ErrKeyNotFound   = errors.New("API key not found")
+	ErrInvalidAPIKey = errors.New("invalid API key")
+)
+
+var (
+	validScopes = []string{ScopeReadWeather, ScopeWriteUser, ScopeAdmin}
+	// anonymousScopes are granted to requests without credentials when auth isn't required.
+	// Changing user data always takes a key or token.
+	anonymousScopes = []string{ScopeReadWeather}
+	// tokenScopes are granted to bearer tokens without a scope claim.
+	tokenScopes = []string{ScopeReadWeather, ScopeWriteUser}
+)
+
+// Principal is the authenticated caller of a request.
+// Subject is set for bearer tokens and selects the user profile in storage.
+type Principal struct {
+	KeyID   string
+	Subject string
+	Scopes  []string
+}
+
+// HasScope reports whether the principal was granted scope. Admin implies every scope.
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// Anonymous reports whether the principal made the request without credentials.
+func (p Principal) Anonymous() bool {
+	return p.KeyID == AnonymousID
+}
+
+type contextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored by Authenticate, if any.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
+
+// CreateKeyRequest is the body accepted when creating an API key.
+type CreateKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreateKeyResponse is returned once when a key is created; the key can't be retrieved later.
+type CreateKeyResponse struct {
+	shared.APIKey
+
+	Key string `json:"key"`
+}
+
+// Service handles dependencies and config.
+type Service struct {
+	mu         sync.Mutex
+	Logger     *zap.Logger
+	Store      storage.APIKeyStore
+	AdminToken string
+	Required   bool
+
+	// JWT validates bearer tokens, nil disables them.
+	JWT *JWTVerifier
+
+	// keys indexes the stored keys by ID, nil until loaded and again after a key changes.
+	keys map[string]shared.APIKey
+}
+
+// NewAuthService creates a new instance of Service.
+// When required is false, requests without credentials get the read scope only.
+func NewAuthService(l *zap.Logger, store storage.APIKeyStore, adminToken string, required bool) *Service {
+	return &Service{
+		mu:         sync.Mutex{},
+		Logger:     l,
+		Store:      store,
+		AdminToken: adminToken,
+		Required:   required,
+		JWT:        nil,
+		keys:       nil,
+	}
+}
+
+// Authenticate resolves the API key or bearer token of a request into a Principal stored in the context.
+// Requests with invalid credentials are rejected; requests without any are left to RequireScope.
+func (s *Service) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := s.AuthenticateContext(r.Context(), credentialFromRequest(r))
+		if err != nil {
+			logger.FromContext(r.Context(), s.Logger).Warn("Rejected credentials", zap.Error(err))
+			unauthorized(w)
+
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// AuthenticateContext checks credential and returns ctx with its principal, user and log fields.
+// Without a credential the caller is anonymous, or has no principal at all if auth is required.
+func (s *Service) AuthenticateContext(ctx context.Context, credential string) (context.Context, error) {
+	if credential == "" {
+		if !s.Required {
+			ctx = WithPrincipal(ctx, Principal{KeyID: AnonymousID, Subject: "", Scopes: anonymousScopes})
+		}
+
+		return ctx, nil
+	}
+
+	principal, err := s.authenticate(ctx, credential)
+	if err != nil {
+		return ctx, err
+	}
+
+	ctx = WithPrincipal(ctx, principal)
+
+	if principal.Subject != "" {
+		ctx = shared.WithUserID(ctx, principal.Subject)
+		ctx = logger.WithContext(ctx, logger.FromContext(ctx, s.Logger).With(zap.String("subject", principal.Subject)))
+	} else {
+		ctx = logger.WithContext(ctx, logger.FromContext(ctx, s.Logger).With(zap.String("api_key_id", principal.KeyID)))
+	}
+
+	return ctx, nil
+}
+
+// RequireScope rejects requests whose principal lacks scope.
+// Anonymous requests are asked for credentials rather than forbidden.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok || (principal.Anonymous() && !principal.HasScope(scope)) {
+				unauthorized(w)
+				return
+			}
+
+			if !principal.HasScope(scope) {
+				http.Error(w, "Forbidden: missing scope "+scope, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ListAPIKeys returns every key without its hash.
+func (s *Service) ListAPIKeys(w http.ResponseWriter, r *http.Request) error {
+	keys, err := s.Store.LoadAPIKeys(r.Context())
+	if err != nil {
+		logger.FromContext(r.Context(), s.Logger).Error("Error loading API keys", zap.Error(err))
+		return fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	response := make([]shared.APIKey, 0, len(keys))
+	for _, key := range keys {
+		key.Hash = ""
+		response = append(response, key)
+	}
+
+	return shared.WriteJSON(w, http.StatusOK, response)
+}
+
+// CreateAPIKey creates a key with the requested scopes and returns the plaintext key once.
+func (s *Service) CreateAPIKey(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	log := logger.FromContext(ctx, s.Logger)
+
+	var reqBody CreateKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		log.Error("Invalid request body", zap.Error(err))
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	if strings.TrimSpace(reqBody.Name) == "" {
+		return ErrNameRequired
+	}
+
+	for _, scope := range reqBody.Scopes {
+		if !slices.Contains(validScopes, scope) {
+			return fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+
+	id, secret := shared.RandomHex(keyIDBytes), shared.RandomHex(keySecretBytes)
+	key := shared.APIKey{
+		ID:        id,
+		Name:      reqBody.Name,
+		Hash:      hashSecret(secret),
+		Scopes:    reqBody.Scopes,
+		CreatedAt: time.Now().UTC(),
+		RevokedAt: nil,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.Store.LoadAPIKeys(ctx)
+	if err != nil {
+		log.Error("Error loading API keys", zap.Error(err))
+		return fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	if err := s.Store.SaveAPIKeys(ctx, append(keys, key)); err != nil {
+		log.Error("Error saving API keys", zap.Error(err))
+		return fmt.Errorf("failed to save API keys: %w", err)
+	}
+
+	s.keys = nil
+
+	log.Info("API key created", zap.String("id", id), zap.Strings("scopes", key.Scopes))
+
+	key.Hash = ""
+
+	return shared.WriteJSON(w, http.StatusCreated,
+		CreateKeyResponse{APIKey: key, Key: keyPrefix + "_" + id + "_" + secret})
+}
+
+// RevokeAPIKey marks the key with id as revoked so it can no longer authenticate.
+func (s *Service) RevokeAPIKey(w http.ResponseWriter, r *http.Request, id string) error {
+	ctx := r.Context()
+	log := logger.FromContext(ctx, s.Logger)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.Store.LoadAPIKeys(ctx)
+	if err != nil {
+		log.Error("Error loading API keys", zap.Error(err))
+		return fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	i := slices.IndexFunc(keys, func(key shared.APIKey) bool { return key.ID == id })
+	if i == -1 {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+
+	if keys[i].RevokedAt == nil {
+		now := time.Now().UTC()
+		keys[i].RevokedAt = &now
+	}
+
+	if err := s.Store.SaveAPIKeys(ctx, keys); err != nil {
+		log.Error("Error saving API keys", zap.Error(err))
+		return fmt.Errorf("failed to save API keys: %w", err)
+	}
+
+	s.keys = nil
+
+	log.Info("API key revoked", zap.String("id", id))
+
+	key := keys[i]
+	key.Hash = ""
+
+	return shared.WriteJSON(w, http.StatusOK, key)
+}
+
+// authenticate checks a credential against the JWKS, the admin token and the stored keys.
+func (s *Service) authenticate(ctx context.Context, credential string) (Principal, error) {
+	if s.JWT != nil && LooksLikeJWT(credential) {
+		claims, err := s.JWT.Verify(ctx, credential)
+		if err != nil {
+			return Principal{}, err
+		}
+
+		return Principal{KeyID: "", Subject: claims.Subject, Scopes: claims.Scopes()}, nil
+	}
+
+	if s.AdminToken != "" && subtle.ConstantTimeCompare([]byte(credential), []byte(s.AdminToken)) == 1 {
+		return Principal{KeyID: adminTokenID, Subject: "", Scopes: []string{ScopeAdmin}}, nil
+	}
+
+	parts := strings.SplitN(credential, "_", keyParts)
+	if len(parts) != keyParts || parts[0] != keyPrefix {
+		return Principal{}, ErrInvalidAPIKey
+	}
+
+	key, ok, err := s.lookupKey(ctx, parts[1])
+	if err != nil {
+		return Principal{}, err
+	}
+
+	if !ok || key.RevokedAt != nil || subtle.ConstantTimeCompare([]byte(hashSecret(parts[2])), []byte(key.Hash)) != 1 {
+		return Principal{}, ErrInvalidAPIKey
+	}
+
+	return Principal{KeyID: key.ID, Subject: "", Scopes: key.Scopes}, nil
+}
+
+// lookupKey returns the stored key with id, loading the index from storage on first use.
+func (s *Service) lookupKey(ctx context.Context, id string) (shared.APIKey, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.keys == nil {
+		keys, err := s.Store.LoadAPIKeys(ctx)
+		if err != nil {
+			return shared.APIKey{}, false, fmt.Errorf("failed to load API keys: %w", err)
+		}
+
+		s.keys = make(map[string]shared.APIKey, len(keys))
+		for _, key := range keys {
+			s.keys[key.ID] = key
+		}
+	}
+
+	key, ok := s.keys[id]
+
+	return key, ok, nil
+}
+
+// credentialFromRequest returns the X-API-Key header or the bearer token.
+func credentialFromRequest(r *http.Request) string {
+	return Credential(r.Header.Get(APIKeyHeader), r.Header.Get("Authorization"))
+}
+
+// Credential picks the API key header if set, otherwise the bearer token of authorization.
+func Credential(apiKey, authorization string) string {
+	if apiKey != "" {
+		return apiKey
+	}
+
+	token, _ := strings.CutPrefix(authorization, "Bearer ")
+
+	return token
+}
+
+// hashSecret returns the hex SHA-256 of an API key secret. Keys are random, so a fast hash is enough.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// unauthorized writes a 401 with a bearer challenge.
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
@@ -0,0 +1,181 @@
+package auth_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/auth"
+	"github.com/codyonesock/rest_weather/internal/shared"
+)
+
+type MockKeyStore struct {
+	keys  []shared.APIKey
+	loads int
+}
+
+func (m *MockKeyStore) LoadAPIKeys(_ context.Context) ([]shared.APIKey, error) {
+	m.loads++
+	return append([]shared.APIKey(nil), m.keys...), nil
+}
+
+func (m *MockKeyStore) SaveAPIKeys(_ context.Context, keys []shared.APIKey) error {
+	m.keys = keys
+	return nil
+}
+
+func setupAuthService(required bool) (*auth.Service, *MockKeyStore) {
+	store := &MockKeyStore{keys: nil, loads: 0}
+	return auth.NewAuthService(zap.NewNop(), store, "admin-secret", required), store
+}
+
+// protected returns a handler that requires scope behind Authenticate.
+func protected(authService *auth.Service, scope string) http.Handler {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return authService.Authenticate(auth.RequireScope(scope)(ok))
+}
+
+func createKey(t *testing.T, authService *auth.Service, scopes ...string) auth.CreateKeyResponse {
+	t.Helper()
+
+	body, _ := json.Marshal(auth.CreateKeyRequest{Name: "kiosk", Scopes: scopes})
+	rec := httptest.NewRecorder()
+
+	if err := authService.CreateAPIKey(rec, httptest.NewRequest(http.MethodPost, "/admin/keys", bytes.NewReader(body))); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var response auth.CreateKeyResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	return response
+}
+
+func serve(handler http.Handler, header, value string) int {
+	req := httptest.NewRequest(http.MethodGet, "/weather/halifax", nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec.Code
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	t.Parallel()
+
+	authService, store := setupAuthService(true)
+	created := createKey(t, authService, auth.ScopeReadWeather)
+
+	if store.keys[0].Hash == "" || bytes.Contains([]byte(store.keys[0].Hash), []byte(created.Key)) {
+		t.Errorf("expected only a hash of the key to be stored, got %+v", store.keys[0])
+	}
+
+	if code := serve(protected(authService, auth.ScopeReadWeather), auth.APIKeyHeader, created.Key); code != http.StatusNoContent {
+		t.Errorf("expected read scope to pass, got %d", code)
+	}
+
+	if code := serve(protected(authService, auth.ScopeWriteUser), "Authorization", "Bearer "+created.Key); code != http.StatusForbidden {
+		t.Errorf("expected missing write scope to be forbidden, got %d", code)
+	}
+
+	if code := serve(protected(authService, auth.ScopeReadWeather), auth.APIKeyHeader, created.Key+"x"); code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong secret to be unauthorized, got %d", code)
+	}
+}
+
+func TestRevokedKeyIsRejected(t *testing.T) {
+	t.Parallel()
+
+	authService, _ := setupAuthService(true)
+	created := createKey(t, authService, auth.ScopeReadWeather)
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/keys/"+created.ID, nil)
+	if err := authService.RevokeAPIKey(httptest.NewRecorder(), req, created.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if code := serve(protected(authService, auth.ScopeReadWeather), auth.APIKeyHeader, created.Key); code != http.StatusUnauthorized {
+		t.Errorf("expected a revoked key to be unauthorized, got %d", code)
+	}
+}
+
+func TestKeysAreLoadedOncePerChange(t *testing.T) {
+	t.Parallel()
+
+	authService, store := setupAuthService(true)
+	created := createKey(t, authService, auth.ScopeReadWeather)
+	handler := protected(authService, auth.ScopeReadWeather)
+
+	serve(handler, auth.APIKeyHeader, created.Key)
+	loads := store.loads
+
+	for range 3 {
+		if code := serve(handler, auth.APIKeyHeader, created.Key); code != http.StatusNoContent {
+			t.Errorf("expected the key to pass, got %d", code)
+		}
+	}
+
+	if store.loads != loads {
+		t.Errorf("expected keys to be cached after %d loads, got %d", loads, store.loads)
+	}
+
+	other := createKey(t, authService, auth.ScopeReadWeather)
+	if code := serve(handler, auth.APIKeyHeader, other.Key); code != http.StatusNoContent {
+		t.Errorf("expected a key created after caching to pass, got %d", code)
+	}
+}
+
+func TestAdminTokenAndAnonymousAccess(t *testing.T) {
+	t.Parallel()
+
+	required, _ := setupAuthService(true)
+	optional, _ := setupAuthService(false)
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		value   string
+		code    int
+	}{
+		{"admin token", protected(required, auth.ScopeAdmin), "Bearer admin-secret", http.StatusNoContent},
+		{"required without key", protected(required, auth.ScopeReadWeather), "", http.StatusUnauthorized},
+		{"optional read without key", protected(optional, auth.ScopeReadWeather), "", http.StatusNoContent},
+		{"optional write without key", protected(optional, auth.ScopeWriteUser), "", http.StatusUnauthorized},
+		{"optional admin without key", protected(optional, auth.ScopeAdmin), "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		header := ""
+		if tt.value != "" {
+			header = "Authorization"
+		}
+
+		if code := serve(tt.handler, header, tt.value); code != tt.code {
+			t.Errorf("%s: expected status code %d, got %d", tt.name, tt.code, code)
+		}
+	}
+}
+
+func TestCreateAPIKeyRejectsUnknownScope(t *testing.T) {
+	t.Parallel()
+
+	authService, _ := setupAuthService(true)
+
+	body := bytes.NewBufferString(`{"name": "kiosk", "scopes": ["root"]}`)
+	if err := authService.CreateAPIKey(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/admin/keys", body)); err == nil {
+		t.Error("expected an error for an unknown scope")
+	}
+}
@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	jwksFetchTimeout = 5 * time.Second
+	// minJWKSRefresh limits refetches triggered by unknown key IDs.
+	minJWKSRefresh = 30 * time.Second
+	clockLeeway    = time.Minute
+)
+
+// err113 demands no dynamic errors!
+var (
+	ErrInvalidToken    = errors.New("invalid token")
+	ErrUnknownKey      = errors.New("unknown signing key")
+	ErrSubjectRequired = errors.New("token has no subject")
+	ErrJWKSStatus      = errors.New("unexpected JWKS status")
+)
+
+// signatureAlgorithms are the algorithms accepted for bearer tokens.
+var signatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// Claims are the JWT claims used to identify a user.
+type Claims struct {
+	jwt.Claims
+
+	Scope string `json:"scope,omitempty"`
+}
+
+// JWTVerifier validates bearer tokens against a JWKS loaded from a file or URL.
+type JWTVerifier struct {
+	mu              sync.Mutex
+	JWKSSource      string
+	Issuer          string
+	Audience        string
+	RefreshInterval time.Duration
+	Client          *http.Client
+	keys            jose.JSONWebKeySet
+	fetchedAt       time.Time
+}
+
+// NewJWTVerifier creates a new instance of JWTVerifier.
+// jwksSource is an http(s) URL or a file path; issuer and audience are checked when set.
+func NewJWTVerifier(jwksSource, issuer, audience string, refreshInterval time.Duration) *JWTVerifier {
+	return &JWTVerifier{
+		mu:              sync.Mutex{},
+		JWKSSource:      jwksSource,
+		Issuer:          issuer,
+		Audience:        audience,
+		RefreshInterval: refreshInterval,
+		Client:          &http.Client{Timeout: jwksFetchTimeout}, //nolint:exhaustruct // defaults
+		keys:            jose.JSONWebKeySet{Keys: nil},
+		fetchedAt:       time.Time{},
+	}
+}
+
+// LooksLikeJWT reports whether a bearer credential is a compact JWT rather than an API key.
+func LooksLikeJWT(credential string) bool {
+	return strings.Count(credential, ".") == 2 //nolint:mnd // header.payload.signature
+}
+
+// Verify checks the signature and registered claims of token and returns its claims.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parsed, err := jwt.ParseSigned(token, signatureAlgorithms)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	if len(parsed.Headers) == 0 {
+		return Claims{}, ErrInvalidToken
+	}
+
+	key, err := v.key(ctx, parsed.Headers[0].KeyID)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	var claims Claims
+	if err := parsed.Claims(key.Key, &claims); err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	expected := jwt.Expected{Issuer: v.Issuer, Time: time.Now()} //nolint:exhaustruct // only checked claims
+	if v.Audience != "" {
+		expected.AnyAudience = jwt.Audience{v.Audience}
+	}
+
+	if err := claims.ValidateWithLeeway(expected, clockLeeway); err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	if claims.Expiry == nil {
+		return Claims{}, fmt.Errorf("%w: missing exp", ErrInvalidToken)
+	}
+
+	if claims.Subject == "" {
+		return Claims{}, ErrSubjectRequired
+	}
+
+	return claims, nil
+}
+
+// Scopes returns the valid scopes in the scope claim, or the default user scopes if there is none.
+func (c Claims) Scopes() []string {
+	if c.Scope == "" {
+		return tokenScopes
+	}
+
+	var scopes []string
+
+	for _, scope := range strings.Fields(c.Scope) {
+		if slices.Contains(validScopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes
+}
+
+// key returns the JWKS key for kid, refetching the set when it's stale or the kid is unknown.
+func (v *JWTVerifier) key(ctx context.Context, kid string) (jose.JSONWebKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	age := time.Since(v.fetchedAt)
+	stale := v.fetchedAt.IsZero() || (v.RefreshInterval > 0 && age > v.RefreshInterval)
+
+	if stale {
+		if err := v.refresh(ctx); err != nil {
+			return jose.JSONWebKey{}, err
+		}
+	}
+
+	if key, ok := v.lookup(kid); ok {
+		return key, nil
+	}
+
+	// The issuer may have rotated keys, refetch at most every minJWKSRefresh.
+	if !stale && age > minJWKSRefresh {
+		if err := v.refresh(ctx); err != nil {
+			return jose.JSONWebKey{}, err
+		}
+
+		if key, ok := v.lookup(kid); ok {
+			return key, nil
+		}
+	}
+
+	return jose.JSONWebKey{}, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+}
+
+// lookup finds a signing key by kid. An empty kid matches when there is a single key.
+func (v *JWTVerifier) lookup(kid string) (jose.JSONWebKey, bool) {
+	if kid == "" && len(v.keys.Keys) == 1 {
+		return v.keys.Keys[0], true
+	}
+
+	for _, key := range v.keys.Key(kid) {
+		if key.Use == "" || key.Use == "sig" {
+			return key, true
+		}
+	}
+
+	return jose.JSONWebKey{}, false
+}
+
+// refresh loads the key set from the JWKS source. Callers hold v.mu.
+func (v *JWTVerifier) refresh(ctx context.Context) error {
+	data, err := v.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	v.keys = keys
+	v.fetchedAt = time.Now()
+
+	return nil
+}
+
+// fetch reads the raw JWKS from a URL or file.
+func (v *JWTVerifier) fetch(ctx context.Context) ([]byte, error) {
+	parsedURL, err := url.Parse(v.JWKSSource)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		data, err := os.ReadFile(strings.TrimPrefix(v.JWKSSource, "file://"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+		}
+
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.JWKSSource, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+
+	res, err := v.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %d", ErrJWKSStatus, res.StatusCode)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,161 @@
+package auth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+
+	"github.com/codyonesock/rest_weather/internal/auth"
+	"github.com/codyonesock/rest_weather/internal/shared"
+)
+
+const (
+	testIssuer   = "https://sso.example.com"
+	testAudience = "rest_weather"
+)
+
+type testIssuerKeys struct {
+	signer jose.Signer
+	server *httptest.Server
+}
+
+// setupJWKS generates a signing key and serves its public half from an httptest JWKS server.
+func setupJWKS(t *testing.T) testIssuerKeys {
+	t.Helper()
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: privateKey.Public(), KeyID: "test-key", Algorithm: string(jose.ES256), Use: "sig"},
+	}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(server.Close)
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: privateKey, KeyID: "test-key"}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	return testIssuerKeys{signer: signer, server: server}
+}
+
+func (k testIssuerKeys) token(t *testing.T, claims auth.Claims) string {
+	t.Helper()
+
+	token, err := jwt.Signed(k.signer).Claims(claims).Serialize()
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	return token
+}
+
+func validClaims(subject string) auth.Claims {
+	now := time.Now()
+
+	return auth.Claims{
+		Claims: jwt.Claims{
+			Issuer:   testIssuer,
+			Subject:  subject,
+			Audience: jwt.Audience{testAudience},
+			Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt: jwt.NewNumericDate(now),
+		},
+	}
+}
+
+func TestBearerTokenMapsSubjectToUser(t *testing.T) {
+	t.Parallel()
+
+	keys := setupJWKS(t)
+	authService, _ := setupAuthService(true)
+	authService.JWT = auth.NewJWTVerifier(keys.server.URL, testIssuer, testAudience, time.Hour)
+
+	var userID string
+
+	handler := authService.Authenticate(auth.RequireScope(auth.ScopeWriteUser)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID = shared.UserIDFromContext(r.Context())
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	))
+
+	code := serve(handler, "Authorization", "Bearer "+keys.token(t, validClaims("alice")))
+	if code != http.StatusNoContent {
+		t.Fatalf("expected a valid token to pass, got %d", code)
+	}
+
+	if userID != "alice" {
+		t.Errorf("expected user id 'alice', got %q", userID)
+	}
+}
+
+func TestBearerTokenRejected(t *testing.T) {
+	t.Parallel()
+
+	keys := setupJWKS(t)
+	other := setupJWKS(t)
+
+	authService, _ := setupAuthService(true)
+	authService.JWT = auth.NewJWTVerifier(keys.server.URL, testIssuer, testAudience, time.Hour)
+	handler := protected(authService, auth.ScopeReadWeather)
+
+	expired := validClaims("alice")
+	expired.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+
+	wrongAudience := validClaims("alice")
+	wrongAudience.Audience = jwt.Audience{"someone-else"}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", keys.token(t, expired)},
+		{"wrong audience", keys.token(t, wrongAudience)},
+		{"unknown signer", other.token(t, validClaims("alice"))},
+		{"no subject", keys.token(t, validClaims(""))},
+	}
+
+	for _, tt := range tests {
+		if code := serve(handler, "Authorization", "Bearer "+tt.token); code != http.StatusUnauthorized {
+			t.Errorf("%s: expected status code %d, got %d", tt.name, http.StatusUnauthorized, code)
+		}
+	}
+}
+
+func TestBearerTokenScopeClaim(t *testing.T) {
+	t.Parallel()
+
+	keys := setupJWKS(t)
+	authService, _ := setupAuthService(true)
+	authService.JWT = auth.NewJWTVerifier(keys.server.URL, testIssuer, testAudience, time.Hour)
+
+	claims := validClaims("bob")
+	claims.Scope = "read:weather"
+	token := keys.token(t, claims)
+
+	if code := serve(protected(authService, auth.ScopeReadWeather), "Authorization", "Bearer "+token); code != http.StatusNoContent {
+		t.Errorf("expected read scope to pass, got %d", code)
+	}
+
+	if code := serve(protected(authService, auth.ScopeWriteUser), "Authorization", "Bearer "+token); code != http.StatusForbidden {
+		t.Errorf("expected missing write scope to be forbidden, got %d", code)
+	}
+}
@@ -0,0 +1,88 @@
+// Package cache is a small in-memory TTL cache for upstream responses.
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Recorder is notified of cache hits and misses, e.g. for metrics.
+type Recorder interface {
+	CacheHit(name string)
+	CacheMiss(name string)
+}
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// Cache stores values for a fixed TTL. A zero TTL or a nil *Cache disables caching.
+type Cache[V any] struct {
+	mu       sync.Mutex
+	Name     string
+	TTL      time.Duration
+	Recorder Recorder
+	entries  map[string]entry[V]
+}
+
+// NewCache creates a new instance of Cache.
+// The name is used to label hits and misses passed to the recorder, which may be nil.
+func NewCache[V any](name string, ttl time.Duration, recorder Recorder) *Cache[V] {
+	return &Cache[V]{
+		mu:       sync.Mutex{},
+		Name:     name,
+		TTL:      ttl,
+		Recorder: recorder,
+		entries:  map[string]entry[V]{},
+	}
+}
+
+// Get returns the value for key if it exists and hasn't expired.
+func (c *Cache[V]) Get(key string) (V, bool) {
+	if c == nil {
+		var zero V
+		return zero, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if ok && time.Now().After(e.expiresAt) {
+		delete(c.entries, key)
+
+		e, ok = entry[V]{}, false //nolint:exhaustruct // zero value
+	}
+
+	if c.Recorder != nil {
+		if ok {
+			c.Recorder.CacheHit(c.Name)
+		} else {
+			c.Recorder.CacheMiss(c.Name)
+		}
+	}
+
+	return e.value, ok
+}
+
+// Set stores value for key until the TTL expires.
+func (c *Cache[V]) Set(key string, value V) {
+	if c == nil || c.TTL <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	// Drop expired entries so the map doesn't grow forever with one-off cities.
+	for k, e := range c.entries {
+		if now.After(e.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = entry[V]{value: value, expiresAt: now.Add(c.TTL)}
+}
@@ -0,0 +1,62 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codyonesock/rest_weather/internal/cache"
+)
+
+type countingRecorder struct {
+	hits   int
+	misses int
+}
+
+func (r *countingRecorder) CacheHit(string)  { r.hits++ }
+func (r *countingRecorder) CacheMiss(string) { r.misses++ }
+
+func TestCacheGetSet(t *testing.T) {
+	t.Parallel()
+
+	recorder := &countingRecorder{hits: 0, misses: 0}
+	c := cache.NewCache[string]("test", time.Minute, recorder)
+
+	if _, ok := c.Get("halifax"); ok {
+		t.Error("expected a miss before Set")
+	}
+
+	c.Set("halifax", "sunny")
+
+	value, ok := c.Get("halifax")
+	if !ok || value != "sunny" {
+		t.Errorf("expected 'sunny', got %v (ok=%v)", value, ok)
+	}
+
+	if recorder.hits != 1 || recorder.misses != 1 {
+		t.Errorf("expected 1 hit and 1 miss, got %d hits and %d misses", recorder.hits, recorder.misses)
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	t.Parallel()
+
+	c := cache.NewCache[string]("test", time.Millisecond, nil)
+	c.Set("halifax", "sunny")
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.Get("halifax"); ok {
+		t.Error("expected entry to expire")
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	t.Parallel()
+
+	var c *cache.Cache[string]
+	c.Set("halifax", "sunny")
+
+	if _, ok := c.Get("halifax"); ok {
+		t.Error("expected a nil cache to never hit")
+	}
+}
@@ -2,27 +2,586 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"net/mail"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
+	"gopkg.in/yaml.v3"
+
+	"github.com/codyonesock/rest_weather/internal/logger"
+)
+
+const (
+	// redacted replaces secret values when the config is printed.
+	redacted               = "REDACTED"
+	defaultReloadInterval  = 5 * time.Second
+	defaultShutdownTimeout = 15 * time.Second
+	defaultGeocodeCacheTTL = 24 * time.Hour
+	defaultWeatherCacheTTL = 5 * time.Minute
+	defaultHealthCacheTTL  = 30 * time.Second
+	defaultJWKSRefresh     = time.Hour
+	defaultReadRateLimit   = 5
+	defaultReadRateBurst   = 20
+	defaultWriteRateLimit  = 1
+	defaultWriteRateBurst  = 5
+	defaultCORSMaxAge      = 10 * time.Minute
+	defaultStreamRefresh   = time.Minute
+	defaultStreamHeartbeat = 15 * time.Second
+	defaultWSMaxSubs       = 10
+	defaultWSPingInterval  = 30 * time.Second
+	defaultPrewarmInterval = 4 * time.Minute
+	defaultPrewarmJitter   = 30 * time.Second
+	defaultPrewarmWorkers  = 4
+	defaultAlertInterval   = 10 * time.Minute
+	defaultWebhookTimeout  = 10 * time.Second
+	defaultWebhookAttempts = 5
+	defaultWebhookBackoff  = 10 * time.Second
+	defaultSMTPPort        = 587
+	defaultSMTPTimeout     = 30 * time.Second
+	defaultDigestHour      = 7
+	defaultEmailAttempts   = 5
+	defaultEmailBackoff    = 30 * time.Second
+	maxPort                = 65535
+	maxHour                = 23
+)
+
+// err113 demands no dynamic errors!
+var (
+	ErrPortRequired           = errors.New("port is required")
+	ErrInvalidURL             = errors.New("invalid URL")
+	ErrInvalidShutdownTimeout = errors.New("shutdown timeout must be positive")
+	ErrInvalidStreamInterval  = errors.New("stream intervals must be positive")
+	ErrInvalidWSSubscriptions = errors.New("websocket max subscriptions must be positive")
+	ErrInvalidPrewarm         = errors.New("prewarm interval and jitter can't be negative")
+	ErrInvalidPrewarmWorkers  = errors.New("prewarm concurrency must be positive")
+	ErrPrewarmOutlivesCache   = errors.New("prewarm interval plus jitter must be below the weather cache ttl")
+	ErrInvalidAlertInterval   = errors.New("alert interval can't be negative")
+	ErrInvalidCORS            = errors.New("cors can't allow credentials for any origin")
+	ErrInvalidWebhook         = errors.New("webhook timeout, attempts and backoff must be positive")
+	ErrInvalidSMTPFrom        = errors.New("smtp from must be an email address")
+	ErrInvalidSMTPPort        = errors.New("smtp port must be between 1 and 65535")
+	ErrInvalidSMTPTLS         = errors.New("smtp tls must be starttls, tls or none")
+	ErrInvalidEmail           = errors.New("smtp timeout, email attempts and backoff must be positive")
+	ErrInvalidDigestHour      = errors.New("email digest hour must be between 0 and 23")
 )
 
 // Config is your config.
+//
+// Values are layered as defaults < config file < environment < command-line flags.
+// Fields tagged with secret:"true" are redacted by Redacted.
 type Config struct {
-	Port                  string `envconfig:"PORT" default:":8080"`
-	CurrentWeatherAPIURL  string `envconfig:"CURRENT_WEATHER_API_URL"`
-	ForecastWeatherAPIURL string `envconfig:"FORECAST_WEATHER_API_URL"`
-	GeocodeAPIURL         string `envconfig:"GEOCODE_API_URL"`
-	DatabaseURL           string `envconfig:"DATABASE_URL" default:"userdata.json"`
-	LogLevel              string `envconfig:"LOG_LEVEL" default:"INFO" `
+	Port                  string `envconfig:"PORT"                     flag:"port"                     yaml:"port"`
+	CurrentWeatherAPIURL  string `envconfig:"CURRENT_WEATHER_API_URL"  flag:"current-weather-api-url"  yaml:"current_weather_api_url"`
+	ForecastWeatherAPIURL string `envconfig:"FORECAST_WEATHER_API_URL" flag:"forecast-weather-api-url" yaml:"forecast_weather_api_url"`
+	GeocodeAPIURL         string `envconfig:"GEOCODE_API_URL"          flag:"geocode-api-url"          yaml:"geocode_api_url"`
+	DatabaseURL           string `envconfig:"DATABASE_URL"             flag:"database-url"             yaml:"database_url"`
+	LogLevel              string `envconfig:"LOG_LEVEL"                flag:"log-level"                yaml:"log_level"`
+	AdminToken            string `envconfig:"ADMIN_TOKEN"              flag:"admin-token"              yaml:"admin_token"              secret:"true"`
+	AuthRequired          bool   `envconfig:"AUTH_REQUIRED"            flag:"auth-required"            yaml:"auth_required"`
+	JWKSURL               string `envconfig:"JWKS_URL"                 flag:"jwks-url"                 yaml:"jwks_url"`
+	JWTIssuer             string `envconfig:"JWT_ISSUER"               flag:"jwt-issuer"               yaml:"jwt_issuer"`
+	JWTAudience           string `envconfig:"JWT_AUDIENCE"             flag:"jwt-audience"             yaml:"jwt_audience"`
+
+	// ConfigFile is the file the config was loaded from, if any.
+	ConfigFile           string        `ignored:"true"                       yaml:"config_file,omitempty"`
+	ConfigReloadInterval time.Duration `envconfig:"CONFIG_RELOAD_INTERVAL" flag:"config-reload-interval" yaml:"config_reload_interval"`
+	ShutdownTimeout      time.Duration `envconfig:"SHUTDOWN_TIMEOUT"       flag:"shutdown-timeout"       yaml:"shutdown_timeout"`
+	GeocodeCacheTTL      time.Duration `envconfig:"GEOCODE_CACHE_TTL"      flag:"geocode-cache-ttl"      yaml:"geocode_cache_ttl"`
+	WeatherCacheTTL      time.Duration `envconfig:"WEATHER_CACHE_TTL"      flag:"weather-cache-ttl"      yaml:"weather_cache_ttl"`
+	TracingExporter      string        `envconfig:"TRACING_EXPORTER"       flag:"tracing-exporter"       yaml:"tracing_exporter"`
+	TracingEndpoint      string        `envconfig:"TRACING_ENDPOINT"       flag:"tracing-endpoint"       yaml:"tracing_endpoint"`
+	HealthCacheTTL       time.Duration `envconfig:"HEALTH_CACHE_TTL"       flag:"health-cache-ttl"       yaml:"health_cache_ttl"`
+	JWKSRefreshInterval  time.Duration `envconfig:"JWKS_REFRESH_INTERVAL"  flag:"jwks-refresh-interval"  yaml:"jwks_refresh_interval"`
+
+	// Rate limits are requests per second with a burst, per user, API key or client IP. 0 disables them.
+	ReadRateLimit  float64 `envconfig:"READ_RATE_LIMIT"  flag:"read-rate-limit"  yaml:"read_rate_limit"`
+	ReadRateBurst  int     `envconfig:"READ_RATE_BURST"  flag:"read-rate-burst"  yaml:"read_rate_burst"`
+	WriteRateLimit float64 `envconfig:"WRITE_RATE_LIMIT" flag:"write-rate-limit" yaml:"write_rate_limit"`
+	WriteRateBurst int     `envconfig:"WRITE_RATE_BURST" flag:"write-rate-burst" yaml:"write_rate_burst"`
+
+	// CORS is disabled unless CORSAllowedOrigins is set; "*" allows any origin.
+	CORSAllowedOrigins   []string      `envconfig:"CORS_ALLOWED_ORIGINS"   flag:"cors-allowed-origins"   yaml:"cors_allowed_origins"`
+	CORSAllowedMethods   []string      `envconfig:"CORS_ALLOWED_METHODS"   flag:"cors-allowed-methods"   yaml:"cors_allowed_methods"`
+	CORSAllowedHeaders   []string      `envconfig:"CORS_ALLOWED_HEADERS"   flag:"cors-allowed-headers"   yaml:"cors_allowed_headers"`
+	CORSAllowCredentials bool          `envconfig:"CORS_ALLOW_CREDENTIALS" flag:"cors-allow-credentials" yaml:"cors_allow_credentials"`
+	CORSMaxAge           time.Duration `envconfig:"CORS_MAX_AGE"           flag:"cors-max-age"           yaml:"cors_max_age"`
+
+	// Streamed cities are refreshed every StreamRefreshInterval; idle streams get a heartbeat.
+	StreamRefreshInterval   time.Duration `envconfig:"STREAM_REFRESH_INTERVAL"   flag:"stream-refresh-interval"   yaml:"stream_refresh_interval"`
+	StreamHeartbeatInterval time.Duration `envconfig:"STREAM_HEARTBEAT_INTERVAL" flag:"stream-heartbeat-interval" yaml:"stream_heartbeat_interval"`
+
+	// WebSocket connections follow at most WSMaxSubscriptions cities and are pinged every WSPingInterval.
+	WSMaxSubscriptions int           `envconfig:"WS_MAX_SUBSCRIPTIONS" flag:"ws-max-subscriptions" yaml:"ws_max_subscriptions"`
+	WSPingInterval     time.Duration `envconfig:"WS_PING_INTERVAL"     flag:"ws-ping-interval"     yaml:"ws_ping_interval"`
+
+	// GRPCPort is where the gRPC API listens; empty disables it.
+	GRPCPort string `envconfig:"GRPC_PORT" flag:"grpc-port" yaml:"grpc_port"`
+
+	// Saved cities are refreshed into the weather cache every PrewarmInterval plus up to
+	// PrewarmJitter, PrewarmConcurrency at a time. A zero interval disables it.
+	PrewarmInterval    time.Duration `envconfig:"PREWARM_INTERVAL"    flag:"prewarm-interval"    yaml:"prewarm_interval"`
+	PrewarmJitter      time.Duration `envconfig:"PREWARM_JITTER"      flag:"prewarm-jitter"      yaml:"prewarm_jitter"`
+	PrewarmConcurrency int           `envconfig:"PREWARM_CONCURRENCY" flag:"prewarm-concurrency" yaml:"prewarm_concurrency"`
+
+	// AlertInterval is how often alert rules are checked; zero disables it.
+	AlertInterval time.Duration `envconfig:"ALERT_INTERVAL" flag:"alert-interval" yaml:"alert_interval"`
+
+	// Webhook requests time out after WebhookTimeout. Failed deliveries are attempted up to
+	// WebhookMaxAttempts times, waiting WebhookBackoff and then twice as long each time.
+	WebhookTimeout     time.Duration `envconfig:"WEBHOOK_TIMEOUT"      flag:"webhook-timeout"      yaml:"webhook_timeout"`
+	WebhookMaxAttempts int           `envconfig:"WEBHOOK_MAX_ATTEMPTS" flag:"webhook-max-attempts" yaml:"webhook_max_attempts"`
+	WebhookBackoff     time.Duration `envconfig:"WEBHOOK_BACKOFF"      flag:"webhook-backoff"      yaml:"webhook_backoff"`
+	// WebhookAllowPrivate lets webhooks point at loopback, private and link-local addresses.
+	WebhookAllowPrivate bool `envconfig:"WEBHOOK_ALLOW_PRIVATE" flag:"webhook-allow-private" yaml:"webhook_allow_private"`
+
+	// SMTPHost is the server alert and digest emails are sent through; empty disables email.
+	// SMTPTLS is starttls, tls (implicit, usually port 465) or none.
+	SMTPHost     string        `envconfig:"SMTP_HOST"     flag:"smtp-host"     yaml:"smtp_host"`
+	SMTPPort     int           `envconfig:"SMTP_PORT"     flag:"smtp-port"     yaml:"smtp_port"`
+	SMTPUsername string        `envconfig:"SMTP_USERNAME" flag:"smtp-username" yaml:"smtp_username"`
+	SMTPPassword string        `envconfig:"SMTP_PASSWORD" flag:"smtp-password" yaml:"smtp_password" secret:"true"`
+	SMTPFrom     string        `envconfig:"SMTP_FROM"     flag:"smtp-from"     yaml:"smtp_from"`
+	SMTPTLS      string        `envconfig:"SMTP_TLS"      flag:"smtp-tls"      yaml:"smtp_tls"`
+	SMTPTimeout  time.Duration `envconfig:"SMTP_TIMEOUT"  flag:"smtp-timeout"  yaml:"smtp_timeout"`
+
+	// Digests are sent daily from EmailDigestHour, UTC. Failed emails are attempted up to
+	// EmailMaxAttempts times, waiting EmailBackoff and then twice as long each time.
+	EmailDigestHour  int           `envconfig:"EMAIL_DIGEST_HOUR"  flag:"email-digest-hour"  yaml:"email_digest_hour"`
+	EmailMaxAttempts int           `envconfig:"EMAIL_MAX_ATTEMPTS" flag:"email-max-attempts" yaml:"email_max_attempts"`
+	EmailBackoff     time.Duration `envconfig:"EMAIL_BACKOFF"      flag:"email-backoff"      yaml:"email_backoff"`
+}
+
+// Default returns the config used when nothing else is set.
+func Default() Config {
+	return Config{
+		Port:                    ":8080",
+		CurrentWeatherAPIURL:    "",
+		ForecastWeatherAPIURL:   "",
+		GeocodeAPIURL:           "",
+		DatabaseURL:             "userdata.json",
+		LogLevel:                "INFO",
+		AdminToken:              "",
+		AuthRequired:            false,
+		JWKSURL:                 "",
+		JWTIssuer:               "",
+		JWTAudience:             "",
+		ConfigFile:              "",
+		ConfigReloadInterval:    defaultReloadInterval,
+		ShutdownTimeout:         defaultShutdownTimeout,
+		GeocodeCacheTTL:         defaultGeocodeCacheTTL,
+		WeatherCacheTTL:         defaultWeatherCacheTTL,
+		TracingExporter:         "none",
+		TracingEndpoint:         "",
+		HealthCacheTTL:          defaultHealthCacheTTL,
+		JWKSRefreshInterval:     defaultJWKSRefresh,
+		ReadRateLimit:           defaultReadRateLimit,
+		ReadRateBurst:           defaultReadRateBurst,
+		WriteRateLimit:          defaultWriteRateLimit,
+		WriteRateBurst:          defaultWriteRateBurst,
+		CORSAllowedOrigins:      []string{},
+		CORSAllowedMethods:      []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		CORSAllowedHeaders:      []string{"Accept", "Authorization", "Content-Type", "X-API-Key", "X-Request-ID"},
+		CORSAllowCredentials:    false,
+		CORSMaxAge:              defaultCORSMaxAge,
+		StreamRefreshInterval:   defaultStreamRefresh,
+		StreamHeartbeatInterval: defaultStreamHeartbeat,
+		WSMaxSubscriptions:      defaultWSMaxSubs,
+		WSPingInterval:          defaultWSPingInterval,
+		GRPCPort:                ":9090",
+		PrewarmInterval:         defaultPrewarmInterval,
+		PrewarmJitter:           defaultPrewarmJitter,
+		PrewarmConcurrency:      defaultPrewarmWorkers,
+		AlertInterval:           defaultAlertInterval,
+		WebhookTimeout:          defaultWebhookTimeout,
+		WebhookMaxAttempts:      defaultWebhookAttempts,
+		WebhookBackoff:          defaultWebhookBackoff,
+		WebhookAllowPrivate:     false,
+		SMTPHost:                "",
+		SMTPPort:                defaultSMTPPort,
+		SMTPUsername:            "",
+		SMTPPassword:            "",
+		SMTPFrom:                "",
+		SMTPTLS:                 "starttls",
+		SMTPTimeout:             defaultSMTPTimeout,
+		EmailDigestHour:         defaultDigestHour,
+		EmailMaxAttempts:        defaultEmailAttempts,
+		EmailBackoff:            defaultEmailBackoff,
+	}
 }
 
-// LoadConfig loads the application config.
+// LoadConfig loads the application config from os.Args.
 func LoadConfig() (*Config, error) {
-	var cfg Config
+	return Load(os.Args[1:])
+}
+
+// Load builds the config from the defaults, the config file, the environment and args.
+// The config file path comes from the -config flag or the CONFIG_FILE env var.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("weatherApp", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	flagValues := registerFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("error parsing flags: %w", err)
+	}
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := envconfig.Process("", &cfg); err != nil {
 		return nil, fmt.Errorf("error loading environment variables: %w", err)
 	}
 
+	if err := applyFlags(fs, flagValues, &cfg); err != nil {
+		return nil, err
+	}
+
+	cfg.ConfigFile = *configFile
+
 	return &cfg, nil
 }
+
+// loadFile decodes a YAML config file over cfg. Unknown keys are rejected so typos don't
+// silently fall back to the defaults.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error decoding config file: %w", err)
+	}
+
+	return nil
+}
+
+// registerFlags adds a string flag for every Config field with a flag tag.
+func registerFlags(fs *flag.FlagSet) map[string]*string {
+	values := map[string]*string{}
+	t := reflect.TypeFor[Config]()
+
+	for i := range t.NumField() {
+		name := t.Field(i).Tag.Get("flag")
+		if name == "" {
+			continue
+		}
+
+		values[name] = fs.String(name, "", "overrides "+t.Field(i).Tag.Get("envconfig"))
+	}
+
+	return values
+}
+
+// applyFlags copies only the flags that were explicitly passed onto cfg.
+func applyFlags(fs *flag.FlagSet, values map[string]*string, cfg *Config) error {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+
+	var err error
+
+	fs.Visit(func(f *flag.Flag) {
+		for i := range t.NumField() {
+			if t.Field(i).Tag.Get("flag") != f.Name || err != nil {
+				continue
+			}
+
+			if setErr := setField(v.Field(i), *values[f.Name]); setErr != nil {
+				err = fmt.Errorf("error parsing flag -%s: %w", f.Name, setErr)
+			}
+		}
+	})
+
+	return err
+}
+
+// setField parses a flag value into a config field.
+func setField(field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration: %w", err)
+		}
+
+		field.SetInt(int64(d))
+
+		return nil
+	case []string:
+		field.Set(reflect.ValueOf(splitList(value)))
+		return nil
+	}
+
+	switch field.Kind() { //nolint:exhaustive // config only uses these kinds
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid bool: %w", err)
+		}
+
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid int: %w", err)
+		}
+
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid float: %w", err)
+		}
+
+		field.SetFloat(f)
+	}
+
+	return nil
+}
+
+// splitList splits a comma separated flag value.
+func splitList(value string) []string {
+	items := []string{}
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// Redacted returns a copy of the config that is safe to print.
+func (c Config) Redacted() Config {
+	v := reflect.ValueOf(&c).Elem()
+	t := v.Type()
+
+	for i := range t.NumField() {
+		field := v.Field(i)
+		if field.Kind() != reflect.String || field.String() == "" {
+			continue
+		}
+
+		if t.Field(i).Tag.Get("secret") == "true" {
+			field.SetString(redacted)
+			continue
+		}
+
+		field.SetString(redactURL(field.String()))
+	}
+
+	return c
+}
+
+// redactURL hides the password of a URL with credentials, e.g. a database DSN.
+func redactURL(raw string) string {
+	parsedURL, err := url.Parse(raw)
+	if err != nil || parsedURL.User == nil {
+		return raw
+	}
+
+	if _, ok := parsedURL.User.Password(); !ok {
+		return raw
+	}
+
+	parsedURL.User = url.UserPassword(parsedURL.User.Username(), redacted)
+
+	return parsedURL.String()
+}
+
+// Validate checks that the config can be used to run the server.
+func (c Config) Validate() error {
+	if c.Port == "" {
+		return ErrPortRequired
+	}
+
+	for _, rawURL := range []string{c.CurrentWeatherAPIURL, c.ForecastWeatherAPIURL, c.GeocodeAPIURL} {
+		if rawURL == "" {
+			continue
+		}
+
+		parsedURL, err := url.Parse(rawURL)
+		if err != nil || parsedURL.Scheme != "https" || parsedURL.Host == "" {
+			return fmt.Errorf("%w: %s", ErrInvalidURL, rawURL)
+		}
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		return ErrInvalidShutdownTimeout
+	}
+
+	if c.StreamRefreshInterval <= 0 || c.StreamHeartbeatInterval <= 0 || c.WSPingInterval <= 0 {
+		return ErrInvalidStreamInterval
+	}
+
+	if c.WSMaxSubscriptions <= 0 {
+		return ErrInvalidWSSubscriptions
+	}
+
+	// Any origin with credentials would let every site make authenticated requests.
+	if c.CORSAllowCredentials && slices.Contains(c.CORSAllowedOrigins, "*") {
+		return ErrInvalidCORS
+	}
+
+	if c.PrewarmInterval < 0 || c.PrewarmJitter < 0 {
+		return ErrInvalidPrewarm
+	}
+
+	if c.PrewarmConcurrency <= 0 {
+		return ErrInvalidPrewarmWorkers
+	}
+
+	// Otherwise cached entries expire before they're refreshed, or aren't cached at all.
+	if c.PrewarmInterval > 0 && c.PrewarmInterval+c.PrewarmJitter >= c.WeatherCacheTTL {
+		return fmt.Errorf("%w: %s plus %s, ttl %s",
+			ErrPrewarmOutlivesCache, c.PrewarmInterval, c.PrewarmJitter, c.WeatherCacheTTL)
+	}
+
+	if c.AlertInterval < 0 {
+		return ErrInvalidAlertInterval
+	}
+
+	if c.WebhookTimeout <= 0 || c.WebhookMaxAttempts <= 0 || c.WebhookBackoff <= 0 {
+		return ErrInvalidWebhook
+	}
+
+	if err := c.validateEmail(); err != nil {
+		return err
+	}
+
+	if _, err := logger.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	return nil
+}
+
+// validateEmail checks the SMTP settings, which only matter when SMTPHost is set.
+func (c Config) validateEmail() error {
+	if c.SMTPHost == "" {
+		return nil
+	}
+
+	if from, err := mail.ParseAddress(c.SMTPFrom); err != nil || from.Address == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidSMTPFrom, c.SMTPFrom)
+	}
+
+	if c.SMTPPort <= 0 || c.SMTPPort > maxPort {
+		return ErrInvalidSMTPPort
+	}
+
+	switch c.SMTPTLS {
+	case "starttls", "tls", "none":
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidSMTPTLS, c.SMTPTLS)
+	}
+
+	if c.SMTPTimeout <= 0 || c.EmailMaxAttempts <= 0 || c.EmailBackoff <= 0 {
+		return ErrInvalidEmail
+	}
+
+	if c.EmailDigestHour < 0 || c.EmailDigestHour > maxHour {
+		return ErrInvalidDigestHour
+	}
+
+	return nil
+}
+
+// Change is a single field that differs between two configs.
+type Change struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Changes lists the fields that differ between c and other, with secrets redacted.
+func (c Config) Changes(other Config) []Change {
+	oldValue := reflect.ValueOf(c.Redacted())
+	newValue := reflect.ValueOf(other.Redacted())
+	t := oldValue.Type()
+
+	var changes []Change
+
+	for i := range t.NumField() {
+		if reflect.DeepEqual(reflect.ValueOf(c).Field(i).Interface(), reflect.ValueOf(other).Field(i).Interface()) {
+			continue
+		}
+
+		changes = append(changes, Change{
+			Field: t.Field(i).Name,
+			Old:   fmt.Sprint(oldValue.Field(i).Interface()),
+			New:   fmt.Sprint(newValue.Field(i).Interface()),
+		})
+	}
+
+	return changes
+}
+
+// Print writes the redacted config as YAML.
+func (c Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	if err := enc.Encode(c.Redacted().yamlNode()); err != nil {
+		return fmt.Errorf("error encoding config: %w", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("error encoding config: %w", err)
+	}
+
+	return nil
+}
+
+// yamlNode builds a YAML mapping of the config so durations print as "5s" rather than nanoseconds.
+func (c Config) yamlNode() *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode} //nolint:exhaustruct // only Kind is needed
+	v := reflect.ValueOf(c)
+	t := v.Type()
+
+	for i := range t.NumField() {
+		name, opts, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == "-" || (opts == "omitempty" && v.Field(i).IsZero()) {
+			continue
+		}
+
+		value := v.Field(i).Interface()
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+
+		var valueNode yaml.Node
+		if err := valueNode.Encode(value); err != nil {
+			continue
+		}
+
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: name}, //nolint:exhaustruct // only Kind and Value are needed
+			&valueNode,
+		)
+	}
+
+	return node
+}
@@ -0,0 +1,629 @@
+// Package email sends alert and daily digest emails through SMTP to users who opted in.
+//
+// Messages are kept in storage until they're sent in the background, retrying temporary
+// failures with exponential backoff, so messages waiting at shutdown are sent on the next start.
+package email
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/alerts"
+	"github.com/codyonesock/rest_weather/internal/logger"
+	"github.com/codyonesock/rest_weather/internal/shared"
+	"github.com/codyonesock/rest_weather/internal/storage"
+	"github.com/codyonesock/rest_weather/internal/weather"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	textTemplates = template.Must(template.ParseFS(templateFS, "templates/*.txt.tmpl"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html.tmpl"))
+)
+
+const (
+	// digestDays is how many forecast days a digest shows, today included.
+	digestDays = 3
+	// digestCheck is how often Run looks for digests that are due.
+	digestCheck = time.Minute
+	// workers is how many messages are sent at once.
+	workers = 2
+	// queueSize is how many messages may wait for a worker.
+	queueSize = 256
+)
+
+// err113 demands no dynamic errors!
+var (
+	ErrInvalidBody     = errors.New("invalid request body")
+	ErrInvalidAddress  = errors.New("invalid email address")
+	ErrAddressRequired = errors.New("address is required to receive alerts or digests")
+	ErrDisabled        = errors.New("email is disabled on this server")
+)
+
+// SettingsRequest is the body to change the email settings.
+type SettingsRequest struct {
+	Address string `json:"address"`
+	Alerts  bool   `json:"alerts"`
+	Digest  bool   `json:"digest"`
+}
+
+// Service handles dependencies and config. Without a Mailer users can't opt in to emails.
+// mu guards the read-modify-write of settings and outboxes shared by requests, digests and workers.
+type Service struct {
+	mu      sync.Mutex
+	Logger  *zap.Logger
+	Weather *weather.Service
+	Store   storage.EmailStore
+	Mailer  *Mailer
+	From    *mail.Address
+
+	// Digests go out once a day from DigestHour, UTC.
+	DigestHour int
+
+	// Temporary SMTP failures are retried until a message had MaxAttempts, see shared.Backoff.
+	MaxAttempts int
+	Backoff     time.Duration
+
+	queue    chan job
+	inFlight map[string]bool
+}
+
+// job is a message waiting in the outbox of a user.
+type job struct {
+	userID    string
+	messageID string
+}
+
+// NewEmailService creates a new instance of Service sending as from through mailer, which may be nil.
+func NewEmailService(
+	l *zap.Logger,
+	weatherService *weather.Service,
+	store storage.EmailStore,
+	mailer *Mailer,
+	from *mail.Address,
+	digestHour, maxAttempts int,
+	backoff time.Duration,
+) *Service {
+	return &Service{
+		mu:          sync.Mutex{},
+		Logger:      l,
+		Weather:     weatherService,
+		Store:       store,
+		Mailer:      mailer,
+		From:        from,
+		DigestHour:  digestHour,
+		MaxAttempts: maxAttempts,
+		Backoff:     backoff,
+		queue:       make(chan job, queueSize),
+		inFlight:    map[string]bool{},
+	}
+}
+
+// GetSettings writes the email settings of the user.
+func (s *Service) GetSettings(w http.ResponseWriter, r *http.Request) error {
+	settings, err := s.load(r.Context())
+	if err != nil {
+		return err
+	}
+
+	return shared.WriteJSON(w, http.StatusOK, settings)
+}
+
+// UpdateSettings replaces the email settings of the user from the request body.
+func (s *Service) UpdateSettings(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	var req SettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBody, err)
+	}
+
+	address := strings.TrimSpace(req.Address)
+	if address != "" {
+		parsed, err := mail.ParseAddress(address)
+		if err != nil || parsed.Address != address {
+			return fmt.Errorf("%w: %q", ErrInvalidAddress, address)
+		}
+	} else if req.Alerts || req.Digest {
+		return ErrAddressRequired
+	}
+
+	if s.Mailer == nil && (req.Alerts || req.Digest) {
+		return ErrDisabled
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	settings.Address, settings.Alerts, settings.Digest = address, req.Alerts, req.Digest
+	if err := s.save(ctx, settings); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx, s.Logger).Info("Email settings updated", zap.Bool("alerts", req.Alerts), zap.Bool("digest", req.Digest))
+
+	return shared.WriteJSON(w, http.StatusOK, settings)
+}
+
+// NotifyAlert emails event to the user in ctx if they want alert emails.
+func (s *Service) NotifyAlert(ctx context.Context, event shared.AlertEvent) {
+	log := logger.FromContext(ctx, s.Logger)
+
+	settings, err := s.load(ctx)
+	if err != nil || !settings.Alerts || settings.Address == "" {
+		return
+	}
+
+	view := newAlertView(event)
+
+	msg, err := render(settings.Address, fmt.Sprintf("Weather alert: %s %s %s %s %s",
+		view.City, view.Metric, view.Operator, view.Threshold, view.Unit), "alert", view)
+	if err != nil {
+		log.Error("Failed to render alert email", zap.Error(err))
+		return
+	}
+
+	s.mu.Lock()
+	id, err := s.add(ctx, msg)
+	s.mu.Unlock()
+
+	if err != nil {
+		return
+	}
+
+	s.enqueue(ctx, job{userID: shared.UserIDFromContext(ctx), messageID: id})
+}
+
+// Run sends queued messages and the daily digests until ctx is done.
+func (s *Service) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for range workers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+
+	s.resume(ctx)
+
+	ticker := time.NewTicker(digestCheck)
+	defer ticker.Stop()
+
+	for {
+		s.SendDigests(ctx, time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDigests queues the digest of every user who wants one and hasn't had it on now's date,
+// once now is past DigestHour. A digest counts as sent once it's in the outbox.
+func (s *Service) SendDigests(ctx context.Context, now time.Time) {
+	if now.Hour() < s.DigestHour {
+		return
+	}
+
+	users, err := s.Store.EmailUsers(ctx)
+	if err != nil {
+		s.Logger.Error("Failed to list email users", zap.Error(err))
+		return
+	}
+
+	today := now.Format(time.DateOnly)
+
+	// Users saving the same city share its lookups.
+	ctx = weather.WithBatch(ctx)
+
+	for _, userID := range users {
+		userCtx := shared.WithUserID(ctx, userID)
+		log := s.Logger.With(zap.String("user", userID))
+
+		settings, err := s.load(userCtx)
+		if err != nil || !settings.Digest || settings.LastDigest == today {
+			continue
+		}
+
+		reports, err := s.Weather.SavedCityReports(userCtx)
+		if err != nil {
+			log.Error("Failed to fetch digest weather", zap.Error(err))
+			continue
+		}
+
+		if len(reports) == 0 {
+			continue
+		}
+
+		for _, report := range reports {
+			if report.Forecast != nil && len(report.Forecast.Days) > digestDays {
+				report.Forecast.Days = report.Forecast.Days[:digestDays]
+			}
+		}
+
+		msg, err := render(settings.Address, "Your weather for "+today, "digest", digestView{Date: today, Cities: reports})
+		if err != nil {
+			log.Error("Failed to render digest email", zap.Error(err))
+			continue
+		}
+
+		id, ok := s.addDigest(userCtx, today, msg)
+		if !ok {
+			continue
+		}
+
+		s.enqueue(ctx, job{userID: userID, messageID: id})
+	}
+}
+
+// addDigest puts the digest of today in the outbox and then records it as sent, returning
+// the message ID and false if it already was sent.
+func (s *Service) addDigest(ctx context.Context, today string, msg Message) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings, err := s.load(ctx)
+	if err != nil || settings.LastDigest == today {
+		return "", false
+	}
+
+	id, err := s.add(ctx, msg)
+	if err != nil {
+		return "", false
+	}
+
+	// Saved or not, the digest is in the outbox now.
+	settings.LastDigest = today
+	_ = s.save(ctx, settings)
+
+	return id, true
+}
+
+// add puts msg in the outbox of the user in ctx and returns its ID. mu must be held.
+func (s *Service) add(ctx context.Context, msg Message) (string, error) {
+	outbox, err := s.loadOutbox(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	id := shared.NewID()
+	outbox = append(outbox, shared.EmailMessage{
+		ID:            id,
+		To:            msg.To,
+		Subject:       msg.Subject,
+		Text:          msg.Text,
+		HTML:          msg.HTML,
+		Attempts:      0,
+		Error:         "",
+		CreatedAt:     time.Now().UTC(),
+		NextAttemptAt: nil,
+	})
+
+	if err := s.saveOutbox(ctx, outbox); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// resume queues the messages left in every outbox, those waiting for a retry at their next attempt.
+func (s *Service) resume(ctx context.Context) {
+	users, err := s.Store.EmailUsers(ctx)
+	if err != nil {
+		s.Logger.Error("Failed to list email users", zap.Error(err))
+		return
+	}
+
+	for _, userID := range users {
+		outbox, err := s.loadOutbox(shared.WithUserID(ctx, userID))
+		if err != nil {
+			continue
+		}
+
+		for _, msg := range outbox {
+			j := job{userID: userID, messageID: msg.ID}
+			if msg.NextAttemptAt != nil {
+				s.schedule(ctx, j, *msg.NextAttemptAt)
+			} else {
+				s.enqueue(ctx, j)
+			}
+		}
+	}
+}
+
+// enqueue hands j to the workers without waiting for one. While the queue is full, j is tried
+// again every Backoff.
+func (s *Service) enqueue(ctx context.Context, j job) {
+	select {
+	case s.queue <- j:
+	default:
+		logger.FromContext(ctx, s.Logger).Warn("Email queue is full, delaying message", zap.String("message", j.messageID))
+		s.schedule(ctx, j, time.Now().Add(s.Backoff))
+	}
+}
+
+// schedule queues j at the given time, unless ctx is done by then. The message stays in the
+// outbox meanwhile, so if the server stops first it's resumed on the next start.
+func (s *Service) schedule(ctx context.Context, j job, at time.Time) {
+	time.AfterFunc(time.Until(at), func() {
+		if ctx.Err() == nil {
+			s.enqueue(ctx, j)
+		}
+	})
+}
+
+// work sends queued messages until ctx is done.
+func (s *Service) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-s.queue:
+			s.deliver(shared.WithUserID(ctx, j.userID), j.messageID)
+		}
+	}
+}
+
+// deliver makes an attempt at the message with id. A failed attempt that may succeed later is
+// scheduled after the backoff rather than waited for, so the worker moves on.
+func (s *Service) deliver(ctx context.Context, id string) {
+	if !s.claim(id) {
+		return
+	}
+
+	next, retry := s.attempt(ctx, id)
+
+	// Released first, or a short backoff could queue the retry while it's still claimed.
+	s.release(id)
+
+	if retry {
+		s.schedule(ctx, job{userID: shared.UserIDFromContext(ctx), messageID: id}, next)
+	}
+}
+
+// attempt sends the message with id once. A sent message, or one that failed for good, leaves
+// the outbox. It returns when to attempt it again, and whether to.
+func (s *Service) attempt(ctx context.Context, id string) (time.Time, bool) {
+	stored, ok := s.lookup(ctx, id)
+
+	// A stale job, the message was sent or attempted since it was queued.
+	if !ok || stored.NextAttemptAt != nil && time.Now().Before(*stored.NextAttemptAt) {
+		return time.Time{}, false
+	}
+
+	log := logger.FromContext(ctx, s.Logger).With(zap.String("subject", stored.Subject))
+	msg := Message{To: stored.To, Subject: stored.Subject, Text: stored.Text, HTML: stored.HTML}
+
+	data, err := msg.encode(s.From, time.Now())
+	if err != nil {
+		log.Error("Failed to encode email", zap.Error(err))
+		s.record(ctx, id, nil)
+
+		return time.Time{}, false
+	}
+
+	err = s.Mailer.Send(ctx, s.From.Address, []string{msg.To}, data)
+	if ctx.Err() != nil {
+		// Shutting down; the message is attempted again on the next start.
+		return time.Time{}, false
+	}
+
+	attempts := stored.Attempts + 1
+
+	switch {
+	case err == nil:
+		log.Debug("Email sent", zap.Int("attempts", attempts))
+		s.record(ctx, id, nil)
+
+		return time.Time{}, false
+	case permanent(err) || attempts >= s.MaxAttempts:
+		log.Error("Failed to send email", zap.Int("attempts", attempts), zap.Error(err))
+		s.record(ctx, id, nil)
+
+		return time.Time{}, false
+	}
+
+	log.Warn("Failed to send email, retrying", zap.Error(err))
+
+	next := time.Now().UTC().Add(shared.Backoff(s.Backoff, attempts))
+	s.record(ctx, id, func(m *shared.EmailMessage) {
+		m.Attempts, m.Error, m.NextAttemptAt = attempts, err.Error(), &next
+	})
+
+	return next, true
+}
+
+// claim marks the message with id as being sent, returning false if it already is.
+// A message can be queued twice when it's resumed while being added.
+func (s *Service) claim(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inFlight[id] {
+		return false
+	}
+
+	s.inFlight[id] = true
+
+	return true
+}
+
+func (s *Service) release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inFlight, id)
+}
+
+// lookup returns the message with id from the outbox of the user in ctx.
+func (s *Service) lookup(ctx context.Context, id string) (shared.EmailMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	outbox, err := s.loadOutbox(ctx)
+	if err != nil {
+		return shared.EmailMessage{}, false
+	}
+
+	for _, msg := range outbox {
+		if msg.ID == id {
+			return msg, true
+		}
+	}
+
+	return shared.EmailMessage{}, false
+}
+
+// record applies fn to the message with id in the outbox, or removes it when fn is nil.
+func (s *Service) record(ctx context.Context, id string, fn func(m *shared.EmailMessage)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	outbox, err := s.loadOutbox(ctx)
+	if err != nil {
+		return
+	}
+
+	i := slices.IndexFunc(outbox, func(m shared.EmailMessage) bool { return m.ID == id })
+	if i < 0 {
+		return
+	}
+
+	if fn == nil {
+		outbox = slices.Delete(outbox, i, i+1)
+	} else {
+		fn(&outbox[i])
+	}
+
+	_ = s.saveOutbox(ctx, outbox)
+}
+
+// alertView is an alert event formatted for the templates.
+type alertView struct {
+	City      string
+	Metric    string
+	Operator  string
+	Value     string
+	Threshold string
+	Unit      string
+	Date      string
+	RuleID    string
+	FiredAt   string
+}
+
+func newAlertView(event shared.AlertEvent) alertView {
+	metric, unit := event.Metric, "°C"
+
+	switch event.Metric {
+	case alerts.MetricWindSpeed:
+		metric, unit = "wind speed", "km/h"
+	case alerts.MetricTemperatureMax:
+		metric = "the high"
+	case alerts.MetricTemperatureMin:
+		metric = "the low"
+	case alerts.MetricWindSpeedMax:
+		metric, unit = "the max wind speed", "km/h"
+	}
+
+	if event.Units == weather.UnitsImperial {
+		unit = map[string]string{"°C": "°F", "km/h": "mph"}[unit]
+	}
+
+	return alertView{
+		City:      event.City,
+		Metric:    metric,
+		Operator:  event.Operator,
+		Value:     strconv.FormatFloat(event.Value, 'f', -1, 64),
+		Threshold: strconv.FormatFloat(event.Threshold, 'f', -1, 64),
+		Unit:      unit,
+		Date:      event.Date,
+		RuleID:    event.RuleID,
+		FiredAt:   event.FiredAt.UTC().Format("2006-01-02 15:04 UTC"),
+	}
+}
+
+// digestView is the data of the digest templates.
+type digestView struct {
+	Date   string
+	Cities []weather.CityReport
+}
+
+// render returns a message to to with both bodies rendered from the name templates.
+func render(to, subject, name string, data any) (Message, error) {
+	var text, html strings.Builder
+
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt.tmpl", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render text: %w", err)
+	}
+
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html.tmpl", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render html: %w", err)
+	}
+
+	return Message{To: to, Subject: subject, Text: text.String(), HTML: html.String()}, nil
+}
+
+func (s *Service) load(ctx context.Context) (shared.EmailSettings, error) {
+	settings, err := s.Store.LoadEmailSettings(ctx)
+	if err != nil {
+		logger.FromContext(ctx, s.Logger).Error("Error loading email settings", zap.Error(err))
+		return shared.EmailSettings{}, fmt.Errorf("failed to load email settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+func (s *Service) save(ctx context.Context, settings shared.EmailSettings) error {
+	if err := s.Store.SaveEmailSettings(ctx, settings); err != nil {
+		logger.FromContext(ctx, s.Logger).Error("Error saving email settings", zap.Error(err))
+		return fmt.Errorf("failed to save email settings: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) loadOutbox(ctx context.Context) ([]shared.EmailMessage, error) {
+	outbox, err := s.Store.LoadEmailOutbox(ctx)
+	if err != nil {
+		logger.FromContext(ctx, s.Logger).Error("Error loading email outbox", zap.Error(err))
+		return nil, fmt.Errorf("failed to load email outbox: %w", err)
+	}
+
+	return outbox, nil
+}
+
+func (s *Service) saveOutbox(ctx context.Context, outbox []shared.EmailMessage) error {
+	if err := s.Store.SaveEmailOutbox(ctx, outbox); err != nil {
+		logger.FromContext(ctx, s.Logger).Error("Error saving email outbox", zap.Error(err))
+		return fmt.Errorf("failed to save email outbox: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,470 @@
+package email_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/email"
+	"github.com/codyonesock/rest_weather/internal/shared"
+	"github.com/codyonesock/rest_weather/internal/storage"
+	"github.com/codyonesock/rest_weather/internal/weather"
+	"github.com/codyonesock/rest_weather/internal/weathertest"
+)
+
+// smtpServer is a fake SMTP server recording the messages it accepts.
+// While failures is positive, MAIL commands get failCode and decrement it.
+type smtpServer struct {
+	mu       sync.Mutex
+	failures int
+	failCode int
+	attempts int
+	messages []*mail.Message
+}
+
+func (s *smtpServer) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.session(conn)
+	}
+}
+
+func (s *smtpServer) session(conn net.Conn) {
+	defer conn.Close()
+
+	tc := textproto.NewConn(conn)
+	_ = tc.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command, _, _ := strings.Cut(strings.ToUpper(line), " ")
+
+		switch command {
+		case "EHLO", "HELO", "RCPT", "RSET", "NOOP":
+			_ = tc.PrintfLine("250 OK")
+		case "MAIL":
+			_ = tc.PrintfLine("%s", s.mail())
+		case "DATA":
+			_ = tc.PrintfLine("354 Go ahead")
+
+			data, err := io.ReadAll(tc.DotReader())
+			if err != nil {
+				return
+			}
+
+			msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+			if err != nil {
+				_ = tc.PrintfLine("554 Malformed message")
+				continue
+			}
+
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+
+			_ = tc.PrintfLine("250 Queued")
+		case "QUIT":
+			_ = tc.PrintfLine("221 Bye")
+			return
+		default:
+			_ = tc.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) mail() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attempts++
+
+	if s.failures > 0 {
+		s.failures--
+		return strconv.Itoa(s.failCode) + " Try again later"
+	}
+
+	return "250 OK"
+}
+
+func (s *smtpServer) fail(code, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failCode, s.failures = code, times
+}
+
+func (s *smtpServer) received() ([]*mail.Message, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*mail.Message{}, s.messages...), s.attempts
+}
+
+// waitForMessages waits until the server accepted count messages and returns them.
+func (s *smtpServer) waitForMessages(t *testing.T, count int) []*mail.Message {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		messages, _ := s.received()
+		if len(messages) >= count {
+			return messages
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d messages, got %d", count, len(messages))
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// setupEmail returns an email service sending to a fake SMTP server, with weather from a fake
+// upstream and a storage service to seed users. pending is put in alice's outbox before the
+// service starts, and it runs until the test ends.
+func setupEmail(t *testing.T, pending ...shared.EmailMessage) (*email.Service, *smtpServer, *storage.Service) {
+	t.Helper()
+
+	upstream := weathertest.NewUpstream(t)
+	upstream.SetTemperature(11.5)
+	upstream.SetForecast(weathertest.Forecast{
+		Dates:        []string{"2026-10-18", "2026-10-19", "2026-10-20", "2026-10-21"},
+		MinTemps:     []float64{1, 2, 3, 4},
+		MaxTemps:     []float64{12, 14, 16, 18},
+		WeatherCodes: []int{61, 0, 0, 3},
+	})
+
+	weatherService, storageService := upstream.NewWeatherService(t)
+
+	if len(pending) > 0 {
+		if err := storageService.SaveEmailOutbox(shared.WithUserID(context.Background(), "alice"), pending); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	server := &smtpServer{mu: sync.Mutex{}, failures: 0, failCode: 0, attempts: 0, messages: nil}
+	go server.serve(listener)
+
+	port := listener.Addr().(*net.TCPAddr).Port //nolint:forcetypeassert // always TCP
+	mailer := email.NewMailer("127.0.0.1", port, "", "", email.TLSNone, time.Second)
+	from := &mail.Address{Name: "Weather", Address: "weather@example.com"}
+
+	service := email.NewEmailService(zap.NewNop(), weatherService, storageService, mailer, from, 7, 3, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		service.Run(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return service, server, storageService
+}
+
+func updateSettings(t *testing.T, service *email.Service, ctx context.Context, body string) shared.EmailSettings {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequestWithContext(ctx, http.MethodPut, "/user/email", strings.NewReader(body))
+
+	if err := service.UpdateSettings(rec, req); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var settings shared.EmailSettings
+	if err := json.NewDecoder(rec.Body).Decode(&settings); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	return settings
+}
+
+// bodies returns the decoded plain text and HTML parts of msg.
+func bodies(t *testing.T, msg *mail.Message) (string, string) {
+	t.Helper()
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("expected a multipart/alternative message, got %q (%v)", mediaType, err)
+	}
+
+	parts := map[string]string{}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+
+	for {
+		part, err := reader.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if part.Header.Get("Content-Transfer-Encoding") != "quoted-printable" {
+			t.Errorf("expected quoted-printable parts, got %v", part.Header)
+		}
+
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(body)
+	}
+
+	return parts["text/plain"], parts["text/html"]
+}
+
+func subject(t *testing.T, msg *mail.Message) string {
+	t.Helper()
+
+	decoded, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	return decoded
+}
+
+func TestAlertEmailIsRetried(t *testing.T) {
+	t.Parallel()
+
+	service, server, _ := setupEmail(t)
+	server.fail(451, 1)
+
+	ctx := shared.WithUserID(context.Background(), "alice")
+	updateSettings(t, service, ctx, `{"address": "alice@example.com", "alerts": true}`)
+
+	// Users without alert emails don't get any.
+	service.NotifyAlert(shared.WithUserID(context.Background(), "bob"), shared.AlertEvent{ID: "event-0"}) //nolint:exhaustruct,lll // optional fields
+
+	service.NotifyAlert(ctx, shared.AlertEvent{ //nolint:exhaustruct // optional fields
+		ID: "event-1", RuleID: "rule-1", City: "halifax", Metric: "temperature", Operator: "above",
+		Threshold: 20, Value: 25.5, Units: weather.UnitsImperial,
+	})
+
+	messages := server.waitForMessages(t, 1)
+	if _, attempts := server.received(); len(messages) != 1 || attempts != 2 {
+		t.Fatalf("expected one message sent on the second attempt, got %d in %d attempts", len(messages), attempts)
+	}
+
+	msg := messages[0]
+	if msg.Header.Get("To") != "alice@example.com" || !strings.Contains(msg.Header.Get("From"), "weather@example.com") {
+		t.Errorf("expected mail from the service to alice, got %v", msg.Header)
+	}
+
+	if got, want := subject(t, msg), "Weather alert: halifax temperature above 20 °F"; got != want {
+		t.Errorf("expected subject %q, got %q", want, got)
+	}
+
+	text, html := bodies(t, msg)
+	if !strings.Contains(text, "temperature is 25.5 °F") || !strings.Contains(text, "rule-1") {
+		t.Errorf("expected the alert in the text body, got %q", text)
+	}
+
+	if !strings.Contains(html, "25.5 °F") || !strings.Contains(html, "<html") {
+		t.Errorf("expected the alert in the HTML body, got %q", html)
+	}
+}
+
+func TestPendingEmailsAreResumed(t *testing.T) {
+	t.Parallel()
+
+	past := time.Now().Add(-time.Minute)
+	_, server, storageService := setupEmail(t,
+		shared.EmailMessage{ //nolint:exhaustruct // optional fields
+			ID: "message-1", To: "alice@example.com", Subject: "Queued", Text: "queued", HTML: "<p>queued</p>",
+		},
+		shared.EmailMessage{ //nolint:exhaustruct // optional fields
+			ID: "message-2", To: "alice@example.com", Subject: "Retried", Text: "retried", HTML: "<p>retried</p>",
+			Attempts: 1, NextAttemptAt: &past,
+		},
+	)
+
+	messages := server.waitForMessages(t, 2)
+
+	subjects := []string{subject(t, messages[0]), subject(t, messages[1])}
+	if !slices.Contains(subjects, "Queued") || !slices.Contains(subjects, "Retried") {
+		t.Errorf("expected both pending messages, got %v", subjects)
+	}
+
+	ctx := shared.WithUserID(context.Background(), "alice")
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		outbox, err := storageService.LoadEmailOutbox(ctx)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(outbox) == 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected sent messages to leave the outbox, got %+v", outbox)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPermanentFailureIsNotRetried(t *testing.T) {
+	t.Parallel()
+
+	service, server, _ := setupEmail(t)
+	server.fail(550, 1)
+
+	ctx := shared.WithUserID(context.Background(), "alice")
+	updateSettings(t, service, ctx, `{"address": "alice@example.com", "alerts": true}`)
+
+	service.NotifyAlert(ctx, shared.AlertEvent{ID: "event-1", City: "halifax"}) //nolint:exhaustruct // optional fields
+	service.NotifyAlert(ctx, shared.AlertEvent{ID: "event-2", City: "toronto"}) //nolint:exhaustruct // optional fields
+
+	// Sent by two workers, the second alert may go out before the first one is rejected.
+	server.waitForMessages(t, 1)
+	time.Sleep(50 * time.Millisecond)
+
+	messages, attempts := server.received()
+	if len(messages) != 1 || attempts != 2 {
+		t.Fatalf("expected one message sent and one rejected without retry, got %d in %d attempts", len(messages), attempts)
+	}
+}
+
+func TestDigestIsSentOncePerDay(t *testing.T) {
+	t.Parallel()
+
+	service, server, storageService := setupEmail(t)
+
+	ctx := shared.WithUserID(context.Background(), "alice")
+	if err := storageService.SaveUserData(ctx, shared.UserData{Cities: []string{"halifax"}, Units: weather.UnitsMetric}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	updateSettings(t, service, ctx, `{"address": "alice@example.com", "digest": true}`)
+
+	// Run sends the digests due now as well, so only messages on these dates count.
+	service.SendDigests(context.Background(), time.Date(2099, 1, 1, 6, 0, 0, 0, time.UTC))
+	service.SendDigests(context.Background(), time.Date(2099, 1, 1, 7, 0, 0, 0, time.UTC))
+	service.SendDigests(context.Background(), time.Date(2099, 1, 1, 20, 0, 0, 0, time.UTC))
+	service.SendDigests(context.Background(), time.Date(2099, 1, 2, 6, 59, 0, 0, time.UTC))
+
+	server.waitForMessages(t, 1)
+	time.Sleep(50 * time.Millisecond)
+
+	messages, _ := server.received()
+
+	var digests []*mail.Message
+
+	for _, msg := range messages {
+		if strings.Contains(subject(t, msg), "2099") {
+			digests = append(digests, msg)
+		}
+	}
+
+	if len(digests) != 1 {
+		t.Fatalf("expected one digest, got %d", len(digests))
+	}
+
+	if got, want := subject(t, digests[0]), "Your weather for 2099-01-01"; got != want {
+		t.Errorf("expected subject %q, got %q", want, got)
+	}
+
+	text, html := bodies(t, digests[0])
+	for _, want := range []string{"halifax", "11.5 °C", "2026-10-20: 3 to 16 °C"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in the text body, got %q", want, text)
+		}
+	}
+
+	if strings.Contains(text, "2026-10-21") {
+		t.Errorf("expected only %d forecast days, got %q", 3, text)
+	}
+
+	if !strings.Contains(html, "halifax") || !strings.Contains(html, "11.5 °C") {
+		t.Errorf("expected the weather in the HTML body, got %q", html)
+	}
+
+	settings, err := storageService.LoadEmailSettings(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if settings.LastDigest == "" {
+		t.Errorf("expected the digest date to be recorded, got %+v", settings)
+	}
+}
+
+func TestUpdateSettingsValidation(t *testing.T) {
+	t.Parallel()
+
+	service, _, _ := setupEmail(t)
+
+	for _, tc := range []struct {
+		body string
+		want error
+	}{
+		{`{"address": `, email.ErrInvalidBody},
+		{`{"address": "not an address"}`, email.ErrInvalidAddress},
+		{`{"address": "Alice <alice@example.com>"}`, email.ErrInvalidAddress},
+		{`{"alerts": true}`, email.ErrAddressRequired},
+		{`{"digest": true}`, email.ErrAddressRequired},
+	} {
+		req := httptest.NewRequest(http.MethodPut, "/user/email", strings.NewReader(tc.body))
+		if err := service.UpdateSettings(httptest.NewRecorder(), req); !errors.Is(err, tc.want) {
+			t.Errorf("expected %v for %s, got %v", tc.want, tc.body, err)
+		}
+	}
+
+	updateSettings(t, service, context.Background(), `{"address": " alice@example.com ", "alerts": true}`)
+
+	rec := httptest.NewRecorder()
+	if err := service.GetSettings(rec, httptest.NewRequest(http.MethodGet, "/user/email", nil)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if got, want := strings.TrimSpace(rec.Body.String()), `{"address":"alice@example.com","alerts":true,"digest":false}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+}
@@ -0,0 +1,228 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codyonesock/rest_weather/internal/shared"
+)
+
+// TLS modes of a Mailer.
+const (
+	// TLSStartTLS upgrades a plain connection with STARTTLS, usually on port 587.
+	TLSStartTLS = "starttls"
+	// TLSImplicit connects with TLS from the start, usually on port 465.
+	TLSImplicit = "tls"
+	// TLSNone sends in the clear, only for local relays.
+	TLSNone = "none"
+)
+
+// ErrStartTLSUnsupported is returned when the server doesn't offer STARTTLS.
+var ErrStartTLSUnsupported = errors.New("smtp server doesn't support STARTTLS")
+
+// Mailer sends messages through an SMTP server.
+// Username and Password are sent with PLAIN auth when Username is set.
+type Mailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	TLS      string
+	Timeout  time.Duration
+}
+
+// NewMailer creates a new instance of Mailer. Each send gets timeout to complete.
+func NewMailer(host string, port int, username, password, tlsMode string, timeout time.Duration) *Mailer {
+	return &Mailer{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		TLS:      tlsMode,
+		Timeout:  timeout,
+	}
+}
+
+// Send delivers msg from the envelope sender from to the recipients to.
+func (m *Mailer) Send(ctx context.Context, from string, to []string, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	dialer := &net.Dialer{} //nolint:exhaustruct // defaults
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if m.TLS == TLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: m.tlsConfig()}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to set deadline: %w", err)
+	}
+
+	c, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to start session: %w", err)
+	}
+	defer c.Close()
+
+	if m.TLS == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return ErrStartTLSUnsupported
+		}
+
+		if err := c.StartTLS(m.tlsConfig()); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+
+	if m.Username != "" {
+		// net/smtp refuses PLAIN auth without TLS unless the server is on localhost.
+		if err := c.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("failed to add recipient: %w", err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start message: %w", err)
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	if err := c.Quit(); err != nil {
+		return fmt.Errorf("failed to end session: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Mailer) tlsConfig() *tls.Config {
+	return &tls.Config{ServerName: m.Host, MinVersion: tls.VersionTLS12} //nolint:exhaustruct // defaults
+}
+
+// permanent reports whether err is a 5xx reply, which sending again won't fix.
+func permanent(err error) bool {
+	var protoErr *textproto.Error
+
+	return errors.As(err, &protoErr) && protoErr.Code >= 500
+}
+
+// Message is an email with a plain text and an HTML body.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// encode returns msg as a multipart/alternative MIME message from from, dated now.
+func (msg Message) encode(from *mail.Address, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+
+	parts := multipart.NewWriter(&buf)
+
+	header := textproto.MIMEHeader{}
+	header.Set("From", from.String())
+	header.Set("To", msg.To)
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header.Set("Date", now.Format(time.RFC1123Z))
+	header.Set("Message-ID", "<"+shared.NewID()+"."+strconv.FormatInt(now.Unix(), 10)+"@"+domain(from.Address)+">")
+	header.Set("MIME-Version", "1.0")
+	header.Set("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+
+	for _, key := range []string{"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type"} {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, header.Get(key))
+	}
+
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create part: %w", err)
+		}
+
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close message: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+
+	if _, err := io.WriteString(qp, body); err != nil {
+		return fmt.Errorf("failed to write part: %w", err)
+	}
+
+	if err := qp.Close(); err != nil {
+		return fmt.Errorf("failed to write part: %w", err)
+	}
+
+	return nil
+}
+
+// domain returns the domain of address, for Message-IDs.
+func domain(address string) string {
+	if i := strings.LastIndex(address, "@"); i != -1 {
+		return address[i+1:]
+	}
+
+	return "localhost"
+}
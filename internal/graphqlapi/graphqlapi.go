@@ -0,0 +1,333 @@
+// Package graphqlapi serves the weather API as a GraphQL schema, so a client can fetch the
+// user's cities with exactly the weather fields it needs in a single request.
+//
+// Every query runs in a weather batch: a city asked for current weather and a forecast is
+// geocoded once, and each upstream lookup is made at most once per request. A query may look
+// up the weather of at most maxCities distinct cities; one asking for more gets no data.
+package graphqlapi
+
+import (
+	"context"
+	_ "embed" // schema.graphql
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	graphqllog "github.com/graph-gophers/graphql-go/log"
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/logger"
+	"github.com/codyonesock/rest_weather/internal/shared"
+	"github.com/codyonesock/rest_weather/internal/weather"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+const (
+	// maxDepth bounds how deeply queries may nest.
+	maxDepth = 10
+	// maxBodySize is the largest query body accepted.
+	maxBodySize = 1 << 20
+	// maxCities bounds the distinct cities a query may look up, however many fields or aliases
+	// ask for them.
+	maxCities = 20
+)
+
+// err113 demands no dynamic errors!
+var (
+	ErrInvalidRequest = errors.New("invalid GraphQL request")
+	ErrQueryRequired  = errors.New("query is required")
+	ErrInvalidDays    = errors.New("days must be at least 1")
+	ErrTooManyCities  = errors.New("too many cities in query")
+)
+
+// Request is a GraphQL request as sent in a POST body or GET query string.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Service handles dependencies and config.
+type Service struct {
+	Logger  *zap.Logger
+	Weather *weather.Service
+
+	schema *graphql.Schema
+}
+
+// NewGraphQLService creates a new instance of Service.
+func NewGraphQLService(l *zap.Logger, weatherService *weather.Service) *Service {
+	s := &Service{Logger: l, Weather: weatherService, schema: nil}
+
+	s.schema = graphql.MustParseSchema(schemaSDL, &queryResolver{weather: weatherService},
+		graphql.MaxDepth(maxDepth),
+		graphql.Logger(graphqllog.LoggerFunc(func(ctx context.Context, value any) {
+			logger.FromContext(ctx, s.Logger).Error("Panic resolving GraphQL query", zap.Any("panic", value))
+		})),
+	)
+
+	return s
+}
+
+// Exec runs req in its own weather batch. A query looking up more than maxCities cities is
+// rejected: lookups past the limit fail and the response only has the error.
+func (s *Service) Exec(ctx context.Context, req Request) *graphql.Response {
+	limit := &cityLimit{mu: sync.Mutex{}, cities: map[string]struct{}{}, exceeded: false}
+	ctx = context.WithValue(weather.WithBatch(ctx), cityLimitKey{}, limit)
+
+	resp := s.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	if limit.wasExceeded() {
+		err := fmt.Errorf("%w, at most %d", ErrTooManyCities, maxCities)
+
+		return &graphql.Response{
+			Errors:     []*gqlerrors.QueryError{gqlerrors.Errorf("%v", err)},
+			Data:       nil,
+			Extensions: nil,
+		}
+	}
+
+	return resp
+}
+
+type cityLimitKey struct{}
+
+// cityLimit counts the distinct cities a query looks up.
+type cityLimit struct {
+	mu       sync.Mutex
+	cities   map[string]struct{}
+	exceeded bool
+}
+
+// lookup records a lookup of city in ctx's query, failing once maxCities other cities were looked up.
+func lookup(ctx context.Context, city string) error {
+	l, ok := ctx.Value(cityLimitKey{}).(*cityLimit)
+	if !ok {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := strings.ToLower(city)
+	if _, found := l.cities[key]; found {
+		return nil
+	}
+
+	if len(l.cities) >= maxCities {
+		l.exceeded = true
+		return fmt.Errorf("%w, at most %d", ErrTooManyCities, maxCities)
+	}
+
+	l.cities[key] = struct{}{}
+
+	return nil
+}
+
+func (l *cityLimit) wasExceeded() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.exceeded
+}
+
+// ServeGraphQL answers a GraphQL request. Query errors are part of the response;
+// an error is only returned, with nothing written, if the request itself is malformed.
+func (s *Service) ServeGraphQL(w http.ResponseWriter, r *http.Request) error {
+	req, err := decodeRequest(w, r)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(s.Exec(r.Context(), req)); err != nil {
+		logger.FromContext(r.Context(), s.Logger).Debug("Failed to write GraphQL response", zap.Error(err))
+	}
+
+	return nil
+}
+
+// decodeRequest reads a request from the query string of a GET or the JSON body of a POST.
+func decodeRequest(w http.ResponseWriter, r *http.Request) (Request, error) {
+	req := Request{Query: "", OperationName: "", Variables: nil}
+
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return req, fmt.Errorf("%w: variables: %w", ErrInvalidRequest, err)
+			}
+		}
+	} else if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		return req, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+
+	if strings.TrimSpace(req.Query) == "" {
+		return req, ErrQueryRequired
+	}
+
+	return req, nil
+}
+
+type queryResolver struct {
+	weather *weather.Service
+}
+
+func (q *queryResolver) User(ctx context.Context) (*userResolver, error) {
+	userData, err := q.weather.UserData(ctx)
+	if err != nil {
+		return nil, resolverError(err, "error loading user data")
+	}
+
+	return &userResolver{weather: q.weather, data: userData}, nil
+}
+
+func (q *queryResolver) City(ctx context.Context, args struct{ Name string }) (*cityResolver, error) {
+	name := strings.TrimSpace(args.Name)
+	if name == "" {
+		return nil, weather.ErrCityRequired
+	}
+
+	userData, err := q.weather.UserData(ctx)
+	if err != nil {
+		return nil, resolverError(err, "error loading user data")
+	}
+
+	return &cityResolver{weather: q.weather, name: name, units: userData.Units}, nil
+}
+
+type userResolver struct {
+	weather *weather.Service
+	data    *shared.UserData
+}
+
+func (u *userResolver) Units() string {
+	return u.data.Units
+}
+
+func (u *userResolver) Cities() []*cityResolver {
+	cities := make([]*cityResolver, 0, len(u.data.Cities))
+	for _, city := range u.data.Cities {
+		cities = append(cities, &cityResolver{weather: u.weather, name: city, units: u.data.Units})
+	}
+
+	return cities
+}
+
+type cityResolver struct {
+	weather *weather.Service
+	name    string
+	units   string
+}
+
+func (c *cityResolver) Name() string {
+	return c.name
+}
+
+func (c *cityResolver) Current(ctx context.Context) (*weatherResolver, error) {
+	if err := lookup(ctx, c.name); err != nil {
+		return nil, err
+	}
+
+	data, err := c.weather.CurrentWeather(ctx, c.name)
+	if err != nil {
+		return nil, resolverError(err, "error getting current weather")
+	}
+
+	return &weatherResolver{report: weather.NewWeatherReport(c.name, c.units, data)}, nil
+}
+
+func (c *cityResolver) Forecast(ctx context.Context, args struct{ Days *int32 }) (*forecastResolver, error) {
+	if args.Days != nil && *args.Days < 1 {
+		return nil, ErrInvalidDays
+	}
+
+	if err := lookup(ctx, c.name); err != nil {
+		return nil, err
+	}
+
+	data, err := c.weather.Forecast(ctx, c.name)
+	if err != nil {
+		return nil, resolverError(err, "error getting forecast")
+	}
+
+	report := weather.NewForecastReport(c.name, c.units, data)
+	if args.Days != nil && int(*args.Days) < len(report.Days) {
+		report.Days = report.Days[:*args.Days]
+	}
+
+	return &forecastResolver{report: report}, nil
+}
+
+type weatherResolver struct {
+	report *weather.WeatherReport
+}
+
+func (w *weatherResolver) Temperature() float64    { return w.report.Temperature }
+func (w *weatherResolver) TemperatureUnit() string { return w.report.TemperatureUnit }
+func (w *weatherResolver) WindSpeed() float64      { return w.report.WindSpeed }
+func (w *weatherResolver) WindSpeedUnit() string   { return w.report.WindSpeedUnit }
+func (w *weatherResolver) Condition() *string      { return optional(w.report.Condition) }
+
+func (w *weatherResolver) ObservedAt() *graphql.Time {
+	if w.report.ObservedAt.IsZero() {
+		return nil
+	}
+
+	return &graphql.Time{Time: w.report.ObservedAt}
+}
+
+type forecastResolver struct {
+	report *weather.ForecastReport
+}
+
+func (f *forecastResolver) TemperatureUnit() string {
+	return f.report.TemperatureUnit
+}
+
+func (f *forecastResolver) Days() []*forecastDayResolver {
+	days := make([]*forecastDayResolver, 0, len(f.report.Days))
+	for _, day := range f.report.Days {
+		days = append(days, &forecastDayResolver{day: day})
+	}
+
+	return days
+}
+
+type forecastDayResolver struct {
+	day weather.ForecastDay
+}
+
+func (d *forecastDayResolver) Date() string       { return d.day.Date }
+func (d *forecastDayResolver) Min() float64       { return d.day.Min }
+func (d *forecastDayResolver) Max() float64       { return d.day.Max }
+func (d *forecastDayResolver) Condition() *string { return optional(d.day.Condition) }
+
+// optional maps "" to null.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+// resolverError keeps errors the caller can act on and hides the rest, which are already
+// logged, behind msg.
+func resolverError(err error, msg string) error {
+	if errors.Is(err, weather.ErrCityRequired) || errors.Is(err, weather.ErrNoResultsForCity) {
+		return err
+	}
+
+	return errors.New(msg) //nolint:err113 // a generic message for the client
+}
@@ -0,0 +1,168 @@
+package graphqlapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/graphqlapi"
+	"github.com/codyonesock/rest_weather/internal/weather"
+	"github.com/codyonesock/rest_weather/internal/weathertest"
+)
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+		Path    []any  `json:"path"`
+	} `json:"errors"`
+}
+
+// setupGraphQL serves the GraphQL service with halifax and berlin saved, backed by a fake upstream.
+func setupGraphQL(t *testing.T) (*graphqlapi.Service, *weathertest.Upstream) {
+	t.Helper()
+
+	upstream := weathertest.NewUpstream(t)
+	weatherService, _ := upstream.NewWeatherService(t)
+
+	if _, err := weatherService.AddCities(context.Background(), []string{"halifax", "berlin"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	return graphqlapi.NewGraphQLService(zap.NewNop(), weatherService), upstream
+}
+
+func query(t *testing.T, service *graphqlapi.Service, req *http.Request) response {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	if err := service.ServeGraphQL(rec, req); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("expected application/json, got %q", got)
+	}
+
+	var resp response
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("expected a JSON response, got %v", err)
+	}
+
+	return resp
+}
+
+func post(body string) *http.Request {
+	return httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+}
+
+func TestUserQueryBatchesLookups(t *testing.T) {
+	t.Parallel()
+
+	service, upstream := setupGraphQL(t)
+
+	resp := query(t, service, post(`{"query": "query($days: Int) { user { units cities { name `+
+		`current { temperature condition observedAt } forecast(days: $days) { days { date max } } } } }", `+
+		`"variables": {"days": 1}}`))
+	if len(resp.Errors) != 0 {
+		t.Fatalf("expected no errors, got %+v", resp.Errors)
+	}
+
+	want := `{"user":{"units":"metric","cities":[` +
+		`{"name":"halifax","current":{"temperature":12.5,"condition":"Rain","observedAt":"2026-10-18T12:00:00Z"},` +
+		`"forecast":{"days":[{"date":"2026-10-18","max":14.2}]}},` +
+		`{"name":"berlin","current":{"temperature":12.5,"condition":"Rain","observedAt":"2026-10-18T12:00:00Z"},` +
+		`"forecast":{"days":[{"date":"2026-10-18","max":14.2}]}}]}}`
+	if string(resp.Data) != want {
+		t.Errorf("expected %s, got %s", want, resp.Data)
+	}
+
+	// Both cities geocode to the same coordinates, so their weather is fetched once too.
+	if requests := upstream.Requests(); requests["/geocode"] != 2 || requests["/current"] != 1 || requests["/forecast"] != 1 {
+		t.Errorf("expected 2 geocode, 1 current and 1 forecast request, got %v", requests)
+	}
+}
+
+func TestGetQueryWithPartialErrors(t *testing.T) {
+	t.Parallel()
+
+	service, _ := setupGraphQL(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(
+		`{ city(name: "`+weathertest.UnknownCity+`") { name current { temperature } forecast(days: 0) { temperatureUnit } } }`), nil)
+
+	resp := query(t, service, req)
+
+	if want := `{"city":{"name":"nowhere","current":null,"forecast":null}}`; string(resp.Data) != want {
+		t.Errorf("expected %s, got %s", want, resp.Data)
+	}
+
+	messages := []string{}
+	for _, e := range resp.Errors {
+		messages = append(messages, e.Message)
+	}
+
+	if len(messages) != 2 || !strings.Contains(strings.Join(messages, "\n"), weather.ErrNoResultsForCity.Error()) ||
+		!strings.Contains(strings.Join(messages, "\n"), graphqlapi.ErrInvalidDays.Error()) {
+		t.Errorf("expected an unknown city and an invalid days error, got %v", messages)
+	}
+}
+
+func TestQueryRejectsTooManyCities(t *testing.T) {
+	t.Parallel()
+
+	service, upstream := setupGraphQL(t)
+
+	aliases := make([]string, 0, 21)
+	for i := range 21 {
+		aliases = append(aliases, fmt.Sprintf(`c%d: city(name: \"city%d\") { current { temperature } }`, i, i))
+	}
+
+	resp := query(t, service, post(`{"query": "{ `+strings.Join(aliases, " ")+` }"}`))
+
+	if len(resp.Data) != 0 || len(resp.Errors) != 1 ||
+		!strings.Contains(resp.Errors[0].Message, graphqlapi.ErrTooManyCities.Error()) {
+		t.Errorf("expected only a too many cities error, got %s %+v", resp.Data, resp.Errors)
+	}
+
+	if requests := upstream.Requests(); requests["/geocode"] > 20 {
+		t.Errorf("expected at most 20 cities to be looked up, got %v", requests)
+	}
+
+	// The same city under many aliases is one lookup.
+	for i := range aliases {
+		aliases[i] = fmt.Sprintf(`c%d: city(name: \"halifax\") { current { temperature } }`, i)
+	}
+
+	if resp := query(t, service, post(`{"query": "{ `+strings.Join(aliases, " ")+` }"}`)); len(resp.Errors) != 0 {
+		t.Errorf("expected no errors, got %+v", resp.Errors)
+	}
+}
+
+func TestServeGraphQLRejectsInvalidRequests(t *testing.T) {
+	t.Parallel()
+
+	service, _ := setupGraphQL(t)
+
+	for _, tc := range []struct {
+		req  *http.Request
+		want error
+	}{
+		{post(`{"query": `), graphqlapi.ErrInvalidRequest},
+		{post(`{"query": " "}`), graphqlapi.ErrQueryRequired},
+		{httptest.NewRequest(http.MethodGet, "/graphql", nil), graphqlapi.ErrQueryRequired},
+		{httptest.NewRequest(http.MethodGet, "/graphql?query=%7Buser%7Bunits%7D%7D&variables=%7B", nil), graphqlapi.ErrInvalidRequest},
+	} {
+		if err := service.ServeGraphQL(httptest.NewRecorder(), tc.req); !errors.Is(err, tc.want) {
+			t.Errorf("expected %v for %s, got %v", tc.want, tc.req.URL, err)
+		}
+	}
+}
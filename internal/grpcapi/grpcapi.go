@@ -0,0 +1,439 @@
+// Package grpcapi serves the weather API over gRPC, backed by the same services as the REST routes.
+package grpcapi
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=github.com/codyonesock/rest_weather --go-grpc_out=../.. --go-grpc_opt=module=github.com/codyonesock/rest_weather weather/v1/weather.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/codyonesock/rest_weather/internal/auth"
+	"github.com/codyonesock/rest_weather/internal/grpcapi/weatherv1"
+	"github.com/codyonesock/rest_weather/internal/logger"
+	"github.com/codyonesock/rest_weather/internal/ratelimit"
+	"github.com/codyonesock/rest_weather/internal/shared"
+	"github.com/codyonesock/rest_weather/internal/stream"
+	"github.com/codyonesock/rest_weather/internal/weather"
+)
+
+// methodScopes is the scope each RPC requires, like the REST routes they mirror.
+var methodScopes = map[string]string{
+	weatherv1.WeatherService_GetCurrent_FullMethodName:   auth.ScopeReadWeather,
+	weatherv1.WeatherService_GetForecast_FullMethodName:  auth.ScopeReadWeather,
+	weatherv1.WeatherService_GetUserData_FullMethodName:  auth.ScopeReadWeather,
+	weatherv1.WeatherService_AddCities_FullMethodName:    auth.ScopeWriteUser,
+	weatherv1.WeatherService_DeleteCities_FullMethodName: auth.ScopeWriteUser,
+	weatherv1.WeatherService_SetUnits_FullMethodName:     auth.ScopeWriteUser,
+	weatherv1.WeatherService_WatchWeather_FullMethodName: auth.ScopeReadWeather,
+}
+
+// Service implements weatherv1.WeatherServiceServer.
+type Service struct {
+	weatherv1.UnimplementedWeatherServiceServer
+
+	Logger  *zap.Logger
+	Weather *weather.Service
+	Stream  *stream.Service
+	Auth    *auth.Service
+
+	// ReadLimiter and WriteLimiter budget the RPCs by the scope they require, sharing the
+	// buckets of the REST routes; nil disables them.
+	ReadLimiter  *ratelimit.Limiter
+	WriteLimiter *ratelimit.Limiter
+}
+
+// NewGRPCService creates a new instance of Service.
+func NewGRPCService(
+	l *zap.Logger,
+	weatherService *weather.Service,
+	streamService *stream.Service,
+	authService *auth.Service,
+) *Service {
+	return &Service{
+		UnimplementedWeatherServiceServer: weatherv1.UnimplementedWeatherServiceServer{},
+		Logger:                            l,
+		Weather:                           weatherService,
+		Stream:                            streamService,
+		Auth:                              authService,
+		ReadLimiter:                       nil,
+		WriteLimiter:                      nil,
+	}
+}
+
+// Server returns a gRPC server with the service, authentication, rate limits and reflection registered.
+func (s *Service) Server() *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+	weatherv1.RegisterWeatherServiceServer(server, s)
+	reflection.Register(server)
+
+	return server
+}
+
+// Serve listens on addr and serves until ctx is done, then waits up to shutdownTimeout for open RPCs.
+func (s *Service) Serve(ctx context.Context, addr string, shutdownTimeout time.Duration) error {
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", addr) //nolint:exhaustruct // defaults
+	if err != nil {
+		return fmt.Errorf("error listening for gRPC: %w", err)
+	}
+
+	server := s.Server()
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	s.Logger.Info("gRPC server running", zap.String("port", addr))
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("error serving gRPC: %w", err)
+	case <-ctx.Done():
+	}
+
+	stopped := make(chan struct{})
+
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		server.Stop()
+	}
+
+	return nil
+}
+
+// GetCurrent returns the current weather of a city in the caller's units.
+func (s *Service) GetCurrent(ctx context.Context, req *weatherv1.GetCurrentRequest) (*weatherv1.WeatherReport, error) {
+	report, err := s.Weather.CurrentWeatherReport(ctx, strings.TrimSpace(req.GetCity()))
+	if err != nil {
+		return nil, statusError(err, "error getting current weather")
+	}
+
+	return weatherReport(report), nil
+}
+
+// GetForecast returns the daily forecast of a city in the caller's units.
+func (s *Service) GetForecast(ctx context.Context, req *weatherv1.GetForecastRequest) (*weatherv1.ForecastReport, error) {
+	report, err := s.Weather.ForecastReport(ctx, strings.TrimSpace(req.GetCity()))
+	if err != nil {
+		return nil, statusError(err, "error getting forecast data")
+	}
+
+	return forecastReport(report), nil
+}
+
+// GetUserData returns the saved cities and units.
+func (s *Service) GetUserData(ctx context.Context, _ *weatherv1.GetUserDataRequest) (*weatherv1.UserData, error) {
+	data, err := s.Weather.UserData(ctx)
+	if err != nil {
+		return nil, statusError(err, "error getting user data")
+	}
+
+	return userData(data), nil
+}
+
+// AddCities saves cities.
+func (s *Service) AddCities(ctx context.Context, req *weatherv1.AddCitiesRequest) (*weatherv1.UserData, error) {
+	data, err := s.Weather.AddCities(ctx, req.GetCities())
+	if err != nil {
+		return nil, statusError(err, "error adding cities to user data")
+	}
+
+	return userData(data), nil
+}
+
+// DeleteCities removes saved cities.
+func (s *Service) DeleteCities(ctx context.Context, req *weatherv1.DeleteCitiesRequest) (*weatherv1.UserData, error) {
+	data, err := s.Weather.DeleteCities(ctx, req.GetCities())
+	if err != nil {
+		return nil, statusError(err, "error deleting cities from user data")
+	}
+
+	return userData(data), nil
+}
+
+// SetUnits saves the units.
+func (s *Service) SetUnits(ctx context.Context, req *weatherv1.SetUnitsRequest) (*weatherv1.UserData, error) {
+	data, err := s.Weather.SetUnits(ctx, req.GetUnits())
+	if err != nil {
+		return nil, statusError(err, "error updating units in user data")
+	}
+
+	return userData(data), nil
+}
+
+// WatchWeather sends the latest conditions of the cities, then every change the stream service sees.
+func (s *Service) WatchWeather(req *weatherv1.WatchWeatherRequest, watch grpc.ServerStreamingServer[weatherv1.WeatherReport]) error {
+	ctx := watch.Context()
+
+	cities := []string{}
+
+	for _, city := range req.GetCities() {
+		if city = strings.TrimSpace(city); city != "" && !slices.Contains(cities, city) {
+			cities = append(cities, city)
+		}
+	}
+
+	switch {
+	case len(cities) == 0:
+		return statusError(stream.ErrCitiesRequired, "")
+	case len(cities) > stream.MaxCities:
+		return statusError(stream.ErrTooManyCities, "")
+	}
+
+	data, err := s.Weather.UserData(ctx)
+	if err != nil {
+		return statusError(err, "error getting user data")
+	}
+
+	sub := s.Stream.Subscribe()
+	defer sub.Close()
+
+	for _, update := range sub.Follow(ctx, cities...) {
+		if err := watch.Send(weatherReport(weather.NewWeatherReport(update.City, data.Units, update.Data))); err != nil {
+			return fmt.Errorf("failed to send report: %w", err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.Stream.Done():
+			return status.Error(codes.Unavailable, "server shutting down")
+		case update, ok := <-sub.Updates():
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "falling behind on updates")
+			}
+
+			if err := watch.Send(weatherReport(weather.NewWeatherReport(update.City, data.Units, update.Data))); err != nil {
+				return fmt.Errorf("failed to send report: %w", err)
+			}
+		}
+	}
+}
+
+// unaryInterceptor authenticates and rate limits the call, and logs failures.
+func (s *Service) unaryInterceptor(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	ctx, err := s.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.limit(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+
+	resp, err := handler(ctx, req)
+	logFailure(ctx, s.Logger, err)
+
+	return resp, err
+}
+
+// streamInterceptor authenticates and rate limits the call, and logs failures.
+func (s *Service) streamInterceptor(
+	srv any,
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	ctx, err := s.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	if err := s.limit(ctx, info.FullMethod); err != nil {
+		return err
+	}
+
+	err = handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	logFailure(ctx, s.Logger, err)
+
+	return err
+}
+
+// logFailure logs a failed RPC: at Error when the server failed, like the REST handlers, and
+// at Debug when the caller did.
+func logFailure(ctx context.Context, l *zap.Logger, err error) {
+	if err == nil {
+		return
+	}
+
+	log := logger.FromContext(ctx, l)
+	code := status.Code(err)
+
+	switch code {
+	case codes.Internal, codes.Unavailable, codes.Unknown, codes.DataLoss:
+		log.Error("RPC failed", zap.Stringer("code", code), zap.Error(err))
+	default:
+		log.Debug("RPC failed", zap.Stringer("code", code), zap.Error(err))
+	}
+}
+
+// limit takes a token from the read or write budget of the caller, by the scope method requires.
+// Over the limit, it returns ResourceExhausted with how long to wait in a RetryInfo detail.
+func (s *Service) limit(ctx context.Context, method string) error {
+	limiter := s.ReadLimiter
+
+	switch methodScopes[method] {
+	case auth.ScopeReadWeather:
+	case auth.ScopeWriteUser:
+		limiter = s.WriteLimiter
+	default:
+		return nil
+	}
+
+	if limiter == nil || limiter.Rate <= 0 {
+		return nil
+	}
+
+	remoteAddr := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+
+	result := limiter.Allow(ratelimit.Key(ctx, remoteAddr), time.Now())
+	if result.Allowed {
+		return nil
+	}
+
+	st := status.New(codes.ResourceExhausted, "too many requests")
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(result.RetryAfter)}); err == nil {
+		st = detailed
+	}
+
+	return st.Err()
+}
+
+// authenticate checks the credentials in the metadata and the scope method requires.
+// Methods outside WeatherService, such as reflection, need no scope.
+func (s *Service) authenticate(ctx context.Context, method string) (context.Context, error) {
+	ctx = logger.WithContext(ctx, s.Logger.With(zap.String("method", method)))
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	ctx, err := s.Auth.AuthenticateContext(ctx, auth.Credential(first(md.Get("x-api-key")), first(md.Get("authorization"))))
+	if err != nil {
+		logger.FromContext(ctx, s.Logger).Warn("Rejected credentials", zap.Error(err))
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	scope, ok := methodScopes[method]
+	if !ok {
+		return ctx, nil
+	}
+
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok || (principal.Anonymous() && !principal.HasScope(scope)) {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	if !principal.HasScope(scope) {
+		return nil, status.Error(codes.PermissionDenied, "missing scope "+scope)
+	}
+
+	return ctx, nil
+}
+
+// serverStream carries the authenticated context into stream handlers.
+type serverStream struct {
+	grpc.ServerStream
+
+	ctx context.Context //nolint:containedctx // grpc.ServerStream exposes its context
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
+
+// statusError maps the weather and stream errors to a status; anything else is Internal with msg.
+func statusError(err error, msg string) error {
+	switch {
+	case errors.Is(err, weather.ErrCityRequired), errors.Is(err, weather.ErrInvalidUnit),
+		errors.Is(err, stream.ErrCitiesRequired), errors.Is(err, stream.ErrTooManyCities):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, weather.ErrNoResultsForCity):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, msg)
+	}
+}
+
+func weatherReport(report *weather.WeatherReport) *weatherv1.WeatherReport {
+	converted := &weatherv1.WeatherReport{
+		City:            report.City,
+		Units:           report.Units,
+		Temperature:     report.Temperature,
+		TemperatureUnit: report.TemperatureUnit,
+		WindSpeed:       report.WindSpeed,
+		WindSpeedUnit:   report.WindSpeedUnit,
+		Condition:       report.Condition,
+		ObservedAt:      nil,
+	}
+
+	if !report.ObservedAt.IsZero() {
+		converted.ObservedAt = timestamppb.New(report.ObservedAt)
+	}
+
+	return converted
+}
+
+func forecastReport(report *weather.ForecastReport) *weatherv1.ForecastReport {
+	days := make([]*weatherv1.ForecastDay, 0, len(report.Days))
+	for _, day := range report.Days {
+		days = append(days, &weatherv1.ForecastDay{Date: day.Date, Min: day.Min, Max: day.Max, Condition: day.Condition})
+	}
+
+	return &weatherv1.ForecastReport{
+		City:            report.City,
+		Units:           report.Units,
+		TemperatureUnit: report.TemperatureUnit,
+		Days:            days,
+	}
+}
+
+func userData(data *shared.UserData) *weatherv1.UserData {
+	return &weatherv1.UserData{Cities: data.Cities, Units: data.Units}
+}
+
+// first returns the first metadata value, or "".
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
@@ -0,0 +1,257 @@
+package grpcapi_test
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/codyonesock/rest_weather/internal/auth"
+	"github.com/codyonesock/rest_weather/internal/grpcapi"
+	"github.com/codyonesock/rest_weather/internal/grpcapi/weatherv1"
+	"github.com/codyonesock/rest_weather/internal/ratelimit"
+	"github.com/codyonesock/rest_weather/internal/stream"
+	"github.com/codyonesock/rest_weather/internal/weather"
+	"github.com/codyonesock/rest_weather/internal/weathertest"
+)
+
+// setupClient serves the gRPC service in memory, backed by a fake upstream. configure, if not
+// nil, changes the service before it serves.
+func setupClient(
+	t *testing.T, configure func(*grpcapi.Service),
+) (weatherv1.WeatherServiceClient, *weathertest.Upstream) {
+	t.Helper()
+
+	upstream := weathertest.NewUpstream(t)
+	weatherService, storageService := upstream.NewWeatherService(t)
+	logger := zap.NewNop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	streamService := stream.NewStreamService(logger, weatherService, 10*time.Millisecond, time.Hour)
+	go streamService.Run(ctx)
+
+	authService := auth.NewAuthService(logger, storageService, "admin-secret", true)
+	service := grpcapi.NewGRPCService(logger, weatherService, streamService, authService)
+	if configure != nil {
+		configure(service)
+	}
+
+	server := service.Server()
+
+	listener := bufconn.Listen(1 << 20)
+
+	go func() { _ = server.Serve(listener) }()
+
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return weatherv1.NewWeatherServiceClient(conn), upstream
+}
+
+// adminContext authenticates calls with the admin token.
+func adminContext(t *testing.T) context.Context {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer admin-secret")
+}
+
+func TestGetCurrent(t *testing.T) {
+	t.Parallel()
+
+	client, _ := setupClient(t, nil)
+
+	report, err := client.GetCurrent(adminContext(t), &weatherv1.GetCurrentRequest{City: "halifax"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if report.GetTemperature() != 12.5 || report.GetCondition() != "Rain" || report.GetUnits() != weather.UnitsMetric {
+		t.Errorf("expected 12.5 °C and Rain in metric, got %v", report)
+	}
+
+	if got := report.GetObservedAt().AsTime(); !got.Equal(time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected observed at 2026-10-18T12:00, got %v", got)
+	}
+
+	forecast, err := client.GetForecast(adminContext(t), &weatherv1.GetForecastRequest{City: "halifax"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(forecast.GetDays()) != 2 || forecast.GetDays()[0].GetMax() != 14.2 {
+		t.Errorf("expected two days starting with a max of 14.2, got %v", forecast.GetDays())
+	}
+}
+
+func TestUserData(t *testing.T) {
+	t.Parallel()
+
+	client, _ := setupClient(t, nil)
+	ctx := adminContext(t)
+
+	if _, err := client.AddCities(ctx, &weatherv1.AddCitiesRequest{Cities: []string{"halifax", "berlin"}}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := client.SetUnits(ctx, &weatherv1.SetUnitsRequest{Units: weather.UnitsImperial}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := client.DeleteCities(ctx, &weatherv1.DeleteCitiesRequest{Cities: []string{"berlin"}}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	data, err := client.GetUserData(ctx, &weatherv1.GetUserDataRequest{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !slices.Equal(data.GetCities(), []string{"halifax"}) || data.GetUnits() != weather.UnitsImperial {
+		t.Errorf("expected halifax in imperial, got %v", data)
+	}
+}
+
+func TestStatusCodes(t *testing.T) {
+	t.Parallel()
+
+	client, _ := setupClient(t, nil)
+
+	_, err := client.GetUserData(context.Background(), &weatherv1.GetUserDataRequest{})
+	if got := status.Code(err); got != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated without credentials, got %v", got)
+	}
+
+	for _, tc := range []struct {
+		name string
+		call func(ctx context.Context) error
+		want codes.Code
+	}{
+		{"missing city", func(ctx context.Context) error {
+			_, err := client.GetCurrent(ctx, &weatherv1.GetCurrentRequest{City: " "})
+			return err
+		}, codes.InvalidArgument},
+		{"unknown city", func(ctx context.Context) error {
+			_, err := client.GetForecast(ctx, &weatherv1.GetForecastRequest{City: weathertest.UnknownCity})
+			return err
+		}, codes.NotFound},
+		{"invalid units", func(ctx context.Context) error {
+			_, err := client.SetUnits(ctx, &weatherv1.SetUnitsRequest{Units: "kelvin"})
+			return err
+		}, codes.InvalidArgument},
+		{"no cities to add", func(ctx context.Context) error {
+			_, err := client.AddCities(ctx, &weatherv1.AddCitiesRequest{Cities: nil})
+			return err
+		}, codes.InvalidArgument},
+	} {
+		if got := status.Code(tc.call(adminContext(t))); got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestWatchWeather(t *testing.T) {
+	t.Parallel()
+
+	client, upstream := setupClient(t, nil)
+
+	watch, err := client.WatchWeather(adminContext(t), &weatherv1.WatchWeatherRequest{Cities: []string{"halifax"}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	first, err := watch.Recv()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if first.GetCity() != "halifax" || first.GetTemperature() != 12.5 {
+		t.Errorf("expected 12.5 °C in halifax, got %v", first)
+	}
+
+	upstream.SetTemperature(14)
+
+	second, err := watch.Recv()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if second.GetTemperature() != 14 {
+		t.Errorf("expected the change to 14 °C, got %v", second)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	t.Parallel()
+
+	client, _ := setupClient(t, func(service *grpcapi.Service) {
+		service.ReadLimiter = ratelimit.NewLimiter(0.001, 2)
+		service.WriteLimiter = ratelimit.NewLimiter(0.001, 1)
+	})
+	ctx := adminContext(t)
+
+	for range 2 {
+		if _, err := client.GetCurrent(ctx, &weatherv1.GetCurrentRequest{City: "halifax"}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	_, err := client.GetForecast(ctx, &weatherv1.GetForecastRequest{City: "halifax"})
+	assertExhausted(t, err)
+
+	watch, err := client.WatchWeather(ctx, &weatherv1.WatchWeatherRequest{Cities: []string{"halifax"}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	_, err = watch.Recv()
+	assertExhausted(t, err)
+
+	// Writes have their own budget.
+	if _, err := client.SetUnits(ctx, &weatherv1.SetUnitsRequest{Units: weather.UnitsImperial}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	_, err = client.SetUnits(ctx, &weatherv1.SetUnitsRequest{Units: weather.UnitsMetric})
+	assertExhausted(t, err)
+}
+
+// assertExhausted checks that err is ResourceExhausted and says when to retry.
+func assertExhausted(t *testing.T, err error) {
+	t.Helper()
+
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("expected %v, got %v", codes.ResourceExhausted, err)
+	}
+
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay().AsDuration() > 0 {
+			return
+		}
+	}
+
+	t.Errorf("expected a retry delay, got %v", st.Details())
+}
@@ -0,0 +1,743 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: weather/v1/weather.proto
+
+// Package weather.v1 mirrors the v2 REST API. Reports are converted to the caller's saved units.
+
+package weatherv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetCurrentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	City          string                 `protobuf:"bytes,1,opt,name=city,proto3" json:"city,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCurrentRequest) Reset() {
+	*x = GetCurrentRequest{}
+	mi := &file_weather_v1_weather_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCurrentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCurrentRequest) ProtoMessage() {}
+
+func (x *GetCurrentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCurrentRequest.ProtoReflect.Descriptor instead.
+func (*GetCurrentRequest) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetCurrentRequest) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+type GetForecastRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	City          string                 `protobuf:"bytes,1,opt,name=city,proto3" json:"city,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetForecastRequest) Reset() {
+	*x = GetForecastRequest{}
+	mi := &file_weather_v1_weather_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetForecastRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetForecastRequest) ProtoMessage() {}
+
+func (x *GetForecastRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetForecastRequest.ProtoReflect.Descriptor instead.
+func (*GetForecastRequest) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetForecastRequest) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+type GetUserDataRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserDataRequest) Reset() {
+	*x = GetUserDataRequest{}
+	mi := &file_weather_v1_weather_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserDataRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserDataRequest) ProtoMessage() {}
+
+func (x *GetUserDataRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserDataRequest.ProtoReflect.Descriptor instead.
+func (*GetUserDataRequest) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{2}
+}
+
+type AddCitiesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cities        []string               `protobuf:"bytes,1,rep,name=cities,proto3" json:"cities,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddCitiesRequest) Reset() {
+	*x = AddCitiesRequest{}
+	mi := &file_weather_v1_weather_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddCitiesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddCitiesRequest) ProtoMessage() {}
+
+func (x *AddCitiesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddCitiesRequest.ProtoReflect.Descriptor instead.
+func (*AddCitiesRequest) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AddCitiesRequest) GetCities() []string {
+	if x != nil {
+		return x.Cities
+	}
+	return nil
+}
+
+type DeleteCitiesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cities        []string               `protobuf:"bytes,1,rep,name=cities,proto3" json:"cities,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteCitiesRequest) Reset() {
+	*x = DeleteCitiesRequest{}
+	mi := &file_weather_v1_weather_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteCitiesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteCitiesRequest) ProtoMessage() {}
+
+func (x *DeleteCitiesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteCitiesRequest.ProtoReflect.Descriptor instead.
+func (*DeleteCitiesRequest) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DeleteCitiesRequest) GetCities() []string {
+	if x != nil {
+		return x.Cities
+	}
+	return nil
+}
+
+type SetUnitsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Units         string                 `protobuf:"bytes,1,opt,name=units,proto3" json:"units,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetUnitsRequest) Reset() {
+	*x = SetUnitsRequest{}
+	mi := &file_weather_v1_weather_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetUnitsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetUnitsRequest) ProtoMessage() {}
+
+func (x *SetUnitsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetUnitsRequest.ProtoReflect.Descriptor instead.
+func (*SetUnitsRequest) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SetUnitsRequest) GetUnits() string {
+	if x != nil {
+		return x.Units
+	}
+	return ""
+}
+
+type WatchWeatherRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cities        []string               `protobuf:"bytes,1,rep,name=cities,proto3" json:"cities,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchWeatherRequest) Reset() {
+	*x = WatchWeatherRequest{}
+	mi := &file_weather_v1_weather_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchWeatherRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchWeatherRequest) ProtoMessage() {}
+
+func (x *WatchWeatherRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchWeatherRequest.ProtoReflect.Descriptor instead.
+func (*WatchWeatherRequest) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *WatchWeatherRequest) GetCities() []string {
+	if x != nil {
+		return x.Cities
+	}
+	return nil
+}
+
+type WeatherReport struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	City            string                 `protobuf:"bytes,1,opt,name=city,proto3" json:"city,omitempty"`
+	Units           string                 `protobuf:"bytes,2,opt,name=units,proto3" json:"units,omitempty"`
+	Temperature     float64                `protobuf:"fixed64,3,opt,name=temperature,proto3" json:"temperature,omitempty"`
+	TemperatureUnit string                 `protobuf:"bytes,4,opt,name=temperature_unit,json=temperatureUnit,proto3" json:"temperature_unit,omitempty"`
+	WindSpeed       float64                `protobuf:"fixed64,5,opt,name=wind_speed,json=windSpeed,proto3" json:"wind_speed,omitempty"`
+	WindSpeedUnit   string                 `protobuf:"bytes,6,opt,name=wind_speed_unit,json=windSpeedUnit,proto3" json:"wind_speed_unit,omitempty"`
+	// condition is empty if open-meteo didn't report a weather code.
+	Condition string `protobuf:"bytes,7,opt,name=condition,proto3" json:"condition,omitempty"`
+	// observed_at is unset if open-meteo didn't report when it observed the conditions.
+	ObservedAt    *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=observed_at,json=observedAt,proto3" json:"observed_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WeatherReport) Reset() {
+	*x = WeatherReport{}
+	mi := &file_weather_v1_weather_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WeatherReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WeatherReport) ProtoMessage() {}
+
+func (x *WeatherReport) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WeatherReport.ProtoReflect.Descriptor instead.
+func (*WeatherReport) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WeatherReport) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *WeatherReport) GetUnits() string {
+	if x != nil {
+		return x.Units
+	}
+	return ""
+}
+
+func (x *WeatherReport) GetTemperature() float64 {
+	if x != nil {
+		return x.Temperature
+	}
+	return 0
+}
+
+func (x *WeatherReport) GetTemperatureUnit() string {
+	if x != nil {
+		return x.TemperatureUnit
+	}
+	return ""
+}
+
+func (x *WeatherReport) GetWindSpeed() float64 {
+	if x != nil {
+		return x.WindSpeed
+	}
+	return 0
+}
+
+func (x *WeatherReport) GetWindSpeedUnit() string {
+	if x != nil {
+		return x.WindSpeedUnit
+	}
+	return ""
+}
+
+func (x *WeatherReport) GetCondition() string {
+	if x != nil {
+		return x.Condition
+	}
+	return ""
+}
+
+func (x *WeatherReport) GetObservedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ObservedAt
+	}
+	return nil
+}
+
+type ForecastDay struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Date          string                 `protobuf:"bytes,1,opt,name=date,proto3" json:"date,omitempty"`
+	Min           float64                `protobuf:"fixed64,2,opt,name=min,proto3" json:"min,omitempty"`
+	Max           float64                `protobuf:"fixed64,3,opt,name=max,proto3" json:"max,omitempty"`
+	Condition     string                 `protobuf:"bytes,4,opt,name=condition,proto3" json:"condition,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ForecastDay) Reset() {
+	*x = ForecastDay{}
+	mi := &file_weather_v1_weather_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ForecastDay) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForecastDay) ProtoMessage() {}
+
+func (x *ForecastDay) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForecastDay.ProtoReflect.Descriptor instead.
+func (*ForecastDay) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ForecastDay) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *ForecastDay) GetMin() float64 {
+	if x != nil {
+		return x.Min
+	}
+	return 0
+}
+
+func (x *ForecastDay) GetMax() float64 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
+func (x *ForecastDay) GetCondition() string {
+	if x != nil {
+		return x.Condition
+	}
+	return ""
+}
+
+type ForecastReport struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	City            string                 `protobuf:"bytes,1,opt,name=city,proto3" json:"city,omitempty"`
+	Units           string                 `protobuf:"bytes,2,opt,name=units,proto3" json:"units,omitempty"`
+	TemperatureUnit string                 `protobuf:"bytes,3,opt,name=temperature_unit,json=temperatureUnit,proto3" json:"temperature_unit,omitempty"`
+	Days            []*ForecastDay         `protobuf:"bytes,4,rep,name=days,proto3" json:"days,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ForecastReport) Reset() {
+	*x = ForecastReport{}
+	mi := &file_weather_v1_weather_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ForecastReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForecastReport) ProtoMessage() {}
+
+func (x *ForecastReport) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForecastReport.ProtoReflect.Descriptor instead.
+func (*ForecastReport) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ForecastReport) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *ForecastReport) GetUnits() string {
+	if x != nil {
+		return x.Units
+	}
+	return ""
+}
+
+func (x *ForecastReport) GetTemperatureUnit() string {
+	if x != nil {
+		return x.TemperatureUnit
+	}
+	return ""
+}
+
+func (x *ForecastReport) GetDays() []*ForecastDay {
+	if x != nil {
+		return x.Days
+	}
+	return nil
+}
+
+type UserData struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cities        []string               `protobuf:"bytes,1,rep,name=cities,proto3" json:"cities,omitempty"`
+	Units         string                 `protobuf:"bytes,2,opt,name=units,proto3" json:"units,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserData) Reset() {
+	*x = UserData{}
+	mi := &file_weather_v1_weather_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserData) ProtoMessage() {}
+
+func (x *UserData) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserData.ProtoReflect.Descriptor instead.
+func (*UserData) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *UserData) GetCities() []string {
+	if x != nil {
+		return x.Cities
+	}
+	return nil
+}
+
+func (x *UserData) GetUnits() string {
+	if x != nil {
+		return x.Units
+	}
+	return ""
+}
+
+var File_weather_v1_weather_proto protoreflect.FileDescriptor
+
+const file_weather_v1_weather_proto_rawDesc = "" +
+	"\n" +
+	"\x18weather/v1/weather.proto\x12\n" +
+	"weather.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"'\n" +
+	"\x11GetCurrentRequest\x12\x12\n" +
+	"\x04city\x18\x01 \x01(\tR\x04city\"(\n" +
+	"\x12GetForecastRequest\x12\x12\n" +
+	"\x04city\x18\x01 \x01(\tR\x04city\"\x14\n" +
+	"\x12GetUserDataRequest\"*\n" +
+	"\x10AddCitiesRequest\x12\x16\n" +
+	"\x06cities\x18\x01 \x03(\tR\x06cities\"-\n" +
+	"\x13DeleteCitiesRequest\x12\x16\n" +
+	"\x06cities\x18\x01 \x03(\tR\x06cities\"'\n" +
+	"\x0fSetUnitsRequest\x12\x14\n" +
+	"\x05units\x18\x01 \x01(\tR\x05units\"-\n" +
+	"\x13WatchWeatherRequest\x12\x16\n" +
+	"\x06cities\x18\x01 \x03(\tR\x06cities\"\xa8\x02\n" +
+	"\rWeatherReport\x12\x12\n" +
+	"\x04city\x18\x01 \x01(\tR\x04city\x12\x14\n" +
+	"\x05units\x18\x02 \x01(\tR\x05units\x12 \n" +
+	"\vtemperature\x18\x03 \x01(\x01R\vtemperature\x12)\n" +
+	"\x10temperature_unit\x18\x04 \x01(\tR\x0ftemperatureUnit\x12\x1d\n" +
+	"\n" +
+	"wind_speed\x18\x05 \x01(\x01R\twindSpeed\x12&\n" +
+	"\x0fwind_speed_unit\x18\x06 \x01(\tR\rwindSpeedUnit\x12\x1c\n" +
+	"\tcondition\x18\a \x01(\tR\tcondition\x12;\n" +
+	"\vobserved_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"observedAt\"c\n" +
+	"\vForecastDay\x12\x12\n" +
+	"\x04date\x18\x01 \x01(\tR\x04date\x12\x10\n" +
+	"\x03min\x18\x02 \x01(\x01R\x03min\x12\x10\n" +
+	"\x03max\x18\x03 \x01(\x01R\x03max\x12\x1c\n" +
+	"\tcondition\x18\x04 \x01(\tR\tcondition\"\x92\x01\n" +
+	"\x0eForecastReport\x12\x12\n" +
+	"\x04city\x18\x01 \x01(\tR\x04city\x12\x14\n" +
+	"\x05units\x18\x02 \x01(\tR\x05units\x12)\n" +
+	"\x10temperature_unit\x18\x03 \x01(\tR\x0ftemperatureUnit\x12+\n" +
+	"\x04days\x18\x04 \x03(\v2\x17.weather.v1.ForecastDayR\x04days\"8\n" +
+	"\bUserData\x12\x16\n" +
+	"\x06cities\x18\x01 \x03(\tR\x06cities\x12\x14\n" +
+	"\x05units\x18\x02 \x01(\tR\x05units2\xfd\x03\n" +
+	"\x0eWeatherService\x12F\n" +
+	"\n" +
+	"GetCurrent\x12\x1d.weather.v1.GetCurrentRequest\x1a\x19.weather.v1.WeatherReport\x12I\n" +
+	"\vGetForecast\x12\x1e.weather.v1.GetForecastRequest\x1a\x1a.weather.v1.ForecastReport\x12C\n" +
+	"\vGetUserData\x12\x1e.weather.v1.GetUserDataRequest\x1a\x14.weather.v1.UserData\x12?\n" +
+	"\tAddCities\x12\x1c.weather.v1.AddCitiesRequest\x1a\x14.weather.v1.UserData\x12E\n" +
+	"\fDeleteCities\x12\x1f.weather.v1.DeleteCitiesRequest\x1a\x14.weather.v1.UserData\x12=\n" +
+	"\bSetUnits\x12\x1b.weather.v1.SetUnitsRequest\x1a\x14.weather.v1.UserData\x12L\n" +
+	"\fWatchWeather\x12\x1f.weather.v1.WatchWeatherRequest\x1a\x19.weather.v1.WeatherReport0\x01BJZHgithub.com/codyonesock/rest_weather/internal/grpcapi/weatherv1;weatherv1b\x06proto3"
+
+var (
+	file_weather_v1_weather_proto_rawDescOnce sync.Once
+	file_weather_v1_weather_proto_rawDescData []byte
+)
+
+func file_weather_v1_weather_proto_rawDescGZIP() []byte {
+	file_weather_v1_weather_proto_rawDescOnce.Do(func() {
+		file_weather_v1_weather_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_weather_v1_weather_proto_rawDesc), len(file_weather_v1_weather_proto_rawDesc)))
+	})
+	return file_weather_v1_weather_proto_rawDescData
+}
+
+var file_weather_v1_weather_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_weather_v1_weather_proto_goTypes = []any{
+	(*GetCurrentRequest)(nil),     // 0: weather.v1.GetCurrentRequest
+	(*GetForecastRequest)(nil),    // 1: weather.v1.GetForecastRequest
+	(*GetUserDataRequest)(nil),    // 2: weather.v1.GetUserDataRequest
+	(*AddCitiesRequest)(nil),      // 3: weather.v1.AddCitiesRequest
+	(*DeleteCitiesRequest)(nil),   // 4: weather.v1.DeleteCitiesRequest
+	(*SetUnitsRequest)(nil),       // 5: weather.v1.SetUnitsRequest
+	(*WatchWeatherRequest)(nil),   // 6: weather.v1.WatchWeatherRequest
+	(*WeatherReport)(nil),         // 7: weather.v1.WeatherReport
+	(*ForecastDay)(nil),           // 8: weather.v1.ForecastDay
+	(*ForecastReport)(nil),        // 9: weather.v1.ForecastReport
+	(*UserData)(nil),              // 10: weather.v1.UserData
+	(*timestamppb.Timestamp)(nil), // 11: google.protobuf.Timestamp
+}
+var file_weather_v1_weather_proto_depIdxs = []int32{
+	11, // 0: weather.v1.WeatherReport.observed_at:type_name -> google.protobuf.Timestamp
+	8,  // 1: weather.v1.ForecastReport.days:type_name -> weather.v1.ForecastDay
+	0,  // 2: weather.v1.WeatherService.GetCurrent:input_type -> weather.v1.GetCurrentRequest
+	1,  // 3: weather.v1.WeatherService.GetForecast:input_type -> weather.v1.GetForecastRequest
+	2,  // 4: weather.v1.WeatherService.GetUserData:input_type -> weather.v1.GetUserDataRequest
+	3,  // 5: weather.v1.WeatherService.AddCities:input_type -> weather.v1.AddCitiesRequest
+	4,  // 6: weather.v1.WeatherService.DeleteCities:input_type -> weather.v1.DeleteCitiesRequest
+	5,  // 7: weather.v1.WeatherService.SetUnits:input_type -> weather.v1.SetUnitsRequest
+	6,  // 8: weather.v1.WeatherService.WatchWeather:input_type -> weather.v1.WatchWeatherRequest
+	7,  // 9: weather.v1.WeatherService.GetCurrent:output_type -> weather.v1.WeatherReport
+	9,  // 10: weather.v1.WeatherService.GetForecast:output_type -> weather.v1.ForecastReport
+	10, // 11: weather.v1.WeatherService.GetUserData:output_type -> weather.v1.UserData
+	10, // 12: weather.v1.WeatherService.AddCities:output_type -> weather.v1.UserData
+	10, // 13: weather.v1.WeatherService.DeleteCities:output_type -> weather.v1.UserData
+	10, // 14: weather.v1.WeatherService.SetUnits:output_type -> weather.v1.UserData
+	7,  // 15: weather.v1.WeatherService.WatchWeather:output_type -> weather.v1.WeatherReport
+	9,  // [9:16] is the sub-list for method output_type
+	2,  // [2:9] is the sub-list for method input_type
+	2,  // [2:2] is the sub-list for extension type_name
+	2,  // [2:2] is the sub-list for extension extendee
+	0,  // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_weather_v1_weather_proto_init() }
+func file_weather_v1_weather_proto_init() {
+	if File_weather_v1_weather_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_weather_v1_weather_proto_rawDesc), len(file_weather_v1_weather_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_weather_v1_weather_proto_goTypes,
+		DependencyIndexes: file_weather_v1_weather_proto_depIdxs,
+		MessageInfos:      file_weather_v1_weather_proto_msgTypes,
+	}.Build()
+	File_weather_v1_weather_proto = out.File
+	file_weather_v1_weather_proto_goTypes = nil
+	file_weather_v1_weather_proto_depIdxs = nil
+}
@@ -0,0 +1,375 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: weather/v1/weather.proto
+
+// Package weather.v1 mirrors the v2 REST API. Reports are converted to the caller's saved units.
+
+package weatherv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	WeatherService_GetCurrent_FullMethodName   = "/weather.v1.WeatherService/GetCurrent"
+	WeatherService_GetForecast_FullMethodName  = "/weather.v1.WeatherService/GetForecast"
+	WeatherService_GetUserData_FullMethodName  = "/weather.v1.WeatherService/GetUserData"
+	WeatherService_AddCities_FullMethodName    = "/weather.v1.WeatherService/AddCities"
+	WeatherService_DeleteCities_FullMethodName = "/weather.v1.WeatherService/DeleteCities"
+	WeatherService_SetUnits_FullMethodName     = "/weather.v1.WeatherService/SetUnits"
+	WeatherService_WatchWeather_FullMethodName = "/weather.v1.WeatherService/WatchWeather"
+)
+
+// WeatherServiceClient is the client API for WeatherService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// WeatherService serves the same data as the REST routes.
+// Credentials go in the authorization ("Bearer <token>") or x-api-key metadata.
+type WeatherServiceClient interface {
+	// GetCurrent returns the current weather of a city. Requires read:weather.
+	GetCurrent(ctx context.Context, in *GetCurrentRequest, opts ...grpc.CallOption) (*WeatherReport, error)
+	// GetForecast returns the daily forecast of a city. Requires read:weather.
+	GetForecast(ctx context.Context, in *GetForecastRequest, opts ...grpc.CallOption) (*ForecastReport, error)
+	// GetUserData returns the saved cities and units. Requires read:weather.
+	GetUserData(ctx context.Context, in *GetUserDataRequest, opts ...grpc.CallOption) (*UserData, error)
+	// AddCities saves cities. Requires write:user.
+	AddCities(ctx context.Context, in *AddCitiesRequest, opts ...grpc.CallOption) (*UserData, error)
+	// DeleteCities removes saved cities. Requires write:user.
+	DeleteCities(ctx context.Context, in *DeleteCitiesRequest, opts ...grpc.CallOption) (*UserData, error)
+	// SetUnits saves the units, "metric" or "imperial". Requires write:user.
+	SetUnits(ctx context.Context, in *SetUnitsRequest, opts ...grpc.CallOption) (*UserData, error)
+	// WatchWeather sends the latest conditions of the cities, then every change. Requires read:weather.
+	WatchWeather(ctx context.Context, in *WatchWeatherRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WeatherReport], error)
+}
+
+type weatherServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWeatherServiceClient(cc grpc.ClientConnInterface) WeatherServiceClient {
+	return &weatherServiceClient{cc}
+}
+
+func (c *weatherServiceClient) GetCurrent(ctx context.Context, in *GetCurrentRequest, opts ...grpc.CallOption) (*WeatherReport, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WeatherReport)
+	err := c.cc.Invoke(ctx, WeatherService_GetCurrent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) GetForecast(ctx context.Context, in *GetForecastRequest, opts ...grpc.CallOption) (*ForecastReport, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ForecastReport)
+	err := c.cc.Invoke(ctx, WeatherService_GetForecast_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) GetUserData(ctx context.Context, in *GetUserDataRequest, opts ...grpc.CallOption) (*UserData, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserData)
+	err := c.cc.Invoke(ctx, WeatherService_GetUserData_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) AddCities(ctx context.Context, in *AddCitiesRequest, opts ...grpc.CallOption) (*UserData, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserData)
+	err := c.cc.Invoke(ctx, WeatherService_AddCities_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) DeleteCities(ctx context.Context, in *DeleteCitiesRequest, opts ...grpc.CallOption) (*UserData, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserData)
+	err := c.cc.Invoke(ctx, WeatherService_DeleteCities_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) SetUnits(ctx context.Context, in *SetUnitsRequest, opts ...grpc.CallOption) (*UserData, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserData)
+	err := c.cc.Invoke(ctx, WeatherService_SetUnits_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) WatchWeather(ctx context.Context, in *WatchWeatherRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WeatherReport], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &WeatherService_ServiceDesc.Streams[0], WeatherService_WatchWeather_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchWeatherRequest, WeatherReport]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WeatherService_WatchWeatherClient = grpc.ServerStreamingClient[WeatherReport]
+
+// WeatherServiceServer is the server API for WeatherService service.
+// All implementations must embed UnimplementedWeatherServiceServer
+// for forward compatibility.
+//
+// WeatherService serves the same data as the REST routes.
+// Credentials go in the authorization ("Bearer <token>") or x-api-key metadata.
+type WeatherServiceServer interface {
+	// GetCurrent returns the current weather of a city. Requires read:weather.
+	GetCurrent(context.Context, *GetCurrentRequest) (*WeatherReport, error)
+	// GetForecast returns the daily forecast of a city. Requires read:weather.
+	GetForecast(context.Context, *GetForecastRequest) (*ForecastReport, error)
+	// GetUserData returns the saved cities and units. Requires read:weather.
+	GetUserData(context.Context, *GetUserDataRequest) (*UserData, error)
+	// AddCities saves cities. Requires write:user.
+	AddCities(context.Context, *AddCitiesRequest) (*UserData, error)
+	// DeleteCities removes saved cities. Requires write:user.
+	DeleteCities(context.Context, *DeleteCitiesRequest) (*UserData, error)
+	// SetUnits saves the units, "metric" or "imperial". Requires write:user.
+	SetUnits(context.Context, *SetUnitsRequest) (*UserData, error)
+	// WatchWeather sends the latest conditions of the cities, then every change. Requires read:weather.
+	WatchWeather(*WatchWeatherRequest, grpc.ServerStreamingServer[WeatherReport]) error
+	mustEmbedUnimplementedWeatherServiceServer()
+}
+
+// UnimplementedWeatherServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedWeatherServiceServer struct{}
+
+func (UnimplementedWeatherServiceServer) GetCurrent(context.Context, *GetCurrentRequest) (*WeatherReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCurrent not implemented")
+}
+func (UnimplementedWeatherServiceServer) GetForecast(context.Context, *GetForecastRequest) (*ForecastReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetForecast not implemented")
+}
+func (UnimplementedWeatherServiceServer) GetUserData(context.Context, *GetUserDataRequest) (*UserData, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserData not implemented")
+}
+func (UnimplementedWeatherServiceServer) AddCities(context.Context, *AddCitiesRequest) (*UserData, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddCities not implemented")
+}
+func (UnimplementedWeatherServiceServer) DeleteCities(context.Context, *DeleteCitiesRequest) (*UserData, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCities not implemented")
+}
+func (UnimplementedWeatherServiceServer) SetUnits(context.Context, *SetUnitsRequest) (*UserData, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetUnits not implemented")
+}
+func (UnimplementedWeatherServiceServer) WatchWeather(*WatchWeatherRequest, grpc.ServerStreamingServer[WeatherReport]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchWeather not implemented")
+}
+func (UnimplementedWeatherServiceServer) mustEmbedUnimplementedWeatherServiceServer() {}
+func (UnimplementedWeatherServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeWeatherServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WeatherServiceServer will
+// result in compilation errors.
+type UnsafeWeatherServiceServer interface {
+	mustEmbedUnimplementedWeatherServiceServer()
+}
+
+func RegisterWeatherServiceServer(s grpc.ServiceRegistrar, srv WeatherServiceServer) {
+	// If the following call pancis, it indicates UnimplementedWeatherServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&WeatherService_ServiceDesc, srv)
+}
+
+func _WeatherService_GetCurrent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCurrentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).GetCurrent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_GetCurrent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).GetCurrent(ctx, req.(*GetCurrentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_GetForecast_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetForecastRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).GetForecast(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_GetForecast_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).GetForecast(ctx, req.(*GetForecastRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_GetUserData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).GetUserData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_GetUserData_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).GetUserData(ctx, req.(*GetUserDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_AddCities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddCitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).AddCities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_AddCities_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).AddCities(ctx, req.(*AddCitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_DeleteCities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).DeleteCities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_DeleteCities_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).DeleteCities(ctx, req.(*DeleteCitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_SetUnits_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetUnitsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).SetUnits(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_SetUnits_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).SetUnits(ctx, req.(*SetUnitsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_WatchWeather_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchWeatherRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WeatherServiceServer).WatchWeather(m, &grpc.GenericServerStream[WatchWeatherRequest, WeatherReport]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WeatherService_WatchWeatherServer = grpc.ServerStreamingServer[WeatherReport]
+
+// WeatherService_ServiceDesc is the grpc.ServiceDesc for WeatherService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WeatherService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "weather.v1.WeatherService",
+	HandlerType: (*WeatherServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetCurrent",
+			Handler:    _WeatherService_GetCurrent_Handler,
+		},
+		{
+			MethodName: "GetForecast",
+			Handler:    _WeatherService_GetForecast_Handler,
+		},
+		{
+			MethodName: "GetUserData",
+			Handler:    _WeatherService_GetUserData_Handler,
+		},
+		{
+			MethodName: "AddCities",
+			Handler:    _WeatherService_AddCities_Handler,
+		},
+		{
+			MethodName: "DeleteCities",
+			Handler:    _WeatherService_DeleteCities_Handler,
+		},
+		{
+			MethodName: "SetUnits",
+			Handler:    _WeatherService_SetUnits_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchWeather",
+			Handler:       _WeatherService_WatchWeather_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "weather/v1/weather.proto",
+}
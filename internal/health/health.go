@@ -0,0 +1,203 @@
+// Package health reports liveness and readiness for orchestrators.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+	checkTimeout      = 3 * time.Second
+)
+
+// err113 demands no dynamic errors!
+var (
+	// ErrUpstreamUnhealthy is returned when an upstream answers with a server error.
+	ErrUpstreamUnhealthy = errors.New("upstream unhealthy")
+	// ErrInvalidURL is returned when the URL to check has no host.
+	ErrInvalidURL = errors.New("invalid URL")
+)
+
+// CheckFunc reports whether a dependency is usable.
+type CheckFunc func(ctx context.Context) error
+
+// CheckResult is the outcome of a single dependency check.
+type CheckResult struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Response is the body returned by the health endpoints.
+type Response struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Service runs dependency checks and caches their results for CacheTTL.
+type Service struct {
+	mu       sync.Mutex
+	Logger   *zap.Logger
+	CacheTTL time.Duration
+	checks   []check
+	results  map[string]CheckResult
+}
+
+// NewHealthService creates a new instance of Service.
+func NewHealthService(l *zap.Logger, cacheTTL time.Duration) *Service {
+	return &Service{
+		mu:       sync.Mutex{},
+		Logger:   l,
+		CacheTTL: cacheTTL,
+		checks:   nil,
+		results:  map[string]CheckResult{},
+	}
+}
+
+// AddCheck registers a readiness check. It should be called before serving.
+func (s *Service) AddCheck(name string, fn CheckFunc) {
+	s.checks = append(s.checks, check{name: name, fn: fn})
+}
+
+// Liveness reports that the process is up.
+func (s *Service) Liveness(w http.ResponseWriter) error {
+	return writeResponse(w, http.StatusOK, Response{Status: statusOK, Checks: nil})
+}
+
+// Readiness runs the checks (or reuses cached results) and returns 503 if any fail.
+func (s *Service) Readiness(ctx context.Context, w http.ResponseWriter) error {
+	response := s.Check(ctx)
+
+	code := http.StatusOK
+	if response.Status != statusOK {
+		code = http.StatusServiceUnavailable
+	}
+
+	return writeResponse(w, code, response)
+}
+
+// Check returns the readiness of every dependency.
+func (s *Service) Check(ctx context.Context) Response {
+	response := Response{Status: statusOK, Checks: map[string]CheckResult{}}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+
+	for _, c := range s.checks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			result := s.run(ctx, c)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			response.Checks[c.name] = result
+			if result.Status != statusOK {
+				response.Status = statusUnavailable
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return response
+}
+
+// run returns the cached result for c, or runs it if the cache has expired.
+func (s *Service) run(ctx context.Context, c check) CheckResult {
+	s.mu.Lock()
+	cached, ok := s.results[c.name]
+	s.mu.Unlock()
+
+	if ok && time.Since(cached.CheckedAt) < s.CacheTTL {
+		return cached
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	result := CheckResult{Status: statusOK, Error: "", CheckedAt: time.Now()}
+	if err := c.fn(ctx); err != nil {
+		s.Logger.Warn("Readiness check failed", zap.String("check", c.name), zap.Error(err))
+		result.Status = statusUnavailable
+		result.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	s.results[c.name] = result
+	s.mu.Unlock()
+
+	return result
+}
+
+// HTTPCheck checks that the host of the URL returned by rawURL answers without a server error.
+// rawURL is called on every check so reloaded URLs are picked up.
+func HTTPCheck(client *http.Client, rawURL func() string) CheckFunc {
+	return func(ctx context.Context) error {
+		target := rawURL()
+
+		parsedURL, err := url.Parse(target)
+		if err != nil {
+			return fmt.Errorf("%w %q: %w", ErrInvalidURL, target, err)
+		}
+
+		if parsedURL.Host == "" {
+			return fmt.Errorf("%w %q: no host", ErrInvalidURL, target)
+		}
+
+		baseURL := parsedURL.Scheme + "://" + parsedURL.Host + "/"
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, baseURL, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to reach %s: %w", parsedURL.Host, err)
+		}
+
+		if err := res.Body.Close(); err != nil {
+			return fmt.Errorf("failed to close response body: %w", err)
+		}
+
+		if res.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("%w: %s returned %d", ErrUpstreamUnhealthy, parsedURL.Host, res.StatusCode)
+		}
+
+		return nil
+	}
+}
+
+// writeResponse encodes a health response with the given status code.
+func writeResponse(w http.ResponseWriter, code int, response Response) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+
+	return nil
+}
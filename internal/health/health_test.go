@@ -0,0 +1,94 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/health"
+)
+
+var errDown = errors.New("down")
+
+func TestReadiness(t *testing.T) {
+	t.Parallel()
+
+	healthService := health.NewHealthService(zap.NewNop(), time.Minute)
+	healthService.AddCheck("storage", func(context.Context) error { return nil })
+	healthService.AddCheck("weather_api", func(context.Context) error { return errDown })
+
+	rec := httptest.NewRecorder()
+	if err := healthService.Readiness(t.Context(), rec); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status code %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	var response health.Response
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if response.Checks["storage"].Status != "ok" || response.Checks["weather_api"].Error != "down" {
+		t.Errorf("unexpected check breakdown: %+v", response.Checks)
+	}
+}
+
+func TestReadinessCachesResults(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	healthService := health.NewHealthService(zap.NewNop(), time.Minute)
+	healthService.AddCheck("geocode_api", func(context.Context) error {
+		calls++
+		return nil
+	})
+
+	for range 3 {
+		if response := healthService.Check(t.Context()); response.Status != "ok" {
+			t.Errorf("expected ok, got %v", response.Status)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("expected the check to run once, got %d", calls)
+	}
+}
+
+func TestHTTPCheck(t *testing.T) {
+	t.Parallel()
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer up.Close()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	if err := health.HTTPCheck(up.Client(), func() string { return up.URL + "/v1/search" })(t.Context()); err != nil {
+		t.Errorf("expected a reachable host to pass, got %v", err)
+	}
+
+	if err := health.HTTPCheck(down.Client(), func() string { return down.URL })(t.Context()); err == nil {
+		t.Error("expected a 5xx host to fail")
+	}
+
+	for _, rawURL := range []string{"/v1/search", "://bad"} {
+		err := health.HTTPCheck(up.Client(), func() string { return rawURL })(t.Context())
+		if !errors.Is(err, health.ErrInvalidURL) || strings.Contains(err.Error(), "%!") {
+			t.Errorf("expected %v for %q, got %v", health.ErrInvalidURL, rawURL, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Package ical writes RFC 5545 calendars of all-day events.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxLineOctets is the longest content line allowed before folding (RFC 5545 3.1).
+	maxLineOctets = 75
+	dateFormat    = "20060102"
+	stampFormat   = "20060102T150405Z"
+)
+
+// ContentType is the media type of a calendar.
+const ContentType = "text/calendar; charset=utf-8"
+
+// Event is an all-day VEVENT. UID must stay the same across feeds so clients update the event.
+type Event struct {
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+}
+
+// Calendar is a VCALENDAR published as a subscription feed.
+type Calendar struct {
+	ProdID string
+	Name   string
+	Stamp  time.Time
+	Events []Event
+}
+
+// Encode writes c to w with CRLF line endings, escaped text and folded lines.
+func Encode(w io.Writer, c Calendar) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeFolded(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", c.ProdID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+
+	if c.Name != "" {
+		line("X-WR-CALNAME", escape(c.Name))
+	}
+
+	stamp := c.Stamp.UTC().Format(stampFormat)
+
+	for _, event := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", event.UID)
+		line("DTSTAMP", stamp)
+		line("DTSTART;VALUE=DATE", event.Date.Format(dateFormat))
+		line("DTEND;VALUE=DATE", event.Date.AddDate(0, 0, 1).Format(dateFormat))
+		line("SUMMARY", escape(event.Summary))
+
+		if event.Description != "" {
+			line("DESCRIPTION", escape(event.Description))
+		}
+
+		line("TRANSP", "TRANSPARENT")
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write calendar: %w", err)
+	}
+
+	return nil
+}
+
+// escape escapes TEXT values (RFC 5545 3.3.11).
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// writeFolded writes a content line, folding it at maxLineOctets without splitting UTF-8 characters.
+func writeFolded(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		_, _ = w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts towards the limit.
+		limit = maxLineOctets - 1
+	}
+
+	_, _ = w.WriteString(line + "\r\n")
+}
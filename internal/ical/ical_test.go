@@ -0,0 +1,83 @@
+package ical_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codyonesock/rest_weather/internal/ical"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+
+	err := ical.Encode(&b, ical.Calendar{
+		ProdID: "-//rest_weather//Forecast//EN",
+		Name:   "halifax forecast",
+		Stamp:  time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC),
+		Events: []ical.Event{{
+			UID:         "2026-10-18-halifax@rest-weather",
+			Date:        time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC),
+			Summary:     "Rain, 8.1 to 14.2 °C",
+			Description: "Low 8.1 °C; high 14.2 °C",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"UID:2026-10-18-halifax@rest-weather\r\n",
+		"DTSTAMP:20261018T120000Z\r\n",
+		"DTSTART;VALUE=DATE:20261018\r\nDTEND;VALUE=DATE:20261019\r\n",
+		`SUMMARY:Rain\, 8.1 to 14.2 °C` + "\r\n",
+		`DESCRIPTION:Low 8.1 °C\; high 14.2 °C` + "\r\n",
+		"END:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("expected calendar to contain %q, got %q", want, b.String())
+		}
+	}
+}
+
+func TestEncodeFoldsLongLines(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+
+	err := ical.Encode(&b, ical.Calendar{
+		ProdID: "-//rest_weather//Forecast//EN",
+		Name:   "",
+		Stamp:  time.Now(),
+		Events: []ical.Event{{
+			UID:         "long@rest-weather",
+			Date:        time.Now(),
+			Summary:     strings.Repeat("°C ", 60),
+			Description: "",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var unfolded strings.Builder
+
+	for i, line := range strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("expected lines of at most 75 octets, line %d has %d", i, len(line))
+		}
+
+		if strings.HasPrefix(line, " ") {
+			unfolded.WriteString(line[1:])
+		} else {
+			unfolded.WriteString("\n" + line)
+		}
+	}
+
+	if !strings.Contains(unfolded.String(), "SUMMARY:"+strings.Repeat("°C ", 60)) {
+		t.Errorf("expected folded summary to unfold to the original, got %q", unfolded.String())
+	}
+}
@@ -2,27 +2,45 @@
 package logger
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
-// CreateLogger initializes a logger with the specified log level.
-func CreateLogger(logLevel string) (*zap.Logger, error) {
-	level := zap.NewAtomicLevel()
+// ErrInvalidLevel is returned for log levels that aren't supported.
+var ErrInvalidLevel = errors.New("invalid log level")
 
-	switch logLevel {
+// ParseLevel converts a LOG_LEVEL value into a zap level.
+func ParseLevel(logLevel string) (zapcore.Level, error) {
+	switch strings.ToUpper(logLevel) {
 	case "DEBUG":
-		level.SetLevel(zap.DebugLevel)
+		return zap.DebugLevel, nil
 	case "INFO":
-		level.SetLevel(zap.InfoLevel)
+		return zap.InfoLevel, nil
+	case "WARN":
+		return zap.WarnLevel, nil
 	case "ERROR":
-		level.SetLevel(zap.ErrorLevel)
+		return zap.ErrorLevel, nil
 	case "PANIC":
-		level.SetLevel(zap.PanicLevel)
+		return zap.PanicLevel, nil
+	case "FATAL":
+		return zap.FatalLevel, nil
 	default:
-		level.SetLevel(zap.InfoLevel)
+		return zap.InfoLevel, fmt.Errorf("%w: %s", ErrInvalidLevel, logLevel)
 	}
+}
+
+// CreateLogger initializes a logger with the specified log level.
+// The returned AtomicLevel can be used to change the level at runtime.
+func CreateLogger(logLevel string) (*zap.Logger, zap.AtomicLevel, error) {
+	level := zap.NewAtomicLevel()
+
+	// Unknown levels fall back to INFO.
+	parsedLevel, _ := ParseLevel(logLevel)
+	level.SetLevel(parsedLevel)
 
 	cfg := zap.Config{
 		Level:            level,
@@ -35,8 +53,8 @@ func CreateLogger(logLevel string) (*zap.Logger, error) {
 
 	logger, err := cfg.Build()
 	if err != nil {
-		return nil, fmt.Errorf("failed to build logger: %w", err)
+		return nil, level, fmt.Errorf("failed to build logger: %w", err)
 	}
 
-	return logger, nil
+	return logger, level, nil
 }
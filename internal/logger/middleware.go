@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader carries the correlation ID between clients, this server and upstream APIs.
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+)
+
+// WithContext returns a copy of ctx carrying l.
+func WithContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// FromContext returns the request-scoped logger in ctx, or fallback if there isn't one.
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if l, ok := ctx.Value(loggerKey).(*zap.Logger); ok {
+		return l
+	}
+
+	return fallback
+}
+
+// RequestIDFromContext returns the request ID stored by RequestMiddleware, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// RequestMiddleware assigns or propagates X-Request-ID, stores a logger tagged with it
+// in the request context, and writes one access log line per request.
+func RequestMiddleware(base *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get(RequestIDHeader)
+			if !validRequestID(requestID) {
+				requestID = newRequestID()
+			}
+
+			fields := []zap.Field{zap.String("request_id", requestID)}
+			if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.HasTraceID() {
+				fields = append(fields, zap.String("trace_id", spanContext.TraceID().String()))
+			}
+
+			requestLogger := base.With(fields...)
+
+			ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+			ctx = WithContext(ctx, requestLogger)
+
+			w.Header().Set(RequestIDHeader, requestID)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			route := ""
+			if rctx := chi.RouteContext(ctx); rctx != nil {
+				route = rctx.RoutePattern()
+			}
+
+			requestLogger.Info("Request handled",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("route", route),
+				zap.Int("status", status),
+				zap.Int("bytes", ww.BytesWritten()),
+				zap.Duration("duration", time.Since(start)),
+				zap.String("remote_addr", r.RemoteAddr),
+				zap.String("user_agent", r.UserAgent()),
+			)
+		})
+	}
+}
+
+// validRequestID only accepts short printable IDs so clients can't inject into logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newRequestID returns a random 16 byte hex ID.
+func newRequestID() string {
+	b := make([]byte, 16) //nolint:mnd // 128 bits
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,77 @@
+package logger_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/codyonesock/rest_weather/internal/logger"
+)
+
+func setupRouter(t *testing.T) (*chi.Mux, *observer.ObservedLogs) {
+	t.Helper()
+
+	core, logs := observer.New(zap.InfoLevel)
+
+	r := chi.NewRouter()
+	r.Use(logger.RequestMiddleware(zap.New(core)))
+	r.Get("/weather/{city}", func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context(), zap.NewNop()).Info("In handler")
+		_, _ = w.Write([]byte("sunny"))
+	})
+
+	return r, logs
+}
+
+func TestRequestMiddlewarePropagatesRequestID(t *testing.T) {
+	t.Parallel()
+
+	r, logs := setupRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/weather/halifax", nil)
+	req.Header.Set(logger.RequestIDHeader, "abc-123")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Header().Get(logger.RequestIDHeader) != "abc-123" {
+		t.Errorf("expected request id to be echoed, got %v", rec.Header().Get(logger.RequestIDHeader))
+	}
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("expected handler and access log entries, got %d", len(entries))
+	}
+
+	for _, entry := range entries {
+		if entry.ContextMap()["request_id"] != "abc-123" {
+			t.Errorf("expected %q to carry the request id, got %v", entry.Message, entry.ContextMap())
+		}
+	}
+
+	access := entries[1].ContextMap()
+	if access["route"] != "/weather/{city}" || access["status"] != int64(http.StatusOK) || access["bytes"] != int64(5) {
+		t.Errorf("unexpected access log fields: %v", access)
+	}
+}
+
+func TestRequestMiddlewareGeneratesRequestID(t *testing.T) {
+	t.Parallel()
+
+	r, _ := setupRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/weather/halifax", nil)
+	req.Header.Set(logger.RequestIDHeader, "bad id\nwith newline")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	id := rec.Header().Get(logger.RequestIDHeader)
+	if len(id) != 32 {
+		t.Errorf("expected a generated 32 char request id, got %q", id)
+	}
+}
@@ -0,0 +1,150 @@
+// Package metrics exposes Prometheus metrics for the server, upstream calls, caches and storage.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "weather"
+
+// Service holds the collectors. A nil *Service is valid and records nothing,
+// so packages can treat metrics as optional.
+type Service struct {
+	Registry         *prometheus.Registry
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	upstreamDuration *prometheus.HistogramVec
+	upstreamErrors   *prometheus.CounterVec
+	cacheRequests    *prometheus.CounterVec
+	storageDuration  *prometheus.HistogramVec
+}
+
+// NewMetricsService creates a new instance of Service with its own registry.
+func NewMetricsService() *Service {
+	s := &Service{
+		Registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{ //nolint:exhaustruct // optional fields
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, chi route pattern and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{ //nolint:exhaustruct // optional fields
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, chi route pattern and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{ //nolint:exhaustruct // optional fields
+			Namespace: namespace,
+			Name:      "upstream_request_duration_seconds",
+			Help:      "Latency of upstream API calls by host.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"host"}),
+		upstreamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{ //nolint:exhaustruct // optional fields
+			Namespace: namespace,
+			Name:      "upstream_errors_total",
+			Help:      "Failed upstream API calls by host.",
+		}, []string{"host"}),
+		cacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{ //nolint:exhaustruct // optional fields
+			Namespace: namespace,
+			Name:      "cache_requests_total",
+			Help:      "Cache lookups by cache name and result (hit or miss).",
+		}, []string{"cache", "result"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{ //nolint:exhaustruct // optional fields
+			Namespace: namespace,
+			Name:      "storage_operation_duration_seconds",
+			Help:      "Storage read and write latency by operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+	}
+
+	s.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}), //nolint:exhaustruct // defaults
+		s.requests,
+		s.requestDuration,
+		s.upstreamDuration,
+		s.upstreamErrors,
+		s.cacheRequests,
+		s.storageDuration,
+	)
+
+	return s
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (s *Service) Handler() http.Handler {
+	return promhttp.HandlerFor(s.Registry, promhttp.HandlerOpts{}) //nolint:exhaustruct // defaults
+}
+
+// Middleware records the count and latency of every request by chi route pattern.
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		labels := prometheus.Labels{"method": r.Method, "route": route, "status": strconv.Itoa(status)}
+		s.requests.With(labels).Inc()
+		s.requestDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveUpstream records an upstream call to host.
+func (s *Service) ObserveUpstream(host string, duration time.Duration, failed bool) {
+	if s == nil {
+		return
+	}
+
+	s.upstreamDuration.WithLabelValues(host).Observe(duration.Seconds())
+
+	if failed {
+		s.upstreamErrors.WithLabelValues(host).Inc()
+	}
+}
+
+// ObserveStorage records the duration of a storage operation.
+func (s *Service) ObserveStorage(operation string, duration time.Duration) {
+	if s == nil {
+		return
+	}
+
+	s.storageDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+// CacheHit records a cache hit.
+func (s *Service) CacheHit(name string) {
+	if s == nil {
+		return
+	}
+
+	s.cacheRequests.WithLabelValues(name, "hit").Inc()
+}
+
+// CacheMiss records a cache miss.
+func (s *Service) CacheMiss(name string) {
+	if s == nil {
+		return
+	}
+
+	s.cacheRequests.WithLabelValues(name, "miss").Inc()
+}
@@ -0,0 +1,58 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/codyonesock/rest_weather/internal/metrics"
+)
+
+func TestMiddlewareRecordsRoutePattern(t *testing.T) {
+	t.Parallel()
+
+	metricsService := metrics.NewMetricsService()
+
+	r := chi.NewRouter()
+	r.Use(metricsService.Middleware)
+	r.Get("/weather/{city}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	r.Handle("/metrics", metricsService.Handler())
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/weather/halifax", nil))
+
+	metricsService.ObserveUpstream("api.open-meteo.com", time.Millisecond, true)
+	metricsService.CacheHit("geocode")
+	metricsService.ObserveStorage("load", time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`weather_http_requests_total{method="GET",route="/weather/{city}",status="418"} 1`,
+		`weather_upstream_errors_total{host="api.open-meteo.com"} 1`,
+		`weather_cache_requests_total{cache="geocode",result="hit"} 1`,
+		`weather_storage_operation_duration_seconds_count{operation="load"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q", want)
+		}
+	}
+}
+
+func TestNilServiceIsNoop(t *testing.T) {
+	t.Parallel()
+
+	var metricsService *metrics.Service
+
+	metricsService.ObserveUpstream("api.open-meteo.com", time.Millisecond, false)
+	metricsService.ObserveStorage("save", time.Millisecond)
+	metricsService.CacheHit("weather")
+	metricsService.CacheMiss("weather")
+}
@@ -0,0 +1,225 @@
+// Package openapi serves the OpenAPI document of the API and a Swagger UI page for it.
+//
+// api.json describes every API route once, under x-versioned-paths without a version prefix.
+// The served document gets a /v1 and a /v2 copy of each and the deprecated unversioned alias:
+//   - operationIds and tags get the version ("v1GetWeather", "v1"); aliases keep the plain ID
+//     and are tagged "legacy".
+//   - x-v2 on an operation replaces its keys in the /v2 copy, response by response for responses.
+//   - Aliases are deprecated, add the Deprecation, Sunset and Link headers to their 2xx
+//     responses and can answer 406. x-same-in-v2 marks operations whose response doesn't
+//     depend on the version, so their description doesn't point at the v2 Accept header.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+)
+
+//go:embed api.json
+var source []byte
+
+var spec = mustBuild(source)
+
+const (
+	versionedPathsKey = "x-versioned-paths"
+	v2Key             = "x-v2"
+	sameInV2Key       = "x-same-in-v2"
+
+	aliasNote       = " Deprecated alias of the `/v1` route."
+	aliasNoteAccept = " Deprecated alias of the `/v1` route; send `Accept: application/vnd.weather.v2+json` for the v2 response."
+)
+
+// err113 demands no dynamic errors!
+var errInvalidPathItem = errors.New("invalid path item in api.json")
+
+// docsPage loads Swagger UI from a CDN and points it at /openapi.json.
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Weather REST API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
+
+type object = map[string]any
+
+// Spec returns the OpenAPI 3 document.
+func Spec() []byte {
+	return spec
+}
+
+// SpecHandler serves the OpenAPI document.
+func SpecHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(spec)
+}
+
+// DocsHandler serves the Swagger UI page.
+func DocsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(docsPage))
+}
+
+// mustBuild expands the versioned paths of src. src is embedded, so a failure is a bug caught by the tests.
+func mustBuild(src []byte) []byte {
+	doc, err := build(src)
+	if err != nil {
+		panic(err)
+	}
+
+	return doc
+}
+
+func build(src []byte) ([]byte, error) {
+	var doc object
+	if err := json.Unmarshal(src, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode api.json: %w", err)
+	}
+
+	paths, _ := doc["paths"].(object)
+	versioned, _ := doc[versionedPathsKey].(object)
+	delete(doc, versionedPathsKey)
+
+	for path, item := range versioned {
+		operations, ok := item.(object)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errInvalidPathItem, path)
+		}
+
+		v1, v2, alias := object{}, object{}, object{}
+
+		for method, value := range operations {
+			op, ok := value.(object)
+			if id, _ := op["operationId"].(string); !ok || id == "" {
+				return nil, fmt.Errorf("%w: %s %s", errInvalidPathItem, method, path)
+			}
+
+			v1[method] = versionedOperation(op, "v1", nil)
+			v2[method] = versionedOperation(op, "v2", op[v2Key])
+			alias[method] = aliasOperation(op)
+		}
+
+		paths["/v1"+path], paths["/v2"+path], paths[path] = v1, v2, alias
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the document: %w", err)
+	}
+
+	return append(out, '\n'), nil
+}
+
+// versionedOperation returns a copy of op for version with override applied.
+func versionedOperation(op object, version string, override any) object {
+	out := operation(op)
+	id, _ := out["operationId"].(string)
+	out["operationId"] = version + strings.ToUpper(id[:1]) + id[1:]
+	out["tags"] = appendTag(out["tags"], version)
+
+	if override, ok := override.(object); ok {
+		for key, value := range deepCopy(override).(object) { //nolint:forcetypeassert // copy of an object
+			base, isObject := out[key].(object)
+			values, isOverride := value.(object)
+
+			if key == "responses" && isObject && isOverride {
+				maps.Copy(base, values)
+				continue
+			}
+
+			out[key] = value
+		}
+	}
+
+	return out
+}
+
+// aliasOperation returns the deprecated unversioned copy of op.
+func aliasOperation(op object) object {
+	out := operation(op)
+	out["tags"] = appendTag(out["tags"], "legacy")
+	out["deprecated"] = true
+
+	note := aliasNoteAccept
+	if same, _ := op[sameInV2Key].(bool); same {
+		note = aliasNote
+	}
+
+	description, _ := out["description"].(string)
+	out["description"] = strings.TrimSpace(description + note)
+
+	responses, _ := out["responses"].(object)
+	for code, value := range responses {
+		response, ok := value.(object)
+		if !ok || !strings.HasPrefix(code, "2") || response["$ref"] != nil {
+			continue
+		}
+
+		headers, _ := response["headers"].(object)
+		if headers == nil {
+			headers = object{}
+			response["headers"] = headers
+		}
+
+		for _, name := range []string{"Deprecation", "Sunset", "Link"} {
+			headers[name] = object{"$ref": "#/components/headers/" + name}
+		}
+	}
+
+	if responses != nil {
+		responses["406"] = object{"$ref": "#/components/responses/NotAcceptable"}
+	}
+
+	return out
+}
+
+// operation returns a deep copy of op without the source-only extensions.
+func operation(op object) object {
+	out := deepCopy(op).(object) //nolint:forcetypeassert // copy of an object
+	delete(out, v2Key)
+	delete(out, sameInV2Key)
+
+	return out
+}
+
+func appendTag(tags any, tag string) []any {
+	list, _ := tags.([]any)
+	return append(append([]any{}, list...), tag)
+}
+
+func deepCopy(v any) any {
+	switch v := v.(type) {
+	case object:
+		out := make(object, len(v))
+		for key, value := range v {
+			out[key] = deepCopy(value)
+		}
+
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, value := range v {
+			out[i] = deepCopy(value)
+		}
+
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,160 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codyonesock/rest_weather/internal/openapi"
+)
+
+type operation struct {
+	OperationID string         `json:"operationId"`
+	Responses   map[string]any `json:"responses"`
+}
+
+type document struct {
+	OpenAPI string                          `json:"openapi"`
+	Paths   map[string]map[string]operation `json:"paths"`
+}
+
+func TestSpecOperations(t *testing.T) {
+	t.Parallel()
+
+	var doc document
+	if err := json.Unmarshal(openapi.Spec(), &doc); err != nil {
+		t.Fatalf("expected valid JSON, got %v", err)
+	}
+
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("expected an OpenAPI 3 document, got version %q", doc.OpenAPI)
+	}
+
+	seen := map[string]bool{}
+
+	for path, methods := range doc.Paths {
+		for method, op := range methods {
+			if op.OperationID == "" {
+				t.Errorf("expected an operationId for %s %s", method, path)
+			}
+
+			if seen[op.OperationID] {
+				t.Errorf("expected unique operationIds, %q is repeated", op.OperationID)
+			}
+
+			seen[op.OperationID] = true
+
+			if len(op.Responses) == 0 {
+				t.Errorf("expected responses for %s %s", method, path)
+			}
+		}
+	}
+}
+
+func TestSpecRefsResolve(t *testing.T) {
+	t.Parallel()
+
+	var doc map[string]any
+	if err := json.Unmarshal(openapi.Spec(), &doc); err != nil {
+		t.Fatalf("expected valid JSON, got %v", err)
+	}
+
+	var walk func(v any)
+
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok && resolve(doc, ref) == nil {
+				t.Errorf("expected %s to resolve", ref)
+			}
+
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+
+	walk(doc)
+}
+
+// resolve follows a local JSON pointer such as #/components/schemas/UserData.
+func resolve(doc map[string]any, ref string) any {
+	var node any = doc
+
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := node.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		node = m[part]
+	}
+
+	return node
+}
+
+func TestVersionedCopies(t *testing.T) {
+	t.Parallel()
+
+	var doc struct {
+		Paths map[string]map[string]map[string]any `json:"paths"`
+	}
+
+	if err := json.Unmarshal(openapi.Spec(), &doc); err != nil {
+		t.Fatalf("expected valid JSON, got %v", err)
+	}
+
+	if strings.Contains(string(openapi.Spec()), `"x-`) {
+		t.Error("expected no source extensions in the served document")
+	}
+
+	v1, v2, alias := doc.Paths["/v1/weather/{city}"]["get"], doc.Paths["/v2/weather/{city}"]["get"], doc.Paths["/weather/{city}"]["get"]
+	if v1 == nil || v2 == nil || alias == nil {
+		t.Fatalf("expected /v1, /v2 and unversioned copies of /weather/{city}, got %v", doc.Paths["/weather/{city}"])
+	}
+
+	if v1["operationId"] != "v1GetCurrentWeather" || v2["operationId"] != "v2GetCurrentWeather" || alias["operationId"] != "getCurrentWeather" {
+		t.Errorf("expected versioned operationIds, got %v, %v and %v", v1["operationId"], v2["operationId"], alias["operationId"])
+	}
+
+	schema := func(op map[string]any) string {
+		b, _ := json.Marshal(op["responses"].(map[string]any)["200"]) //nolint:forcetypeassert // checked by the decode
+		return string(b)
+	}
+
+	if !strings.Contains(schema(v1), "CurrentWeatherResponse") || !strings.Contains(schema(v2), "WeatherReport") {
+		t.Errorf("expected the v2 override to replace the response, got %s and %s", schema(v1), schema(v2))
+	}
+
+	if alias["deprecated"] != true || !strings.Contains(schema(alias), "Sunset") || v1["deprecated"] != nil {
+		t.Errorf("expected only the alias to be deprecated with a Sunset header, got %v", alias)
+	}
+
+	if _, ok := alias["responses"].(map[string]any)["406"]; !ok { //nolint:forcetypeassert // checked by the decode
+		t.Error("expected the alias to document 406")
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	openapi.SpecHandler(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("expected application/json, got %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	openapi.DocsHandler(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+	if !strings.Contains(rec.Body.String(), `url: "/openapi.json"`) {
+		t.Error("expected the docs page to load /openapi.json")
+	}
+}
@@ -0,0 +1,123 @@
+// Package prewarm keeps the weather cache warm for every saved city, so their requests don't
+// wait on the upstream APIs.
+package prewarm
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/storage"
+	"github.com/codyonesock/rest_weather/internal/weather"
+)
+
+// Service handles dependencies and config.
+type Service struct {
+	Logger  *zap.Logger
+	Weather *weather.Service
+	Cities  storage.CityStore
+
+	// Interval is the time between runs, each delayed by up to Jitter more so that several
+	// instances don't hit the upstream together. At most Concurrency cities refresh at once.
+	Interval    time.Duration
+	Jitter      time.Duration
+	Concurrency int
+}
+
+// NewPrewarmService creates a new instance of Service.
+func NewPrewarmService(
+	l *zap.Logger,
+	weatherService *weather.Service,
+	cities storage.CityStore,
+	interval, jitter time.Duration,
+	concurrency int,
+) *Service {
+	return &Service{
+		Logger:      l,
+		Weather:     weatherService,
+		Cities:      cities,
+		Interval:    interval,
+		Jitter:      jitter,
+		Concurrency: concurrency,
+	}
+}
+
+// Run refreshes the saved cities right away and then every Interval plus jitter until ctx is done.
+// It returns once the refreshes in flight have stopped.
+func (s *Service) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			s.RefreshAll(ctx)
+			timer.Reset(s.next())
+		}
+	}
+}
+
+// RefreshAll refreshes every saved city, Concurrency at a time, and returns when all are done.
+// Failures are logged; they only mean the next request for the city fetches it itself.
+func (s *Service) RefreshAll(ctx context.Context) {
+	cities, err := s.Cities.SavedCities(ctx)
+	if err != nil {
+		s.Logger.Error("Failed to list saved cities to prewarm", zap.Error(err))
+		return
+	}
+
+	start := time.Now()
+	slots := make(chan struct{}, max(s.Concurrency, 1))
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+	)
+
+dispatch:
+	for _, city := range cities {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case slots <- struct{}{}:
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			if _, _, err := s.Weather.Refresh(ctx, city); err != nil {
+				s.Logger.Warn("Failed to prewarm city", zap.String("city", city), zap.Error(err))
+
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	s.Logger.Debug("Prewarmed saved cities",
+		zap.Int("cities", len(cities)),
+		zap.Int("failed", failed),
+		zap.Duration("duration", time.Since(start)),
+	)
+}
+
+// next returns the delay before the next run.
+func (s *Service) next() time.Duration {
+	if s.Jitter <= 0 {
+		return s.Interval
+	}
+
+	return s.Interval + rand.N(s.Jitter) //nolint:gosec // jitter doesn't need a secure source
+}
@@ -0,0 +1,108 @@
+package prewarm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/cache"
+	"github.com/codyonesock/rest_weather/internal/prewarm"
+	"github.com/codyonesock/rest_weather/internal/weather"
+	"github.com/codyonesock/rest_weather/internal/weathertest"
+)
+
+// setupPrewarm returns a prewarm service for cities, refreshing concurrency at a time.
+func setupPrewarm(
+	t *testing.T, cities []string, concurrency int,
+) (*prewarm.Service, *weather.Service, *weathertest.Upstream) {
+	t.Helper()
+
+	upstream := weathertest.NewUpstream(t)
+	upstream.SetDelay(5 * time.Millisecond)
+
+	weatherService, storageService := upstream.NewWeatherService(t)
+	weatherService.WeatherCache = cache.NewCache[[]byte]("weather", time.Hour, nil)
+
+	if _, err := weatherService.AddCities(context.Background(), cities); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	return prewarm.NewPrewarmService(zap.NewNop(), weatherService, storageService, time.Hour, 0, concurrency),
+		weatherService, upstream
+}
+
+func TestRefreshAllWarmsTheCache(t *testing.T) {
+	t.Parallel()
+
+	prewarmService, weatherService, upstream := setupPrewarm(t, []string{"halifax"}, 4)
+
+	prewarmService.RefreshAll(context.Background())
+
+	if _, err := weatherService.CurrentWeather(context.Background(), "halifax"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := weatherService.Forecast(context.Background(), "halifax"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if requests := upstream.Requests(); requests["/current"] != 1 || requests["/forecast"] != 1 {
+		t.Errorf("expected requests to be served from the prewarmed cache, got %v", requests)
+	}
+
+	// A later run refreshes the cached entries instead of reading them.
+	prewarmService.RefreshAll(context.Background())
+
+	if requests := upstream.Requests(); requests["/current"] != 2 || requests["/forecast"] != 2 {
+		t.Errorf("expected a second refresh to reach the upstream, got %v", requests)
+	}
+}
+
+func TestRefreshAllBoundsConcurrency(t *testing.T) {
+	t.Parallel()
+
+	prewarmService, _, upstream := setupPrewarm(t, []string{"halifax", "berlin", "tokyo", "lima", "oslo", "cairo"}, 2)
+
+	prewarmService.RefreshAll(context.Background())
+
+	if requests := upstream.Requests(); requests["/current"] != 6 || requests["/forecast"] != 6 {
+		t.Errorf("expected every city to be refreshed, got %v", requests)
+	}
+
+	if maxInFlight := upstream.MaxInFlight(); maxInFlight > 2 {
+		t.Errorf("expected at most 2 requests in flight, got %d", maxInFlight)
+	}
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	t.Parallel()
+
+	prewarmService, _, upstream := setupPrewarm(t, []string{"halifax"}, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		prewarmService.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for upstream.Requests()["/forecast"] == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the first run to start right away")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Run to return after cancel")
+	}
+}
@@ -0,0 +1,156 @@
+// Package ratelimit limits requests per API key, user or client IP with token buckets.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codyonesock/rest_weather/internal/auth"
+)
+
+// sweepInterval is how often idle, full buckets are dropped.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Limiter is a set of token buckets sharing one rate and burst.
+// A nil *Limiter or a zero rate allows everything.
+type Limiter struct {
+	mu        sync.Mutex
+	Rate      float64
+	Burst     int
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewLimiter creates a new instance of Limiter refilling rate tokens per second up to burst.
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		mu:        sync.Mutex{},
+		Rate:      rate,
+		Burst:     max(burst, 1),
+		buckets:   map[string]*bucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket of key.
+func (l *Limiter) Allow(key string, now time.Time) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+
+	result := Result{Allowed: b.tokens >= 1, Limit: l.Burst, Remaining: 0, Reset: 0, RetryAfter: 0}
+
+	if result.Allowed {
+		b.tokens--
+	} else {
+		result.RetryAfter = l.duration(1 - b.tokens)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = l.duration(float64(l.Burst) - b.tokens)
+
+	return result
+}
+
+// duration is how long it takes to refill tokens.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.Rate * float64(time.Second))
+}
+
+// sweep drops buckets that have refilled completely, they behave the same as new ones. Callers hold l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.Rate >= float64(l.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware sets the RateLimit-* headers and answers 429 with Retry-After once the bucket is empty.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	if l == nil || l.Rate <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := l.Allow(ClientKey(r), time.Now())
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientKey identifies who a request is charged to: the user, the API key or the client IP.
+func ClientKey(r *http.Request) string {
+	return Key(r.Context(), r.RemoteAddr)
+}
+
+// Key identifies who a call authenticated in ctx from remoteAddr is charged to, like ClientKey.
+func Key(ctx context.Context, remoteAddr string) string {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		if principal.Subject != "" {
+			return "sub:" + principal.Subject
+		}
+
+		if principal.KeyID != "" && principal.KeyID != auth.AnonymousID {
+			return "key:" + principal.KeyID
+		}
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// ceilSeconds rounds d up to whole seconds for the headers.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,141 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codyonesock/rest_weather/internal/auth"
+	"github.com/codyonesock/rest_weather/internal/ratelimit"
+)
+
+func setupHandler(limiter *ratelimit.Limiter) http.Handler {
+	return limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func withKey(r *http.Request, keyID string) *http.Request {
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{KeyID: keyID, Subject: "", Scopes: nil})
+	return r.WithContext(ctx)
+}
+
+func TestAllowRefills(t *testing.T) {
+	t.Parallel()
+
+	limiter := ratelimit.NewLimiter(1, 2)
+	now := time.Now()
+
+	for i := range 2 {
+		if result := limiter.Allow("client", now); !result.Allowed {
+			t.Fatalf("expected request %d to be allowed", i)
+		}
+	}
+
+	result := limiter.Allow("client", now)
+	if result.Allowed {
+		t.Fatal("expected request over the burst to be rejected")
+	}
+
+	if result.RetryAfter != time.Second {
+		t.Errorf("expected retry after 1s, got %v", result.RetryAfter)
+	}
+
+	if result := limiter.Allow("client", now.Add(time.Second)); !result.Allowed {
+		t.Error("expected request to be allowed after a refill")
+	}
+}
+
+func TestMiddlewareHeaders(t *testing.T) {
+	t.Parallel()
+
+	handler := setupHandler(ratelimit.NewLimiter(1, 1))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, withKey(httptest.NewRequest(http.MethodGet, "/weather/halifax", nil), "k1"))
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rec.Code)
+	}
+
+	if got := rec.Header().Get("RateLimit-Limit"); got != "1" {
+		t.Errorf("expected RateLimit-Limit 1, got %q", got)
+	}
+
+	if got := rec.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("expected RateLimit-Remaining 0, got %q", got)
+	}
+
+	if got := rec.Header().Get("RateLimit-Reset"); got != "1" {
+		t.Errorf("expected RateLimit-Reset 1, got %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, withKey(httptest.NewRequest(http.MethodGet, "/weather/halifax", nil), "k1"))
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", rec.Code)
+	}
+
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("expected Retry-After 1, got %q", got)
+	}
+}
+
+func TestMiddlewareSeparateClients(t *testing.T) {
+	t.Parallel()
+
+	handler := setupHandler(ratelimit.NewLimiter(1, 1))
+
+	for _, r := range []*http.Request{
+		withKey(httptest.NewRequest(http.MethodGet, "/weather/halifax", nil), "k1"),
+		withKey(httptest.NewRequest(http.MethodGet, "/weather/halifax", nil), "k2"),
+		withKey(httptest.NewRequest(http.MethodGet, "/weather/halifax", nil), auth.AnonymousID),
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("expected status 204 for %s, got %d", ratelimit.ClientKey(r), rec.Code)
+		}
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+
+	if got := ratelimit.ClientKey(withKey(r, auth.AnonymousID)); got != "ip:10.0.0.1" {
+		t.Errorf("expected anonymous requests to be keyed by IP, got %q", got)
+	}
+
+	if got := ratelimit.ClientKey(withKey(r, "k1")); got != "key:k1" {
+		t.Errorf("expected key:k1, got %q", got)
+	}
+
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{KeyID: "", Subject: "alice", Scopes: nil})
+	if got := ratelimit.ClientKey(r.WithContext(ctx)); got != "sub:alice" {
+		t.Errorf("expected sub:alice, got %q", got)
+	}
+}
+
+func TestMiddlewareDisabled(t *testing.T) {
+	t.Parallel()
+
+	var limiter *ratelimit.Limiter
+
+	handler := setupHandler(limiter)
+
+	for range 3 {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", rec.Code)
+		}
+	}
+}
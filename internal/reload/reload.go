@@ -0,0 +1,231 @@
+// Package reload applies config changes to a running server.
+package reload
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/config"
+	"github.com/codyonesock/rest_weather/internal/logger"
+	"github.com/codyonesock/rest_weather/internal/weather"
+)
+
+// restartOnly lists config fields that can't be changed without a restart.
+var restartOnly = map[string]bool{
+	"Port":                    true,
+	"DatabaseURL":             true,
+	"AdminToken":              true,
+	"AuthRequired":            true,
+	"JWKSURL":                 true,
+	"JWTIssuer":               true,
+	"JWTAudience":             true,
+	"JWKSRefreshInterval":     true,
+	"ReadRateLimit":           true,
+	"ReadRateBurst":           true,
+	"WriteRateLimit":          true,
+	"WriteRateBurst":          true,
+	"CORSAllowedOrigins":      true,
+	"CORSAllowedMethods":      true,
+	"CORSAllowedHeaders":      true,
+	"CORSAllowCredentials":    true,
+	"CORSMaxAge":              true,
+	"StreamRefreshInterval":   true,
+	"StreamHeartbeatInterval": true,
+	"WSMaxSubscriptions":      true,
+	"WSPingInterval":          true,
+	"GRPCPort":                true,
+	"PrewarmInterval":         true,
+	"PrewarmJitter":           true,
+	"PrewarmConcurrency":      true,
+	"AlertInterval":           true,
+	"WebhookTimeout":          true,
+	"WebhookMaxAttempts":      true,
+	"WebhookBackoff":          true,
+	"WebhookAllowPrivate":     true,
+	"SMTPHost":                true,
+	"SMTPPort":                true,
+	"SMTPUsername":            true,
+	"SMTPPassword":            true,
+	"SMTPFrom":                true,
+	"SMTPTLS":                 true,
+	"SMTPTimeout":             true,
+	"EmailDigestHour":         true,
+	"EmailMaxAttempts":        true,
+	"EmailBackoff":            true,
+	"ConfigFile":              true,
+	"ConfigReloadInterval":    true,
+	"ShutdownTimeout":         true,
+	"GeocodeCacheTTL":         true,
+	"WeatherCacheTTL":         true,
+	"TracingExporter":         true,
+	"TracingEndpoint":         true,
+	"HealthCacheTTL":          true,
+}
+
+// Service reloads the config on SIGHUP or when the config file changes.
+type Service struct {
+	mu             sync.Mutex
+	Logger         *zap.Logger
+	Level          zap.AtomicLevel
+	WeatherService *weather.Service
+	Load           func() (*config.Config, error)
+	current        config.Config
+	modTime        time.Time
+}
+
+// NewReloadService creates a new instance of Service.
+// load is called on every reload, typically config.LoadConfig.
+func NewReloadService(
+	l *zap.Logger,
+	level zap.AtomicLevel,
+	weatherService *weather.Service,
+	load func() (*config.Config, error),
+	current *config.Config,
+) *Service {
+	return &Service{
+		mu:             sync.Mutex{},
+		Logger:         l,
+		Level:          level,
+		WeatherService: weatherService,
+		Load:           load,
+		current:        *current,
+		modTime:        fileModTime(current.ConfigFile),
+	}
+}
+
+// Run reloads on SIGHUP and polls the config file until ctx is done.
+func (s *Service) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+
+	if s.current.ConfigFile != "" && s.current.ConfigReloadInterval > 0 {
+		ticker := time.NewTicker(s.current.ConfigReloadInterval)
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			s.Logger.Info("Received SIGHUP, reloading config")
+			s.reloadAndLog()
+		case <-tick:
+			if modTime := fileModTime(s.current.ConfigFile); !modTime.Equal(s.modTime) {
+				s.modTime = modTime
+				s.Logger.Info("Config file changed, reloading config", zap.String("file", s.current.ConfigFile))
+				s.reloadAndLog()
+			}
+		}
+	}
+}
+
+// reloadAndLog reloads and logs failures, since Run has no caller to return them to.
+func (s *Service) reloadAndLog() {
+	if err := s.Reload(); err != nil {
+		s.Logger.Error("Rejected config reload", zap.Error(err))
+	}
+}
+
+// Reload loads and validates the config, then applies the fields that can change at runtime.
+// An invalid config is rejected and the running config is kept.
+func (s *Service) Reload() error {
+	next, err := s.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if err := next.Validate(); err != nil {
+		return fmt.Errorf("failed to validate config: %w", err)
+	}
+
+	level, err := logger.ParseLevel(next.LogLevel)
+	if err != nil {
+		return fmt.Errorf("failed to parse log level: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changes := s.current.Changes(*next)
+	if len(changes) == 0 {
+		s.Logger.Info("Config reloaded, nothing changed")
+		return nil
+	}
+
+	levelChanged := false
+
+	for _, change := range changes {
+		levelChanged = levelChanged || change.Field == "LogLevel"
+
+		if restartOnly[change.Field] {
+			s.Logger.Warn("Config change requires a restart, ignoring",
+				zap.String("field", change.Field), zap.String("old", change.Old), zap.String("new", change.New))
+
+			continue
+		}
+
+		s.Logger.Info("Config changed",
+			zap.String("field", change.Field), zap.String("old", change.Old), zap.String("new", change.New))
+	}
+
+	// Only a changed log level is applied, so a level set through the admin API survives
+	// reloads that don't touch it.
+	if levelChanged {
+		s.Level.SetLevel(level)
+	}
+
+	s.WeatherService.UpdateAPIURLs(next.CurrentWeatherAPIURL, next.ForecastWeatherAPIURL, next.GeocodeAPIURL)
+
+	s.current = keepRestartOnly(s.current, *next)
+
+	return nil
+}
+
+// keepRestartOnly returns next with the restart-only fields of current.
+func keepRestartOnly(current, next config.Config) config.Config {
+	currentValue := reflect.ValueOf(current)
+	nextValue := reflect.ValueOf(&next).Elem()
+
+	for field := range restartOnly {
+		nextValue.FieldByName(field).Set(currentValue.FieldByName(field))
+	}
+
+	return next
+}
+
+// Current returns the config that is currently applied.
+func (s *Service) Current() config.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.current
+}
+
+// fileModTime returns the modification time of path, or the zero time if it can't be read.
+func fileModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
@@ -0,0 +1,110 @@
+package reload_test
+
+import (
+	"os"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/config"
+	"github.com/codyonesock/rest_weather/internal/reload"
+	"github.com/codyonesock/rest_weather/internal/weather"
+)
+
+const geocodeURL = "https://geocoding-api.open-meteo.com/v1/search?name=%s"
+
+func setupReloadService(t *testing.T, content string) (*reload.Service, *weather.Service, zap.AtomicLevel, string) {
+	t.Helper()
+
+	path := t.TempDir() + "/config.yaml"
+	writeFile(t, path, content)
+
+	load := func() (*config.Config, error) {
+		return config.Load([]string{"-config", path})
+	}
+
+	cfg, err := load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	weatherService := weather.NewWeatherService(zap.NewNop(), nil, "", "", cfg.GeocodeAPIURL)
+
+	return reload.NewReloadService(zap.NewNop(), level, weatherService, load, cfg), weatherService, level, path
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+}
+
+func TestReloadAppliesChanges(t *testing.T) {
+	t.Parallel()
+
+	reloadService, weatherService, level, path := setupReloadService(t, "log_level: INFO\n")
+
+	writeFile(t, path, "log_level: DEBUG\nport: \":9999\"\ngeocode_api_url: "+geocodeURL+"\n")
+
+	if err := reloadService.Reload(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if level.Level() != zap.DebugLevel {
+		t.Errorf("expected level to be debug, got %v", level.Level())
+	}
+
+	if weatherService.GeocodeAPIURL != geocodeURL {
+		t.Errorf("expected geocode url to be updated, got %v", weatherService.GeocodeAPIURL)
+	}
+
+	if reloadService.Current().Port != ":8080" {
+		t.Errorf("expected port to require a restart, got %v", reloadService.Current().Port)
+	}
+}
+
+func TestReloadKeepsRuntimeLevel(t *testing.T) {
+	t.Parallel()
+
+	reloadService, weatherService, level, path := setupReloadService(t, "log_level: INFO\n")
+
+	// As set through PUT /admin/loglevel.
+	level.SetLevel(zap.DebugLevel)
+
+	writeFile(t, path, "log_level: INFO\ngeocode_api_url: "+geocodeURL+"\n")
+
+	if err := reloadService.Reload(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if weatherService.GeocodeAPIURL != geocodeURL {
+		t.Errorf("expected geocode url to be updated, got %v", weatherService.GeocodeAPIURL)
+	}
+
+	if level.Level() != zap.DebugLevel {
+		t.Errorf("expected level to stay debug, got %v", level.Level())
+	}
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	reloadService, _, level, path := setupReloadService(t, "log_level: INFO\n")
+
+	writeFile(t, path, "log_level: LOUD\n")
+
+	if err := reloadService.Reload(); err == nil {
+		t.Fatal("expected an error for an invalid log level")
+	}
+
+	if level.Level() != zap.InfoLevel {
+		t.Errorf("expected level to stay info, got %v", level.Level())
+	}
+
+	if reloadService.Current().LogLevel != "INFO" {
+		t.Errorf("expected running config to be kept, got %v", reloadService.Current().LogLevel)
+	}
+}
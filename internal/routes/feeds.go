@@ -0,0 +1,168 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/codyonesock/rest_weather/internal/atom"
+	"github.com/codyonesock/rest_weather/internal/ical"
+	"github.com/codyonesock/rest_weather/internal/weather"
+)
+
+// forecastCalendar turns a forecast into one all-day event per day.
+// UIDs only depend on the city and date, so subscribed calendars update events instead of adding new ones.
+func forecastCalendar(report *weather.ForecastReport, now time.Time) ical.Calendar {
+	events := make([]ical.Event, 0, len(report.Days))
+
+	for _, day := range report.Days {
+		date, err := time.Parse(time.DateOnly, day.Date)
+		if err != nil {
+			continue
+		}
+
+		summary := fmt.Sprintf("%s to %s %s", formatFloat(day.Min), formatFloat(day.Max), report.TemperatureUnit)
+		if day.Condition != "" {
+			summary = day.Condition + ", " + summary
+		}
+
+		events = append(events, ical.Event{
+			UID:     fmt.Sprintf("%s-%s@rest-weather", day.Date, slug(report.City)),
+			Date:    date,
+			Summary: summary,
+			Description: fmt.Sprintf("Forecast for %s: low %s %s, high %s %s", report.City,
+				formatFloat(day.Min), report.TemperatureUnit, formatFloat(day.Max), report.TemperatureUnit),
+		})
+	}
+
+	return ical.Calendar{
+		ProdID: "-//rest_weather//Forecast//EN",
+		Name:   report.City + " forecast",
+		Stamp:  now,
+		Events: events,
+	}
+}
+
+// slug lowercases s and replaces everything but letters and digits with dashes, e.g. "St. John's" is "st-john-s".
+func slug(s string) string {
+	var b strings.Builder
+
+	dash := false
+
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+
+			dash = true
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// userFeed turns the saved cities' reports into an Atom feed with one entry per city.
+// Entries are updated when open-meteo observed the conditions, so readers only see changes.
+func userFeed(r *http.Request, userID string, reports []weather.CityReport, now time.Time) atom.Feed {
+	baseURL := requestBaseURL(r)
+	entries := make([]atom.Entry, 0, len(reports))
+
+	var updated time.Time
+
+	for _, report := range reports {
+		if report.Err != nil {
+			continue
+		}
+
+		entry := atom.Entry{
+			ID:      "urn:rest-weather:city:" + slug(report.City),
+			Title:   cityTitle(report.Weather),
+			Updated: report.Weather.ObservedAt,
+			Links: []atom.Link{{
+				Rel:  "alternate",
+				Type: "application/json",
+				Href: fmt.Sprintf("%s/v%d/weather/%s", baseURL, versionFromContext(r.Context()), url.PathEscape(report.City)),
+			}},
+			Summary: atom.PlainText(citySummary(report)),
+		}
+
+		if entry.Updated.IsZero() {
+			entry.Updated = now
+		}
+
+		if entry.Updated.After(updated) {
+			updated = entry.Updated
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if updated.IsZero() {
+		updated = now
+	}
+
+	if userID == "" {
+		userID = "default"
+	}
+
+	return atom.Feed{ //nolint:exhaustruct // XMLName comes from the struct tag
+		ID:      "urn:rest-weather:feed:" + slug(userID),
+		Title:   "Weather for saved cities",
+		Updated: updated,
+		Author:  atom.Person{Name: "rest_weather"},
+		Links:   []atom.Link{{Rel: "self", Type: atom.ContentType, Href: baseURL + r.URL.RequestURI()}},
+		Entries: entries,
+	}
+}
+
+// cityTitle is e.g. "halifax: Rain, 12.5 °C".
+func cityTitle(report *weather.WeatherReport) string {
+	title := fmt.Sprintf("%s: %s %s", report.City, formatFloat(report.Temperature), report.TemperatureUnit)
+	if report.Condition != "" {
+		title = fmt.Sprintf("%s: %s, %s %s", report.City, report.Condition, formatFloat(report.Temperature), report.TemperatureUnit)
+	}
+
+	return title
+}
+
+// citySummary is e.g. "Now: Rain, 12.5 °C, wind 9.4 km/h. Tomorrow (2026-10-19): Clear sky, 5 to 16 °C.".
+func citySummary(report weather.CityReport) string {
+	now := report.Weather
+	summary := fmt.Sprintf("Now: %s %s, wind %s %s.",
+		formatFloat(now.Temperature), now.TemperatureUnit, formatFloat(now.WindSpeed), now.WindSpeedUnit)
+
+	if now.Condition != "" {
+		summary = fmt.Sprintf("Now: %s, %s %s, wind %s %s.", now.Condition,
+			formatFloat(now.Temperature), now.TemperatureUnit, formatFloat(now.WindSpeed), now.WindSpeedUnit)
+	}
+
+	// The first forecast day is today.
+	if len(report.Forecast.Days) > 1 {
+		tomorrow := report.Forecast.Days[1]
+		temps := fmt.Sprintf("%s to %s %s", formatFloat(tomorrow.Min), formatFloat(tomorrow.Max), report.Forecast.TemperatureUnit)
+
+		if tomorrow.Condition != "" {
+			temps = tomorrow.Condition + ", " + temps
+		}
+
+		summary += fmt.Sprintf(" Tomorrow (%s): %s.", tomorrow.Date, temps)
+	}
+
+	return summary
+}
+
+// requestBaseURL is the scheme and host the request was sent to, for absolute links.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host
+}
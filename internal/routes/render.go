@@ -0,0 +1,209 @@
+package routes
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/shared"
+	"github.com/codyonesock/rest_weather/internal/weather"
+)
+
+// Response formats of the read routes, picked with ?format= or the Accept header.
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+	formatText = "text"
+)
+
+var formatContentTypes = map[string]string{
+	formatJSON: "application/json",
+	formatCSV:  "text/csv; charset=utf-8",
+	formatText: "text/plain; charset=utf-8",
+}
+
+type formatKey struct{}
+
+// plain is a response rendered as CSV rows (header first) or a one-line summary.
+type plain struct {
+	rows    [][]string
+	summary string
+}
+
+// negotiateFormat picks the response format of a request and rejects unsupported ones with 406.
+func negotiateFormat(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+
+		format, ok := requestFormat(r)
+		if !ok {
+			http.Error(w, "Unsupported format, use json, csv or text", http.StatusNotAcceptable)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), formatKey{}, format)))
+	})
+}
+
+// requestFormat returns ?format= if set, otherwise the supported media type with the highest q in Accept.
+func requestFormat(r *http.Request) (string, bool) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		_, ok := formatContentTypes[format]
+		return format, ok
+	}
+
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return formatJSON, true
+	}
+
+	best, bestQ := "", 0.0
+
+	for _, header := range accept {
+		for mediaRange := range strings.SplitSeq(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(mediaRange)
+			if err != nil {
+				continue
+			}
+
+			q := 1.0
+			if rawQ, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(rawQ, 64); err != nil {
+					continue
+				}
+			}
+
+			if format := formatForMediaType(mediaType); format != "" && q > bestQ {
+				best, bestQ = format, q
+			}
+		}
+	}
+
+	return best, best != ""
+}
+
+// formatForMediaType maps a media range to a format, versioned JSON types included.
+func formatForMediaType(mediaType string) string {
+	switch {
+	case mediaType == "application/json", mediaType == "application/*", mediaType == "*/*",
+		strings.HasPrefix(mediaType, mediaTypePrefix):
+		return formatJSON
+	case mediaType == "text/csv":
+		return formatCSV
+	case mediaType == "text/plain", mediaType == "text/*":
+		return formatText
+	default:
+		return ""
+	}
+}
+
+// writeResponse writes v as JSON, or p as CSV or text, depending on the negotiated format.
+func writeResponse(w http.ResponseWriter, r *http.Request, log *zap.Logger, v any, p plain) {
+	format, _ := r.Context().Value(formatKey{}).(string)
+
+	switch format {
+	case formatCSV:
+		w.Header().Set("Content-Type", formatContentTypes[formatCSV])
+
+		if err := csv.NewWriter(w).WriteAll(p.rows); err != nil {
+			log.Error("Error encoding CSV response", zap.Error(err))
+		}
+	case formatText:
+		w.Header().Set("Content-Type", formatContentTypes[formatText])
+
+		if _, err := fmt.Fprintln(w, p.summary); err != nil {
+			log.Error("Error writing text response", zap.Error(err))
+		}
+	default:
+		writeJSON(w, log, v)
+	}
+}
+
+// writeJSON encodes v as the response body.
+func writeJSON(w http.ResponseWriter, log *zap.Logger, v any) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("Error encoding response", zap.Error(err))
+	}
+}
+
+// weatherPlain renders the current weather, e.g. "halifax: 12.3 °C, wind 9.4 km/h".
+func weatherPlain(report *weather.WeatherReport) plain {
+	return plain{
+		rows: [][]string{
+			{"city", "temperature", "temperature_unit", "wind_speed", "wind_speed_unit"},
+			{
+				report.City, formatFloat(report.Temperature), report.TemperatureUnit,
+				formatFloat(report.WindSpeed), report.WindSpeedUnit,
+			},
+		},
+		summary: fmt.Sprintf("%s: %s %s, wind %s %s", report.City,
+			formatFloat(report.Temperature), report.TemperatureUnit, formatFloat(report.WindSpeed), report.WindSpeedUnit),
+	}
+}
+
+// forecastPlain renders the forecast as date,min,max rows, or e.g. "halifax: 8.1 to 14.2 °C today, 5 to 16.1 °C over 7 days".
+func forecastPlain(report *weather.ForecastReport) plain {
+	rows := [][]string{{"date", "min", "max"}}
+	for _, day := range report.Days {
+		rows = append(rows, []string{day.Date, formatFloat(day.Min), formatFloat(day.Max)})
+	}
+
+	if len(report.Days) == 0 {
+		return plain{rows: rows, summary: report.City + ": no forecast"}
+	}
+
+	today, low, high := report.Days[0], report.Days[0].Min, report.Days[0].Max
+	for _, day := range report.Days[1:] {
+		low, high = min(low, day.Min), max(high, day.Max)
+	}
+
+	return plain{
+		rows: rows,
+		summary: fmt.Sprintf("%s: %s to %s %s today, %s to %s %s over %d days", report.City,
+			formatFloat(today.Min), formatFloat(today.Max), report.TemperatureUnit,
+			formatFloat(low), formatFloat(high), report.TemperatureUnit, len(report.Days)),
+	}
+}
+
+// userDataPlain renders the saved cities one per row, or e.g. "2 saved cities (Halifax, Berlin), units metric".
+func userDataPlain(userData *shared.UserData) plain {
+	rows := [][]string{{"city"}}
+	for _, city := range userData.Cities {
+		rows = append(rows, []string{city})
+	}
+
+	summary := fmt.Sprintf("%d saved cities, units %s", len(userData.Cities), userData.Units)
+	if len(userData.Cities) > 0 {
+		summary = fmt.Sprintf("%d saved cities (%s), units %s",
+			len(userData.Cities), strings.Join(userData.Cities, ", "), userData.Units)
+	}
+
+	return plain{rows: rows, summary: summary}
+}
+
+// formatFloat formats v without trailing zeros.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// weatherErrorStatus maps weather errors to a status code.
+func weatherErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, weather.ErrCityRequired):
+		return http.StatusBadRequest
+	case errors.Is(err, weather.ErrNoResultsForCity):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
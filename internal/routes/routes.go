@@ -2,75 +2,351 @@
 package routes
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 
+	"github.com/codyonesock/rest_weather/internal/admin"
+	"github.com/codyonesock/rest_weather/internal/alerts"
+	"github.com/codyonesock/rest_weather/internal/atom"
+	"github.com/codyonesock/rest_weather/internal/auth"
+	"github.com/codyonesock/rest_weather/internal/email"
+	"github.com/codyonesock/rest_weather/internal/graphqlapi"
+	"github.com/codyonesock/rest_weather/internal/health"
+	"github.com/codyonesock/rest_weather/internal/ical"
+	"github.com/codyonesock/rest_weather/internal/logger"
+	"github.com/codyonesock/rest_weather/internal/metrics"
+	"github.com/codyonesock/rest_weather/internal/openapi"
+	"github.com/codyonesock/rest_weather/internal/ratelimit"
+	"github.com/codyonesock/rest_weather/internal/shared"
+	"github.com/codyonesock/rest_weather/internal/stream"
+	"github.com/codyonesock/rest_weather/internal/tracing"
 	"github.com/codyonesock/rest_weather/internal/weather"
+	"github.com/codyonesock/rest_weather/internal/webhooks"
+	"github.com/codyonesock/rest_weather/internal/ws"
 	"github.com/go-chi/chi"
+	"github.com/go-chi/cors"
 	"go.uber.org/zap"
 )
 
+// Services are the dependencies of the handlers.
+type Services struct {
+	Weather  *weather.Service
+	Admin    *admin.Service
+	Metrics  *metrics.Service
+	Health   *health.Service
+	Auth     *auth.Service
+	Stream   *stream.Service
+	WS       *ws.Service
+	GraphQL  *graphqlapi.Service
+	Alerts   *alerts.Service
+	Webhooks *webhooks.Service
+	Email    *email.Service
+
+	// ReadLimiter and WriteLimiter budget the read and write routes separately, nil disables them.
+	ReadLimiter  *ratelimit.Limiter
+	WriteLimiter *ratelimit.Limiter
+
+	// CORS answers preflight requests and sets the CORS headers, nil disables it.
+	CORS *cors.Cors
+}
+
 // RegisterRoutes sets up all the app routes.
-func RegisterRoutes(
-	r *chi.Mux,
-	weatherService *weather.Service,
-) {
+func RegisterRoutes(r *chi.Mux, services Services) {
+	weatherService := services.Weather
+	authService := services.Auth
+
+	r.Use(services.Metrics.Middleware)
+	r.Use(tracing.Middleware)
+	r.Use(logger.RequestMiddleware(weatherService.Logger))
+
+	// Preflights carry no credentials, so CORS has to run before Authenticate.
+	if services.CORS != nil {
+		r.Use(services.CORS.Handler)
+	}
+
+	r.Method(http.MethodGet, "/metrics", services.Metrics.Handler())
+	r.Get("/healthz", livenessHandler(services.Health))
+	r.Get("/readyz", readinessHandler(services.Health))
+	r.Get("/openapi.json", openapi.SpecHandler)
+	r.Get("/docs", openapi.DocsHandler)
+
+	r.Group(func(r chi.Router) {
+		r.Use(authService.Authenticate)
+
+		r.Route("/v1", func(r chi.Router) {
+			r.Use(apiVersion(1))
+			mountAPI(r, services)
+		})
+
+		r.Route("/v2", func(r chi.Router) {
+			r.Use(apiVersion(2)) //nolint:mnd // API version
+			mountAPI(r, services)
+		})
+
+		// The unversioned paths are deprecated aliases, the Accept header picks their version.
+		r.Group(func(r chi.Router) {
+			r.Use(negotiateVersion, deprecated)
+			mountAPI(r, services)
+		})
+	})
+}
+
+// mountAPI registers the versioned API routes on r.
+// Handlers that changed between versions are picked with versioned.
+func mountAPI(r chi.Router, services Services) {
+	weatherService := services.Weather
+	adminService := services.Admin
+	authService := services.Auth
+
 	r.Route("/weather", func(r chi.Router) {
-		r.Get("/{city}", getCurrentWeatherHandler(weatherService))
+		r.Use(auth.RequireScope(auth.ScopeReadWeather), services.ReadLimiter.Middleware, negotiateFormat)
+		r.Get("/{city}", versioned(getCurrentWeatherHandler(weatherService), getWeatherReportHandler(weatherService)))
 	})
 
 	r.Route("/forecast", func(r chi.Router) {
-		r.Get("/{city}", getForecastHandler(weatherService))
+		r.Use(auth.RequireScope(auth.ScopeReadWeather), services.ReadLimiter.Middleware)
+		r.With(negotiateFormat).
+			Get("/{city}", versioned(getForecastHandler(weatherService), getForecastReportHandler(weatherService)))
+		r.Get("/{city}.ics", getForecastCalendarHandler(weatherService))
+	})
+
+	r.Route("/stream", func(r chi.Router) {
+		r.Use(auth.RequireScope(auth.ScopeReadWeather), services.ReadLimiter.Middleware)
+		r.Get("/weather", streamWeatherHandler(services.Stream))
+	})
+
+	r.With(auth.RequireScope(auth.ScopeReadWeather), services.ReadLimiter.Middleware).
+		Get("/ws", webSocketHandler(services.WS))
+
+	r.Group(func(r chi.Router) {
+		r.Use(auth.RequireScope(auth.ScopeReadWeather), services.ReadLimiter.Middleware)
+		r.Get("/graphql", graphqlHandler(services.GraphQL))
+		r.Post("/graphql", graphqlHandler(services.GraphQL))
 	})
 
 	r.Route("/user", func(r chi.Router) {
-		r.Get("/data", getUserDataHandler(weatherService))
-		r.Post("/cities/{city}", addCityHandler(weatherService))
-		r.Delete("/cities/{city}", deleteCityHandler(weatherService))
-		r.Put("/units", updateUserUnitsHandler(weatherService))
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RequireScope(auth.ScopeReadWeather), services.ReadLimiter.Middleware)
+			r.With(negotiateFormat).Get("/data", getUserDataHandler(weatherService))
+			r.Get("/feed.atom", getUserFeedHandler(weatherService))
+			r.Get("/alerts", listAlertRulesHandler(services.Alerts))
+			r.Get("/alerts/events", listAlertEventsHandler(services.Alerts))
+			r.Get("/alerts/{id}", getAlertRuleHandler(services.Alerts))
+			r.Get("/webhooks", listWebhooksHandler(services.Webhooks))
+			r.Get("/webhooks/deliveries", listWebhookDeliveriesHandler(services.Webhooks))
+			r.Get("/webhooks/{id}", getWebhookHandler(services.Webhooks))
+			r.Get("/email", getEmailSettingsHandler(services.Email))
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RequireScope(auth.ScopeWriteUser), services.WriteLimiter.Middleware)
+			r.Post("/cities/{city}", addCityHandler(weatherService))
+			r.Delete("/cities/{city}", deleteCityHandler(weatherService))
+			r.Put("/units", updateUserUnitsHandler(weatherService))
+			r.Post("/alerts", createAlertRuleHandler(services.Alerts))
+			r.Put("/alerts/{id}", updateAlertRuleHandler(services.Alerts))
+			r.Delete("/alerts/{id}", deleteAlertRuleHandler(services.Alerts))
+			r.Post("/webhooks", createWebhookHandler(services.Webhooks))
+			r.Put("/webhooks/{id}", updateWebhookHandler(services.Webhooks))
+			r.Delete("/webhooks/{id}", deleteWebhookHandler(services.Webhooks))
+			r.Post("/webhooks/deliveries/{id}/replay", replayWebhookDeliveryHandler(services.Webhooks))
+			r.Put("/email", updateEmailSettingsHandler(services.Email))
+		})
+	})
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(auth.RequireScope(auth.ScopeAdmin))
+		r.Get("/loglevel", getLogLevelHandler(adminService))
+		r.Put("/loglevel", updateLogLevelHandler(adminService))
+		r.Get("/keys", listAPIKeysHandler(authService))
+		r.Post("/keys", createAPIKeyHandler(authService))
+		r.Delete("/keys/{id}", revokeAPIKeyHandler(authService))
 	})
 }
 
 func getCurrentWeatherHandler(weatherService *weather.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		city := chi.URLParam(r, "city")
-		if _, err := weatherService.GetCurrentWeatherByCity(w, city); err != nil {
-			weatherService.Logger.Error("Error getting current weather", zap.String("city", city), zap.Error(err))
+		city := cityParam(r)
+		log := logger.FromContext(r.Context(), weatherService.Logger)
+
+		weatherData, err := weatherService.CurrentWeather(r.Context(), city)
+		if err != nil {
+			log.Error("Error getting current weather", zap.String("city", city), zap.Error(err))
 			http.Error(w, "Error getting current weather", http.StatusInternalServerError)
+
+			return
 		}
+
+		// open-meteo answers in metric units, which is what v1 has always returned.
+		writeResponse(w, r, log, weatherData, weatherPlain(weather.NewWeatherReport(city, weather.UnitsMetric, weatherData)))
 	}
 }
 
 func getForecastHandler(weatherService *weather.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		city := chi.URLParam(r, "city")
-		if _, err := weatherService.GetForecastByCity(w, city); err != nil {
-			weatherService.Logger.Error("Error getting forecast data", zap.String("city", city), zap.Error(err))
+		city := cityParam(r)
+		log := logger.FromContext(r.Context(), weatherService.Logger)
+
+		forecastData, err := weatherService.Forecast(r.Context(), city)
+		if err != nil {
+			log.Error("Error getting forecast data", zap.String("city", city), zap.Error(err))
 			http.Error(w, "Error getting forecast data", http.StatusInternalServerError)
+
+			return
+		}
+
+		writeResponse(w, r, log, forecastData, forecastPlain(weather.NewForecastReport(city, weather.UnitsMetric, forecastData)))
+	}
+}
+
+func getWeatherReportHandler(weatherService *weather.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		city := cityParam(r)
+		log := logger.FromContext(r.Context(), weatherService.Logger)
+
+		report, err := weatherService.CurrentWeatherReport(r.Context(), city)
+		if err != nil {
+			log.Error("Error getting current weather", zap.String("city", city), zap.Error(err))
+			http.Error(w, "Error getting current weather", weatherErrorStatus(err))
+
+			return
 		}
+
+		writeResponse(w, r, log, report, weatherPlain(report))
 	}
 }
+
+func getForecastReportHandler(weatherService *weather.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		city := cityParam(r)
+		log := logger.FromContext(r.Context(), weatherService.Logger)
+
+		report, err := weatherService.ForecastReport(r.Context(), city)
+		if err != nil {
+			log.Error("Error getting forecast data", zap.String("city", city), zap.Error(err))
+			http.Error(w, "Error getting forecast data", weatherErrorStatus(err))
+
+			return
+		}
+
+		writeResponse(w, r, log, report, forecastPlain(report))
+	}
+}
+
+func getForecastCalendarHandler(weatherService *weather.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		city := cityParam(r)
+		log := logger.FromContext(r.Context(), weatherService.Logger)
+
+		report, err := weatherService.ForecastReport(r.Context(), city)
+		if err != nil {
+			log.Error("Error getting forecast data", zap.String("city", city), zap.Error(err))
+			http.Error(w, "Error getting forecast data", weatherErrorStatus(err))
+
+			return
+		}
+
+		w.Header().Set("Content-Type", ical.ContentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", slug(city)+".ics"))
+
+		if err := ical.Encode(w, forecastCalendar(report, time.Now())); err != nil {
+			log.Error("Error encoding calendar", zap.Error(err))
+		}
+	}
+}
+
 func getUserDataHandler(weatherService *weather.Service) http.HandlerFunc {
-	return func(w http.ResponseWriter, _ *http.Request) {
-		if _, err := weatherService.GetUserData(w); err != nil {
-			weatherService.Logger.Error("Error getting user data", zap.Error(err))
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), weatherService.Logger)
+
+		userData, err := weatherService.UserData(r.Context())
+		if err != nil {
+			log.Error("Error getting user data", zap.Error(err))
 			http.Error(w, "Error getting user data", http.StatusInternalServerError)
+
+			return
 		}
+
+		writeResponse(w, r, log, userData, userDataPlain(userData))
 	}
 }
+func getUserFeedHandler(weatherService *weather.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logger.FromContext(r.Context(), weatherService.Logger)
+
+		reports, err := weatherService.SavedCityReports(r.Context())
+		if err != nil {
+			log.Error("Error getting saved cities", zap.Error(err))
+			http.Error(w, "Error getting saved cities", http.StatusInternalServerError)
+
+			return
+		}
+
+		for _, report := range reports {
+			if report.Err != nil {
+				log.Warn("Leaving city out of the feed", zap.String("city", report.City), zap.Error(report.Err))
+			}
+		}
+
+		w.Header().Set("Content-Type", atom.ContentType)
+
+		feed := userFeed(r, shared.UserIDFromContext(r.Context()), reports, time.Now())
+		if err := atom.Encode(w, feed); err != nil {
+			log.Error("Error encoding feed", zap.Error(err))
+		}
+	}
+}
+
+func streamWeatherHandler(streamService *stream.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := streamService.ServeSSE(w, r); err != nil {
+			logger.FromContext(r.Context(), streamService.Logger).Error("Error streaming weather", zap.Error(err))
+
+			if errors.Is(err, stream.ErrCitiesRequired) || errors.Is(err, stream.ErrTooManyCities) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			http.Error(w, "Error streaming weather", http.StatusInternalServerError)
+		}
+	}
+}
+
+// webSocketHandler only logs errors, a failed upgrade has already been answered.
+func webSocketHandler(wsService *ws.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := wsService.ServeWS(w, r); err != nil {
+			logger.FromContext(r.Context(), wsService.Logger).Error("Error serving websocket", zap.Error(err))
+		}
+	}
+}
+
+func graphqlHandler(graphqlService *graphqlapi.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := graphqlService.ServeGraphQL(w, r); err != nil {
+			logger.FromContext(r.Context(), graphqlService.Logger).Error("Invalid GraphQL request", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}
+}
+
 func addCityHandler(weatherService *weather.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		city := chi.URLParam(r, "city")
-		if err := weatherService.AddCity(w, city); err != nil {
-			weatherService.Logger.Error("Error adding city to user data", zap.String("city", city), zap.Error(err))
+		city := cityParam(r)
+		if err := weatherService.AddCity(r.Context(), w, city); err != nil {
+			logger.FromContext(r.Context(), weatherService.Logger).Error("Error adding city to user data", zap.String("city", city), zap.Error(err))
 			http.Error(w, "Error adding city to user data", http.StatusInternalServerError)
 		}
 	}
 }
 func deleteCityHandler(weatherService *weather.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		city := chi.URLParam(r, "city")
-		if err := weatherService.DeleteCity(w, city); err != nil {
-			weatherService.Logger.Error("Error deleting city from user data", zap.String("city", city), zap.Error(err))
+		city := cityParam(r)
+		if err := weatherService.DeleteCity(r.Context(), w, city); err != nil {
+			logger.FromContext(r.Context(), weatherService.Logger).Error("Error deleting city from user data", zap.String("city", city), zap.Error(err))
 			http.Error(w, "Error deleting city from user data", http.StatusInternalServerError)
 		}
 	}
@@ -78,8 +354,282 @@ func deleteCityHandler(weatherService *weather.Service) http.HandlerFunc {
 func updateUserUnitsHandler(weatherService *weather.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := weatherService.UpdateUserUnits(w, r); err != nil {
-			weatherService.Logger.Error("Error updating units in user data", zap.Error(err))
+			logger.FromContext(r.Context(), weatherService.Logger).Error("Error updating units in user data", zap.Error(err))
 			http.Error(w, "Error updating units in user data", http.StatusInternalServerError)
 		}
 	}
 }
+
+func getLogLevelHandler(adminService *admin.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := adminService.GetLogLevel(w); err != nil {
+			logger.FromContext(r.Context(), adminService.Logger).Error("Error getting log level", zap.Error(err))
+			http.Error(w, "Error getting log level", http.StatusInternalServerError)
+		}
+	}
+}
+func updateLogLevelHandler(adminService *admin.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := adminService.UpdateLogLevel(w, r); err != nil {
+			logger.FromContext(r.Context(), adminService.Logger).Error("Error updating log level", zap.Error(err))
+
+			if errors.Is(err, logger.ErrInvalidLevel) {
+				http.Error(w, "Invalid log level", http.StatusBadRequest)
+				return
+			}
+
+			if errors.Is(err, admin.ErrInvalidBody) {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			http.Error(w, "Error updating log level", http.StatusInternalServerError)
+		}
+	}
+}
+
+func livenessHandler(healthService *health.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := healthService.Liveness(w); err != nil {
+			logger.FromContext(r.Context(), healthService.Logger).Error("Error writing liveness", zap.Error(err))
+		}
+	}
+}
+func readinessHandler(healthService *health.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := healthService.Readiness(r.Context(), w); err != nil {
+			logger.FromContext(r.Context(), healthService.Logger).Error("Error writing readiness", zap.Error(err))
+		}
+	}
+}
+func listAPIKeysHandler(authService *auth.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := authService.ListAPIKeys(w, r); err != nil {
+			logger.FromContext(r.Context(), authService.Logger).Error("Error listing API keys", zap.Error(err))
+			http.Error(w, "Error listing API keys", http.StatusInternalServerError)
+		}
+	}
+}
+func createAPIKeyHandler(authService *auth.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := authService.CreateAPIKey(w, r); err != nil {
+			logger.FromContext(r.Context(), authService.Logger).Error("Error creating API key", zap.Error(err))
+
+			if errors.Is(err, auth.ErrInvalidScope) || errors.Is(err, auth.ErrNameRequired) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			http.Error(w, "Error creating API key", http.StatusInternalServerError)
+		}
+	}
+}
+func revokeAPIKeyHandler(authService *auth.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := authService.RevokeAPIKey(w, r, id); err != nil {
+			logger.FromContext(r.Context(), authService.Logger).Error("Error revoking API key", zap.String("id", id), zap.Error(err))
+
+			if errors.Is(err, auth.ErrKeyNotFound) {
+				http.Error(w, "API key not found", http.StatusNotFound)
+				return
+			}
+
+			http.Error(w, "Error revoking API key", http.StatusInternalServerError)
+		}
+	}
+}
+
+func listAlertRulesHandler(alertsService *alerts.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := alertsService.ListRules(w, r); err != nil {
+			logger.FromContext(r.Context(), alertsService.Logger).Error("Error listing alert rules", zap.Error(err))
+			http.Error(w, "Error listing alert rules", http.StatusInternalServerError)
+		}
+	}
+}
+
+func listAlertEventsHandler(alertsService *alerts.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := alertsService.ListEvents(w, r); err != nil {
+			logger.FromContext(r.Context(), alertsService.Logger).Error("Error listing alert events", zap.Error(err))
+			http.Error(w, "Error listing alert events", http.StatusInternalServerError)
+		}
+	}
+}
+
+func getAlertRuleHandler(alertsService *alerts.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := alertsService.GetRule(w, r, id); err != nil {
+			logger.FromContext(r.Context(), alertsService.Logger).Error("Error getting alert rule", zap.String("id", id), zap.Error(err))
+			alertRuleError(w, err, "Error getting alert rule")
+		}
+	}
+}
+
+func createAlertRuleHandler(alertsService *alerts.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := alertsService.CreateRule(w, r); err != nil {
+			logger.FromContext(r.Context(), alertsService.Logger).Error("Error creating alert rule", zap.Error(err))
+			alertRuleError(w, err, "Error creating alert rule")
+		}
+	}
+}
+
+func updateAlertRuleHandler(alertsService *alerts.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := alertsService.UpdateRule(w, r, id); err != nil {
+			logger.FromContext(r.Context(), alertsService.Logger).Error("Error updating alert rule", zap.String("id", id), zap.Error(err))
+			alertRuleError(w, err, "Error updating alert rule")
+		}
+	}
+}
+
+func deleteAlertRuleHandler(alertsService *alerts.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := alertsService.DeleteRule(w, r, id); err != nil {
+			logger.FromContext(r.Context(), alertsService.Logger).Error("Error deleting alert rule", zap.String("id", id), zap.Error(err))
+			alertRuleError(w, err, "Error deleting alert rule")
+		}
+	}
+}
+
+// alertRuleError maps an alert rule error to its status, hiding unexpected errors behind msg.
+func alertRuleError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, alerts.ErrRuleNotFound):
+		http.Error(w, "Alert rule not found", http.StatusNotFound)
+	case errors.Is(err, alerts.ErrInvalidBody), errors.Is(err, alerts.ErrCityRequired),
+		errors.Is(err, alerts.ErrInvalidMetric), errors.Is(err, alerts.ErrInvalidOperator),
+		errors.Is(err, alerts.ErrInvalidUnits), errors.Is(err, alerts.ErrInvalidDays),
+		errors.Is(err, alerts.ErrTooManyRules):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+func listWebhooksHandler(webhooksService *webhooks.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := webhooksService.ListWebhooks(w, r); err != nil {
+			logger.FromContext(r.Context(), webhooksService.Logger).Error("Error listing webhooks", zap.Error(err))
+			http.Error(w, "Error listing webhooks", http.StatusInternalServerError)
+		}
+	}
+}
+
+func listWebhookDeliveriesHandler(webhooksService *webhooks.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := webhooksService.ListDeliveries(w, r); err != nil {
+			logger.FromContext(r.Context(), webhooksService.Logger).Error("Error listing webhook deliveries", zap.Error(err))
+			http.Error(w, "Error listing webhook deliveries", http.StatusInternalServerError)
+		}
+	}
+}
+
+func getWebhookHandler(webhooksService *webhooks.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := webhooksService.GetWebhook(w, r, id); err != nil {
+			logger.FromContext(r.Context(), webhooksService.Logger).Error("Error getting webhook", zap.String("id", id), zap.Error(err))
+			webhookError(w, err, "Error getting webhook")
+		}
+	}
+}
+
+func createWebhookHandler(webhooksService *webhooks.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := webhooksService.CreateWebhook(w, r); err != nil {
+			logger.FromContext(r.Context(), webhooksService.Logger).Error("Error creating webhook", zap.Error(err))
+			webhookError(w, err, "Error creating webhook")
+		}
+	}
+}
+
+func updateWebhookHandler(webhooksService *webhooks.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := webhooksService.UpdateWebhook(w, r, id); err != nil {
+			logger.FromContext(r.Context(), webhooksService.Logger).Error("Error updating webhook", zap.String("id", id), zap.Error(err))
+			webhookError(w, err, "Error updating webhook")
+		}
+	}
+}
+
+func deleteWebhookHandler(webhooksService *webhooks.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := webhooksService.DeleteWebhook(w, r, id); err != nil {
+			logger.FromContext(r.Context(), webhooksService.Logger).Error("Error deleting webhook", zap.String("id", id), zap.Error(err))
+			webhookError(w, err, "Error deleting webhook")
+		}
+	}
+}
+
+func replayWebhookDeliveryHandler(webhooksService *webhooks.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := webhooksService.ReplayDelivery(w, r, id); err != nil {
+			logger.FromContext(r.Context(), webhooksService.Logger).Error("Error replaying webhook delivery", zap.String("id", id), zap.Error(err))
+			webhookError(w, err, "Error replaying webhook delivery")
+		}
+	}
+}
+
+// webhookError maps a webhook error to its status, hiding unexpected errors behind msg.
+func webhookError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, webhooks.ErrWebhookNotFound):
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+	case errors.Is(err, webhooks.ErrDeliveryNotFound):
+		http.Error(w, "Webhook delivery not found", http.StatusNotFound)
+	case errors.Is(err, webhooks.ErrInvalidBody), errors.Is(err, webhooks.ErrInvalidURL),
+		errors.Is(err, webhooks.ErrUnknownHost), errors.Is(err, webhooks.ErrPrivateAddress),
+		errors.Is(err, webhooks.ErrEventsRequired), errors.Is(err, webhooks.ErrInvalidEvent),
+		errors.Is(err, webhooks.ErrCitiesRequired), errors.Is(err, webhooks.ErrTooManyCities),
+		errors.Is(err, webhooks.ErrSecretTooShort), errors.Is(err, webhooks.ErrTooManyWebhooks):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+func getEmailSettingsHandler(emailService *email.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := emailService.GetSettings(w, r); err != nil {
+			logger.FromContext(r.Context(), emailService.Logger).Error("Error getting email settings", zap.Error(err))
+			http.Error(w, "Error getting email settings", http.StatusInternalServerError)
+		}
+	}
+}
+
+func updateEmailSettingsHandler(emailService *email.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := emailService.UpdateSettings(w, r); err != nil {
+			logger.FromContext(r.Context(), emailService.Logger).Error("Error updating email settings", zap.Error(err))
+
+			switch {
+			case errors.Is(err, email.ErrInvalidBody), errors.Is(err, email.ErrInvalidAddress),
+				errors.Is(err, email.ErrAddressRequired):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case errors.Is(err, email.ErrDisabled):
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			default:
+				http.Error(w, "Error updating email settings", http.StatusInternalServerError)
+			}
+		}
+	}
+}
+
+// cityParam returns the unescaped {city} URL parameter; chi matches on the raw path.
+func cityParam(r *http.Request) string {
+	city := chi.URLParam(r, "city")
+	if unescaped, err := url.PathUnescape(city); err == nil {
+		return unescaped
+	}
+
+	return city
+}
@@ -0,0 +1,504 @@
+package routes_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/cors"
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/admin"
+	"github.com/codyonesock/rest_weather/internal/alerts"
+	"github.com/codyonesock/rest_weather/internal/auth"
+	"github.com/codyonesock/rest_weather/internal/email"
+	"github.com/codyonesock/rest_weather/internal/graphqlapi"
+	"github.com/codyonesock/rest_weather/internal/health"
+	"github.com/codyonesock/rest_weather/internal/metrics"
+	"github.com/codyonesock/rest_weather/internal/openapi"
+	"github.com/codyonesock/rest_weather/internal/routes"
+	"github.com/codyonesock/rest_weather/internal/stream"
+	"github.com/codyonesock/rest_weather/internal/weathertest"
+	"github.com/codyonesock/rest_weather/internal/webhooks"
+	"github.com/codyonesock/rest_weather/internal/ws"
+)
+
+const frontendOrigin = "https://app.example.com"
+
+func setupRouter(t *testing.T) *chi.Mux {
+	t.Helper()
+
+	r := chi.NewRouter()
+	routes.RegisterRoutes(r, setupServices(t))
+
+	return r
+}
+
+func setupServices(t *testing.T) routes.Services {
+	t.Helper()
+
+	weatherService, storageService := weathertest.NewUpstream(t).NewWeatherService(t)
+	logger := zap.NewNop()
+	streamService := stream.NewStreamService(logger, weatherService, time.Minute, time.Minute)
+
+	return routes.Services{
+		Weather: weatherService,
+		Admin:   admin.NewAdminService(logger, zap.NewAtomicLevel()),
+		Metrics: metrics.NewMetricsService(),
+		Health:  health.NewHealthService(logger, 0),
+		Auth:    auth.NewAuthService(logger, storageService, "admin-secret", true),
+		Stream:  streamService,
+		WS:      ws.NewWSService(logger, weatherService, streamService, 10, time.Minute),
+		GraphQL: graphqlapi.NewGraphQLService(logger, weatherService),
+		Alerts:  alerts.NewAlertsService(logger, weatherService, storageService, time.Hour),
+		Webhooks: webhooks.NewWebhooksService(logger, weatherService, streamService, storageService,
+			time.Second, 1, time.Second),
+		Email: email.NewEmailService(logger, weatherService, storageService, nil, nil, 7, 1, time.Second),
+
+		ReadLimiter:  nil,
+		WriteLimiter: nil,
+		CORS: cors.New(cors.Options{ //nolint:exhaustruct // optional fields
+			AllowedOrigins: []string{frontendOrigin},
+			AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+			AllowedHeaders: []string{"Authorization", "Content-Type"},
+			MaxAge:         600,
+		}),
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	t.Parallel()
+
+	r := setupRouter(t)
+
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodPut, "/user/units"},
+		{http.MethodDelete, "/user/cities/halifax"},
+	} {
+		req := httptest.NewRequest(http.MethodOptions, tc.path, nil)
+		req.Header.Set("Origin", frontendOrigin)
+		req.Header.Set("Access-Control-Request-Method", tc.method)
+		req.Header.Set("Access-Control-Request-Headers", "Authorization, Content-Type")
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code >= http.StatusMultipleChoices {
+			t.Errorf("expected preflight for %s %s to succeed, got %d", tc.method, tc.path, rec.Code)
+		}
+
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != frontendOrigin {
+			t.Errorf("expected allowed origin %q, got %q", frontendOrigin, got)
+		}
+
+		if got := rec.Header().Get("Access-Control-Allow-Methods"); got != tc.method {
+			t.Errorf("expected allowed method %q, got %q", tc.method, got)
+		}
+
+		if got := rec.Header().Get("Access-Control-Max-Age"); got != "600" {
+			t.Errorf("expected max age 600, got %q", got)
+		}
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodOptions, "/user/units", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+
+	rec := httptest.NewRecorder()
+	setupRouter(t).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no allowed origin, got %q", got)
+	}
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("Origin", frontendOrigin)
+
+	rec := httptest.NewRecorder()
+	setupRouter(t).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != frontendOrigin {
+		t.Errorf("expected allowed origin %q, got %q", frontendOrigin, got)
+	}
+}
+
+func TestRoutesMatchSpec(t *testing.T) {
+	t.Parallel()
+
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+
+	if err := json.Unmarshal(openapi.Spec(), &doc); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var documented []string
+
+	for path, methods := range doc.Paths {
+		for method := range methods {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	var registered []string
+
+	walk := func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		registered = append(registered, method+" "+route)
+		return nil
+	}
+
+	if err := chi.Walk(setupRouter(t), walk); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, route := range registered {
+		if !slices.Contains(documented, route) {
+			t.Errorf("expected %s to be in the OpenAPI document", route)
+		}
+	}
+
+	for _, route := range documented {
+		if !slices.Contains(registered, route) {
+			t.Errorf("expected %s from the OpenAPI document to be registered", route)
+		}
+	}
+}
+
+func TestAnonymousAccessIsReadOnly(t *testing.T) {
+	t.Parallel()
+
+	services := setupServices(t)
+	services.Auth = auth.NewAuthService(zap.NewNop(), services.Auth.Store, "admin-secret", false)
+
+	r := chi.NewRouter()
+	routes.RegisterRoutes(r, services)
+
+	for _, tc := range []struct {
+		method, path, body string
+		code               int
+	}{
+		{http.MethodGet, "/v1/user/data", "", http.StatusOK},
+		{http.MethodPut, "/user/units", `{"units": "imperial"}`, http.StatusUnauthorized},
+		{http.MethodPut, "/v1/user/units", `{"units": "imperial"}`, http.StatusUnauthorized},
+		{http.MethodPost, "/v1/user/cities/halifax", "", http.StatusUnauthorized},
+		{http.MethodPost, "/v1/user/webhooks", `{"url": "https://example.com", "events": ["alert.fired"]}`, http.StatusUnauthorized},
+	} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+
+		if rec.Code != tc.code {
+			t.Errorf("%s %s: expected status %d, got %d", tc.method, tc.path, tc.code, rec.Code)
+		}
+
+		if tc.code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s %s: expected a WWW-Authenticate challenge", tc.method, tc.path)
+		}
+	}
+}
+
+func TestEmailOptInWithoutSMTP(t *testing.T) {
+	t.Parallel()
+
+	r := setupRouter(t)
+
+	for _, tc := range []struct {
+		body string
+		code int
+	}{
+		{`{"address": "alice@example.com", "alerts": true}`, http.StatusServiceUnavailable},
+		{`{"address": "alice@example.com"}`, http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodPut, "/v1/user/email", strings.NewReader(tc.body))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != tc.code {
+			t.Errorf("%s: expected status %d, got %d", tc.body, tc.code, rec.Code)
+		}
+	}
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	t.Parallel()
+
+	r := setupRouter(t)
+
+	for _, tc := range []struct {
+		path, accept, version, link string
+		code                        int
+	}{
+		{"/v1/user/data", "", "1", "", http.StatusOK},
+		{"/v2/user/data", "", "2", "", http.StatusOK},
+		{"/v1/user/data", "application/vnd.weather.v2+json", "1", "", http.StatusOK},
+		{"/user/data", "", "1", `</v1/user/data>; rel="successor-version"`, http.StatusOK},
+		{"/user/data", "application/json", "1", `</v1/user/data>; rel="successor-version"`, http.StatusOK},
+		{"/user/data", "application/vnd.weather.v2+json", "2", `</v2/user/data>; rel="successor-version"`, http.StatusOK},
+		{"/user/data", "application/vnd.weather.v9+json", "", "", http.StatusNotAcceptable},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != tc.code {
+			t.Errorf("%s (Accept %q): expected status %d, got %d", tc.path, tc.accept, tc.code, rec.Code)
+			continue
+		}
+
+		if got := rec.Header().Get("API-Version"); got != tc.version {
+			t.Errorf("%s (Accept %q): expected API-Version %q, got %q", tc.path, tc.accept, tc.version, got)
+		}
+
+		if got := rec.Header().Get("Link"); got != tc.link {
+			t.Errorf("%s (Accept %q): expected Link %q, got %q", tc.path, tc.accept, tc.link, got)
+		}
+
+		deprecated := rec.Header().Get("Deprecation") != "" && rec.Header().Get("Sunset") != ""
+		if deprecated != (tc.link != "") {
+			t.Errorf("%s (Accept %q): expected deprecated=%t, got Deprecation %q and Sunset %q", tc.path, tc.accept,
+				tc.link != "", rec.Header().Get("Deprecation"), rec.Header().Get("Sunset"))
+		}
+	}
+}
+
+func TestResponseFormats(t *testing.T) {
+	t.Parallel()
+
+	r := setupRouter(t)
+
+	for _, tc := range []struct {
+		path, accept, contentType, body string
+	}{
+		{"/v1/forecast/halifax?format=csv", "", "text/csv; charset=utf-8", "date,min,max\n2026-10-18,8.1,14.2\n2026-10-19,5,16\n"},
+		{"/v1/forecast/halifax", "text/csv", "text/csv; charset=utf-8", "date,min,max\n2026-10-18,8.1,14.2\n2026-10-19,5,16\n"},
+		{"/v1/forecast/halifax", "text/plain", "text/plain; charset=utf-8", "halifax: 8.1 to 14.2 °C today, 5 to 16 °C over 2 days\n"},
+		{"/v1/weather/halifax?format=text", "", "text/plain; charset=utf-8", "halifax: 12.5 °C, wind 9.4 km/h\n"},
+		{"/v1/weather/halifax", "text/csv;q=0.5, text/plain", "text/plain; charset=utf-8", "halifax: 12.5 °C, wind 9.4 km/h\n"},
+		{"/v1/weather/halifax", "*/*", "application/json", `{"current_weather":{"temperature":12.5,"windspeed":9.4,"weathercode":61,"time":"2026-10-18T12:00"}}` + "\n"},
+		{"/v2/weather/halifax?format=json", "", "application/json",
+			`{"city":"halifax","units":"metric","temperature":12.5,"temperature_unit":"°C","wind_speed":9.4,"wind_speed_unit":"km/h",` +
+				`"condition":"Rain","observed_at":"2026-10-18T12:00:00Z"}` + "\n"},
+		{"/v1/user/data?format=text", "", "text/plain; charset=utf-8", "0 saved cities, units metric\n"},
+		{"/v1/user/data", "text/csv", "text/csv; charset=utf-8", "city\n"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s (Accept %q): expected status 200, got %d", tc.path, tc.accept, rec.Code)
+			continue
+		}
+
+		if got := rec.Header().Get("Content-Type"); got != tc.contentType {
+			t.Errorf("%s (Accept %q): expected Content-Type %q, got %q", tc.path, tc.accept, tc.contentType, got)
+		}
+
+		if got := rec.Body.String(); got != tc.body {
+			t.Errorf("%s (Accept %q): expected body %q, got %q", tc.path, tc.accept, tc.body, got)
+		}
+	}
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	t.Parallel()
+
+	r := setupRouter(t)
+
+	for _, tc := range []struct{ path, accept string }{
+		{"/v1/user/data?format=xml", ""},
+		{"/v1/user/data", "application/xml"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNotAcceptable {
+			t.Errorf("%s (Accept %q): expected status 406, got %d", tc.path, tc.accept, rec.Code)
+		}
+	}
+}
+
+func TestForecastCalendar(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/forecast/Saint%20John's.ics", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	req.Header.Set("Accept", "text/calendar")
+
+	rec := httptest.NewRecorder()
+	setupRouter(t).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	if got := rec.Header().Get("Content-Type"); got != "text/calendar; charset=utf-8" {
+		t.Errorf("expected a calendar, got %q", got)
+	}
+
+	body := rec.Body.String()
+	if got := strings.Count(body, "BEGIN:VEVENT"); got != 2 {
+		t.Errorf("expected 2 events, got %d", got)
+	}
+
+	for _, want := range []string{
+		"UID:2026-10-18-saint-john-s@rest-weather\r\n",
+		"UID:2026-10-19-saint-john-s@rest-weather\r\n",
+		"DTSTART;VALUE=DATE:20261018\r\n",
+		`SUMMARY:Rain\, 8.1 to 14.2 °C` + "\r\n",
+		`SUMMARY:Clear sky\, 5 to 16 °C` + "\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected calendar to contain %q, got %q", want, body)
+		}
+	}
+}
+
+func TestUserFeed(t *testing.T) {
+	t.Parallel()
+
+	r := setupRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/user/cities/halifax,berlin", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/user/feed.atom", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	if got := rec.Header().Get("Content-Type"); got != "application/atom+xml; charset=utf-8" {
+		t.Errorf("expected an Atom feed, got %q", got)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<id>urn:rest-weather:city:halifax</id>`,
+		`<id>urn:rest-weather:city:berlin</id>`,
+		`<title>halifax: Rain, 12.5 °C</title>`,
+		`<updated>2026-10-18T12:00:00Z</updated>`,
+		`<summary type="text">Now: Rain, 12.5 °C, wind 9.4 km/h. Tomorrow (2026-10-19): Clear sky, 5 to 16 °C.</summary>`,
+		`href="http://example.com/v1/weather/halifax"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected feed to contain %q, got %s", want, body)
+		}
+	}
+}
+
+func TestWebSocketThroughMiddleware(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(setupRouter(t))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/v1/ws", &websocket.DialOptions{ //nolint:exhaustruct // optional fields
+		HTTPHeader: http.Header{"Authorization": []string{"Bearer admin-secret"}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close(websocket.StatusNormalClosure, "") })
+
+	if err := wsjson.Write(ctx, conn, map[string]any{"type": "subscribe", "cities": []string{"halifax"}}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var msg ws.Message
+	if err := wsjson.Read(ctx, conn, &msg); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if msg.Type != ws.TypeUpdate || msg.Weather == nil || msg.Weather.Condition != "Rain" {
+		t.Errorf("expected an update with condition Rain, got %+v", msg)
+	}
+}
+
+func TestGraphQLThroughMiddleware(t *testing.T) {
+	t.Parallel()
+
+	r := setupRouter(t)
+
+	body := `{"query": "{ city(name: \"halifax\") { current { condition } } }"}`
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(body)))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 without credentials, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v2/graphql", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer admin-secret")
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	if want := `{"data":{"city":{"current":{"condition":"Rain"}}}}`; strings.TrimSpace(rec.Body.String()) != want {
+		t.Errorf("expected %s, got %s", want, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer admin-secret")
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without a query, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// latestVersion is the newest API version, served under /v{latestVersion}.
+	latestVersion = 2
+	// defaultVersion is used by the unversioned paths when Accept doesn't ask for a version.
+	defaultVersion = 1
+
+	// versionHeader reports the API version that handled a request.
+	versionHeader = "API-Version"
+	// mediaTypePrefix and mediaTypeSuffix make up the versioned media type, application/vnd.weather.v2+json.
+	mediaTypePrefix = "application/vnd.weather.v"
+	mediaTypeSuffix = "+json"
+)
+
+var (
+	// deprecatedAt is when the unversioned paths were deprecated.
+	deprecatedAt = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	// sunsetAt is when the unversioned paths will be removed.
+	sunsetAt = time.Date(2027, time.April, 18, 0, 0, 0, 0, time.UTC)
+)
+
+type versionKey struct{}
+
+// apiVersion fixes the API version of the routes under a /v{version} prefix.
+func apiVersion(version int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(versionHeader, strconv.Itoa(version))
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), versionKey{}, version)))
+		})
+	}
+}
+
+// negotiateVersion picks the API version of an unversioned request from its Accept header.
+func negotiateVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, ok := acceptedVersion(r.Header.Values("Accept"))
+		if !ok {
+			http.Error(w, fmt.Sprintf("Unsupported API version, use %s1%s to %s%d%s",
+				mediaTypePrefix, mediaTypeSuffix, mediaTypePrefix, latestVersion, mediaTypeSuffix), http.StatusNotAcceptable)
+
+			return
+		}
+
+		apiVersion(version)(next).ServeHTTP(w, r)
+	})
+}
+
+// acceptedVersion returns the version of the first versioned media type in accept,
+// or defaultVersion if there is none. ok is false for unknown versions.
+func acceptedVersion(accept []string) (int, bool) {
+	for _, header := range accept {
+		for mediaRange := range strings.SplitSeq(header, ",") {
+			mediaType, _, err := mime.ParseMediaType(mediaRange)
+			if err != nil {
+				continue
+			}
+
+			rawVersion, found := strings.CutPrefix(mediaType, mediaTypePrefix)
+			if !found {
+				continue
+			}
+
+			version, err := strconv.Atoi(strings.TrimSuffix(rawVersion, mediaTypeSuffix))
+			if err != nil || version < 1 || version > latestVersion {
+				return 0, false
+			}
+
+			return version, true
+		}
+	}
+
+	return defaultVersion, true
+}
+
+// deprecated marks a response as coming from a deprecated path and links its versioned successor.
+func deprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(deprecatedAt.Unix(), 10))
+		w.Header().Set("Sunset", sunsetAt.Format(http.TimeFormat))
+		w.Header().Set("Link", fmt.Sprintf(`</v%d%s>; rel="successor-version"`, versionFromContext(r.Context()), r.URL.Path))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// versioned serves v1 or v2 depending on the API version of the request.
+func versioned(v1, v2 http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if versionFromContext(r.Context()) >= 2 { //nolint:mnd // API version
+			v2(w, r)
+			return
+		}
+
+		v1(w, r)
+	}
+}
+
+// versionFromContext returns the API version set by apiVersion.
+func versionFromContext(ctx context.Context) int {
+	if version, ok := ctx.Value(versionKey{}).(int); ok {
+		return version
+	}
+
+	return defaultVersion
+}
@@ -0,0 +1,53 @@
+package shared
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff = 10 * time.Minute
+	idBytes    = 8
+)
+
+// WriteJSON encodes v with the given status code.
+func WriteJSON(w http.ResponseWriter, code int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+
+	return nil
+}
+
+// NewID returns a random ID for stored items such as rules, webhooks and deliveries.
+func NewID() string {
+	return RandomHex(idBytes)
+}
+
+// RandomHex returns n random bytes as hex.
+func RandomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// Backoff returns the delay after attempt, doubling from base up to MaxBackoff.
+func Backoff(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for range attempt - 1 {
+		if delay *= 2; delay >= MaxBackoff {
+			return MaxBackoff
+		}
+	}
+
+	return delay
+}
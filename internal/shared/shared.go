@@ -1,8 +1,128 @@
 // Package shared is for shared stuff.
 package shared
 
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
 // UserData is a struct that represents the local userdata.json file used to track preferences.
 type UserData struct {
 	Cities []string `json:"cities"`
 	Units  string   `json:"units"`
 }
+
+// APIKey is a stored API key. Only the SHA-256 hash of the secret is kept.
+type APIKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Hash      string     `json:"hash,omitempty"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Alerts are a user's alert rules and the events they fired, oldest first.
+type Alerts struct {
+	Rules  []AlertRule  `json:"rules"`
+	Events []AlertEvent `json:"events"`
+}
+
+// AlertRule fires when Metric of City is Operator ("above" or "below") Threshold, in Units.
+// Forecast metrics look at the forecast's first Days days, today included.
+type AlertRule struct {
+	ID        string    `json:"id"`
+	City      string    `json:"city"`
+	Metric    string    `json:"metric"`
+	Operator  string    `json:"operator"`
+	Threshold float64   `json:"threshold"`
+	Units     string    `json:"units"`
+	Days      int       `json:"days,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Firing is set while the condition holds, so the rule fires once per episode.
+	Firing bool `json:"firing"`
+}
+
+// AlertEvent is a rule firing. Date is the forecast day that crossed the threshold, if any.
+type AlertEvent struct {
+	ID        string    `json:"id"`
+	RuleID    string    `json:"rule_id"`
+	City      string    `json:"city"`
+	Metric    string    `json:"metric"`
+	Operator  string    `json:"operator"`
+	Threshold float64   `json:"threshold"`
+	Value     float64   `json:"value"`
+	Units     string    `json:"units"`
+	Date      string    `json:"date,omitempty"`
+	FiredAt   time.Time `json:"fired_at"`
+}
+
+// Webhooks are a user's webhook subscriptions and the log of their deliveries.
+type Webhooks struct {
+	Subscriptions []Webhook         `json:"subscriptions"`
+	Deliveries    []WebhookDelivery `json:"deliveries"`
+}
+
+// Webhook receives Events as signed POSTs to URL. Cities limits weather change events.
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Cities    []string  `json:"cities,omitempty"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is an event sent, or to be sent, to a webhook. Payload is the exact body.
+// NextAttemptAt is when a pending delivery that failed is attempted again.
+type WebhookDelivery struct {
+	ID            string          `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	StatusCode    int             `json:"status_code,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+}
+
+// EmailSettings are where and what a user is emailed. LastDigest is the UTC date of the
+// last daily digest queued, so it's sent once a day across restarts.
+type EmailSettings struct {
+	Address    string `json:"address"`
+	Alerts     bool   `json:"alerts"`
+	Digest     bool   `json:"digest"`
+	LastDigest string `json:"last_digest,omitempty"`
+}
+
+// EmailMessage is an email waiting to be sent, kept until it's sent or fails for good.
+// NextAttemptAt is when a message that failed is attempted again.
+type EmailMessage struct {
+	ID            string     `json:"id"`
+	To            string     `json:"to"`
+	Subject       string     `json:"subject"`
+	Text          string     `json:"text"`
+	HTML          string     `json:"html"`
+	Attempts      int        `json:"attempts"`
+	Error         string     `json:"error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+type userIDKey struct{}
+
+// WithUserID returns a copy of ctx that identifies the user whose data should be used.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserIDFromContext returns the user set by WithUserID, or "" for the default user.
+func UserIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey{}).(string)
+	return userID
+}
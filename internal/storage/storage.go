@@ -2,110 +2,635 @@
 package storage
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/codyonesock/rest_weather/internal/logger"
+	"github.com/codyonesock/rest_weather/internal/metrics"
 	"github.com/codyonesock/rest_weather/internal/shared"
+	"github.com/codyonesock/rest_weather/internal/tracing"
 )
 
 // ServiceInterface depicts the interface for the storage package.
 type ServiceInterface interface {
-	LoadUserData() (shared.UserData, error)
-	SaveUserData(userData shared.UserData) error
+	LoadUserData(ctx context.Context) (shared.UserData, error)
+	SaveUserData(ctx context.Context, userData shared.UserData) error
 }
 
+// CityStore depicts the interface for listing the cities saved by any user.
+type CityStore interface {
+	SavedCities(ctx context.Context) ([]string, error)
+}
+
+// AlertStore depicts the interface for persisting alert rules and events.
+// They belong to the user picked by shared.UserIDFromContext, like the user data.
+type AlertStore interface {
+	LoadAlerts(ctx context.Context) (shared.Alerts, error)
+	SaveAlerts(ctx context.Context, alerts shared.Alerts) error
+	AlertUsers(ctx context.Context) ([]string, error)
+}
+
+// WebhookStore depicts the interface for persisting webhook subscriptions and deliveries.
+// They belong to the user picked by shared.UserIDFromContext, like the user data.
+type WebhookStore interface {
+	LoadWebhooks(ctx context.Context) (shared.Webhooks, error)
+	SaveWebhooks(ctx context.Context, webhooks shared.Webhooks) error
+	WebhookUsers(ctx context.Context) ([]string, error)
+}
+
+// EmailStore depicts the interface for persisting email settings and the messages waiting to be sent.
+// They belong to the user picked by shared.UserIDFromContext, like the user data.
+type EmailStore interface {
+	LoadEmailSettings(ctx context.Context) (shared.EmailSettings, error)
+	SaveEmailSettings(ctx context.Context, settings shared.EmailSettings) error
+	LoadEmailOutbox(ctx context.Context) ([]shared.EmailMessage, error)
+	SaveEmailOutbox(ctx context.Context, outbox []shared.EmailMessage) error
+	EmailUsers(ctx context.Context) ([]string, error)
+}
+
+// APIKeyStore depicts the interface for persisting API keys.
+type APIKeyStore interface {
+	LoadAPIKeys(ctx context.Context) ([]shared.APIKey, error)
+	SaveAPIKeys(ctx context.Context, keys []shared.APIKey) error
+}
+
+// document is the layout of the storage file. The user data stays at the top level
+// so files written before other collections existed still load.
+type document struct {
+	shared.UserData
+
+	Users   map[string]shared.UserData `json:"users,omitempty"`
+	APIKeys []shared.APIKey            `json:"api_keys,omitempty"`
+
+	// Alerts, webhooks and email are keyed by user ID, "" being the default user.
+	Alerts      map[string]shared.Alerts         `json:"alerts,omitempty"`
+	Webhooks    map[string]shared.Webhooks       `json:"webhooks,omitempty"`
+	Email       map[string]shared.EmailSettings  `json:"email,omitempty"`
+	EmailOutbox map[string][]shared.EmailMessage `json:"email_outbox,omitempty"`
+}
+
+// userData returns the profile of userID, the top-level data being the default user's.
+func (d *document) userData(userID string) shared.UserData {
+	if userID == "" {
+		return d.UserData
+	}
+
+	if userData, ok := d.Users[userID]; ok {
+		return userData
+	}
+
+	return defaultUserData()
+}
+
+// setUserData replaces the profile of userID.
+func (d *document) setUserData(userID string, userData shared.UserData) {
+	if userID == "" {
+		d.UserData = userData
+		return
+	}
+
+	if d.Users == nil {
+		d.Users = map[string]shared.UserData{}
+	}
+
+	d.Users[userID] = userData
+}
+
+// defaultUserData is the profile of a user that hasn't saved anything yet.
+func defaultUserData() shared.UserData {
+	return shared.UserData{
+		Cities: []string{},
+		Units:  "metric",
+	}
+}
+
+var tracer = tracing.Tracer("github.com/codyonesock/rest_weather/internal/storage")
+
+// ErrClosed is returned when the storage is used after Close.
+var ErrClosed = errors.New("storage is closed")
+
 // Service for dependencies and config.
+// Reads and writes are serialized so Close can wait for pending writes.
 type Service struct {
+	mu       sync.Mutex
+	closed   bool
 	FilePath string
 	Logger   *zap.Logger
+	Metrics  *metrics.Service
 }
 
 // NewStorageService creates a new instance of Service.
 func NewStorageService(filePath string, l *zap.Logger) *Service {
 	return &Service{
+		mu:       sync.Mutex{},
+		closed:   false,
 		FilePath: filePath,
 		Logger:   l,
+		Metrics:  nil,
 	}
 }
 
+// Close waits for any in-progress read or write and rejects later ones.
+func (s *Service) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.Logger.Info("Storage closed", zap.String("filePath", s.FilePath))
+
+	return nil
+}
+
+// Check verifies the user data can be read and that its directory is writable.
+func (s *Service) Check(ctx context.Context) error {
+	if _, err := s.LoadUserData(ctx); err != nil {
+		return fmt.Errorf("storage not readable: %w", err)
+	}
+
+	probe, err := os.CreateTemp(filepath.Dir(s.FilePath), ".healthcheck-*")
+	if err != nil {
+		return fmt.Errorf("storage not writable: %w", err)
+	}
+
+	if err := probe.Close(); err != nil {
+		return fmt.Errorf("storage not writable: %w", err)
+	}
+
+	if err := os.Remove(probe.Name()); err != nil {
+		return fmt.Errorf("failed to remove probe file: %w", err)
+	}
+
+	return nil
+}
+
 // LoadUserData loads the data from a local file. If it doesn't exist, it creates a default one.
-func (s *Service) LoadUserData() (shared.UserData, error) {
-	file, err := os.Open(s.FilePath)
+// The profile is picked by shared.UserIDFromContext, the default user if there is none.
+func (s *Service) LoadUserData(ctx context.Context) (shared.UserData, error) {
+	ctx, span := tracer.Start(ctx, "storage.LoadUserData")
+	defer span.End()
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer s.observe("load", time.Now())
+
+	doc, err := s.load(ctx)
 	if err != nil {
-		if os.IsNotExist(err) {
-			s.Logger.Info("creating default file", zap.String("filePath", s.FilePath))
-			return s.createDefaultUserData()
+		return shared.UserData{}, err
+	}
+
+	return doc.userData(shared.UserIDFromContext(ctx)), nil
+}
+
+// SaveUserData saves user data to the local file.
+func (s *Service) SaveUserData(ctx context.Context, userData shared.UserData) error {
+	ctx, span := tracer.Start(ctx, "storage.SaveUserData")
+	defer span.End()
+
+	return s.update(ctx, func(doc *document) {
+		doc.setUserData(shared.UserIDFromContext(ctx), userData)
+	})
+}
+
+// SavedCities returns every city saved by any user, once each ignoring case, in the order first saved.
+func (s *Service) SavedCities(ctx context.Context) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "storage.SavedCities")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer s.observe("load", time.Now())
+
+	doc, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	cities := []string{}
+
+	profiles := []shared.UserData{doc.UserData}
+	for _, userID := range slices.Sorted(maps.Keys(doc.Users)) {
+		profiles = append(profiles, doc.Users[userID])
+	}
+
+	for _, userData := range profiles {
+		for _, city := range userData.Cities {
+			if key := strings.ToLower(city); !seen[key] {
+				seen[key] = true
+				cities = append(cities, city)
+			}
 		}
+	}
 
-		s.Logger.Error("Failed to open file", zap.Error(err))
+	return cities, nil
+}
 
-		return shared.UserData{}, fmt.Errorf("failed to open file: %w", err)
+// LoadAlerts loads the alert rules and events of the user in ctx.
+func (s *Service) LoadAlerts(ctx context.Context) (shared.Alerts, error) {
+	ctx, span := tracer.Start(ctx, "storage.LoadAlerts")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer s.observe("load", time.Now())
+
+	doc, err := s.load(ctx)
+	if err != nil {
+		return shared.Alerts{}, err
 	}
 
-	defer func() {
-		if err := file.Close(); err != nil {
-			s.Logger.Error("Error closing file", zap.Error(err))
+	alerts := doc.Alerts[shared.UserIDFromContext(ctx)]
+	if alerts.Rules == nil {
+		alerts.Rules = []shared.AlertRule{}
+	}
+
+	if alerts.Events == nil {
+		alerts.Events = []shared.AlertEvent{}
+	}
+
+	return alerts, nil
+}
+
+// SaveAlerts replaces the alert rules and events of the user in ctx.
+func (s *Service) SaveAlerts(ctx context.Context, alerts shared.Alerts) error {
+	ctx, span := tracer.Start(ctx, "storage.SaveAlerts")
+	defer span.End()
+
+	return s.update(ctx, func(doc *document) {
+		if doc.Alerts == nil {
+			doc.Alerts = map[string]shared.Alerts{}
 		}
-	}()
 
-	var userData shared.UserData
-	if err := json.NewDecoder(file).Decode(&userData); err != nil {
-		s.Logger.Error("Failed to decode file", zap.Error(err))
-		return shared.UserData{}, fmt.Errorf("failed to decode file: %w", err)
+		doc.Alerts[shared.UserIDFromContext(ctx)] = alerts
+	})
+}
+
+// AlertUsers returns the IDs of the users with alert rules, sorted.
+func (s *Service) AlertUsers(ctx context.Context) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "storage.AlertUsers")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer s.observe("load", time.Now())
+
+	doc, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	users := []string{}
+
+	for _, userID := range slices.Sorted(maps.Keys(doc.Alerts)) {
+		if len(doc.Alerts[userID].Rules) > 0 {
+			users = append(users, userID)
+		}
 	}
 
-	return userData, nil
+	return users, nil
 }
 
-// SaveUserData saves user data to the local file.
-func (s *Service) SaveUserData(userData shared.UserData) error {
-	file, err := os.Create(s.FilePath)
+// LoadWebhooks loads the webhook subscriptions and deliveries of the user in ctx.
+func (s *Service) LoadWebhooks(ctx context.Context) (shared.Webhooks, error) {
+	ctx, span := tracer.Start(ctx, "storage.LoadWebhooks")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer s.observe("load", time.Now())
+
+	doc, err := s.load(ctx)
 	if err != nil {
-		s.Logger.Error("Failed to create file", zap.Error(err))
-		return fmt.Errorf("failed to create file: %w", err)
+		return shared.Webhooks{}, err
 	}
 
-	defer func() {
-		if err := file.Close(); err != nil {
-			s.Logger.Error("Error closing file", zap.Error(err))
+	webhooks := doc.Webhooks[shared.UserIDFromContext(ctx)]
+	if webhooks.Subscriptions == nil {
+		webhooks.Subscriptions = []shared.Webhook{}
+	}
+
+	if webhooks.Deliveries == nil {
+		webhooks.Deliveries = []shared.WebhookDelivery{}
+	}
+
+	return webhooks, nil
+}
+
+// SaveWebhooks replaces the webhook subscriptions and deliveries of the user in ctx.
+func (s *Service) SaveWebhooks(ctx context.Context, webhooks shared.Webhooks) error {
+	ctx, span := tracer.Start(ctx, "storage.SaveWebhooks")
+	defer span.End()
+
+	return s.update(ctx, func(doc *document) {
+		if doc.Webhooks == nil {
+			doc.Webhooks = map[string]shared.Webhooks{}
 		}
-	}()
 
-	if err := json.NewEncoder(file).Encode(userData); err != nil {
-		s.Logger.Error("Failed to save user data", zap.Error(err))
-		return fmt.Errorf("failed to save user data: %w", err)
+		doc.Webhooks[shared.UserIDFromContext(ctx)] = webhooks
+	})
+}
+
+// WebhookUsers returns the IDs of the users with webhook subscriptions or deliveries, sorted.
+func (s *Service) WebhookUsers(ctx context.Context) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "storage.WebhookUsers")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer s.observe("load", time.Now())
+
+	doc, err := s.load(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	users := []string{}
+
+	for _, userID := range slices.Sorted(maps.Keys(doc.Webhooks)) {
+		if webhooks := doc.Webhooks[userID]; len(webhooks.Subscriptions) > 0 || len(webhooks.Deliveries) > 0 {
+			users = append(users, userID)
+		}
+	}
+
+	return users, nil
 }
 
-// createDefaultUserData creates a default user data file and returns the default data.
-func (s *Service) createDefaultUserData() (shared.UserData, error) {
-	defaultData := shared.UserData{
-		Cities: []string{},
-		Units:  "metric",
+// LoadEmailSettings loads the email settings of the user in ctx.
+func (s *Service) LoadEmailSettings(ctx context.Context) (shared.EmailSettings, error) {
+	ctx, span := tracer.Start(ctx, "storage.LoadEmailSettings")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer s.observe("load", time.Now())
+
+	doc, err := s.load(ctx)
+	if err != nil {
+		return shared.EmailSettings{}, err
+	}
+
+	return doc.Email[shared.UserIDFromContext(ctx)], nil
+}
+
+// SaveEmailSettings replaces the email settings of the user in ctx.
+func (s *Service) SaveEmailSettings(ctx context.Context, settings shared.EmailSettings) error {
+	ctx, span := tracer.Start(ctx, "storage.SaveEmailSettings")
+	defer span.End()
+
+	return s.update(ctx, func(doc *document) {
+		if doc.Email == nil {
+			doc.Email = map[string]shared.EmailSettings{}
+		}
+
+		doc.Email[shared.UserIDFromContext(ctx)] = settings
+	})
+}
+
+// LoadEmailOutbox loads the messages waiting to be sent to the user in ctx.
+func (s *Service) LoadEmailOutbox(ctx context.Context) ([]shared.EmailMessage, error) {
+	ctx, span := tracer.Start(ctx, "storage.LoadEmailOutbox")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer s.observe("load", time.Now())
+
+	doc, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	outbox := doc.EmailOutbox[shared.UserIDFromContext(ctx)]
+	if outbox == nil {
+		outbox = []shared.EmailMessage{}
 	}
 
-	file, err := os.Create(s.FilePath)
+	return outbox, nil
+}
+
+// SaveEmailOutbox replaces the messages waiting to be sent to the user in ctx.
+func (s *Service) SaveEmailOutbox(ctx context.Context, outbox []shared.EmailMessage) error {
+	ctx, span := tracer.Start(ctx, "storage.SaveEmailOutbox")
+	defer span.End()
+
+	return s.update(ctx, func(doc *document) {
+		userID := shared.UserIDFromContext(ctx)
+
+		if len(outbox) == 0 {
+			delete(doc.EmailOutbox, userID)
+			return
+		}
+
+		if doc.EmailOutbox == nil {
+			doc.EmailOutbox = map[string][]shared.EmailMessage{}
+		}
+
+		doc.EmailOutbox[userID] = outbox
+	})
+}
+
+// EmailUsers returns the IDs of the users with an email address or messages waiting, sorted.
+func (s *Service) EmailUsers(ctx context.Context) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "storage.EmailUsers")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer s.observe("load", time.Now())
+
+	doc, err := s.load(ctx)
 	if err != nil {
-		s.Logger.Error("Failed to create file", zap.Error(err))
-		return shared.UserData{}, fmt.Errorf("failed to create file: %w", err)
+		return nil, err
+	}
+
+	users := []string{}
+
+	for userID, settings := range doc.Email {
+		if settings.Address != "" || len(doc.EmailOutbox[userID]) > 0 {
+			users = append(users, userID)
+		}
+	}
+
+	for userID, outbox := range doc.EmailOutbox {
+		if _, ok := doc.Email[userID]; !ok && len(outbox) > 0 {
+			users = append(users, userID)
+		}
+	}
+
+	slices.Sort(users)
+
+	return users, nil
+}
+
+// LoadAPIKeys loads the stored API keys.
+func (s *Service) LoadAPIKeys(ctx context.Context) ([]shared.APIKey, error) {
+	ctx, span := tracer.Start(ctx, "storage.LoadAPIKeys")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer s.observe("load", time.Now())
+
+	doc, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.APIKeys, nil
+}
+
+// SaveAPIKeys replaces the stored API keys.
+func (s *Service) SaveAPIKeys(ctx context.Context, keys []shared.APIKey) error {
+	ctx, span := tracer.Start(ctx, "storage.SaveAPIKeys")
+	defer span.End()
+
+	return s.update(ctx, func(doc *document) {
+		doc.APIKeys = keys
+	})
+}
+
+// update applies fn to the stored document and writes it back, holding the lock throughout.
+func (s *Service) update(ctx context.Context, fn func(doc *document)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer s.observe("save", time.Now())
+
+	doc, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	fn(&doc)
+
+	return s.save(ctx, doc)
+}
+
+// load reads the document from disk, creating a default one if it doesn't exist. Callers hold s.mu.
+func (s *Service) load(ctx context.Context) (document, error) {
+	if s.closed {
+		return document{}, ErrClosed
+	}
+
+	file, err := os.Open(s.FilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.loggerFor(ctx).Info("creating default file", zap.String("filePath", s.FilePath))
+			return s.createDefaultDocument(ctx)
+		}
+
+		s.loggerFor(ctx).Error("Failed to open file", zap.Error(err))
+
+		return document{}, fmt.Errorf("failed to open file: %w", err)
 	}
 
 	defer func() {
 		if err := file.Close(); err != nil {
-			s.Logger.Error("Error closing file", zap.Error(err))
+			s.loggerFor(ctx).Error("Error closing file", zap.Error(err))
 		}
 	}()
 
-	if err := json.NewEncoder(file).Encode(defaultData); err != nil {
-		s.Logger.Error("Failed to write default data", zap.Error(err))
-		return shared.UserData{}, fmt.Errorf("failed to write default data: %w", err)
+	var doc document
+	if err := json.NewDecoder(file).Decode(&doc); err != nil {
+		s.loggerFor(ctx).Error("Failed to decode file", zap.Error(err))
+		return document{}, fmt.Errorf("failed to decode file: %w", err)
+	}
+
+	return doc, nil
+}
+
+// save writes the document to a temp file and renames it over the old one,
+// so a crash mid-write never leaves a truncated file. Callers hold s.mu.
+func (s *Service) save(ctx context.Context, doc document) error {
+	if s.closed {
+		return ErrClosed
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(s.FilePath), filepath.Base(s.FilePath)+".tmp-*")
+	if err != nil {
+		s.loggerFor(ctx).Error("Failed to create file", zap.Error(err))
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	if err := json.NewEncoder(file).Encode(doc); err != nil {
+		s.loggerFor(ctx).Error("Failed to save user data", zap.Error(err))
+		s.discard(ctx, file)
+
+		return fmt.Errorf("failed to save user data: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		s.loggerFor(ctx).Error("Error closing file", zap.Error(err))
+		s.discard(ctx, file)
+
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), s.FilePath); err != nil {
+		s.loggerFor(ctx).Error("Failed to replace file", zap.Error(err))
+		s.discard(ctx, file)
+
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	return nil
+}
+
+// discard closes and removes a temp file after a failed save.
+func (s *Service) discard(ctx context.Context, file *os.File) {
+	_ = file.Close()
+
+	if err := os.Remove(file.Name()); err != nil && !os.IsNotExist(err) {
+		s.loggerFor(ctx).Error("Failed to remove temp file", zap.Error(err))
+	}
+}
+
+// loggerFor returns the request-scoped logger in ctx, falling back to s.Logger.
+func (s *Service) loggerFor(ctx context.Context) *zap.Logger {
+	return logger.FromContext(ctx, s.Logger)
+}
+
+// observe records how long a storage operation took since start.
+func (s *Service) observe(operation string, start time.Time) {
+	s.Metrics.ObserveStorage(operation, time.Since(start))
+}
+
+// createDefaultDocument creates a default user data file and returns the default data.
+func (s *Service) createDefaultDocument(ctx context.Context) (document, error) {
+	defaultDoc := document{
+		UserData: defaultUserData(),
+		Users:    nil,
+		APIKeys:  nil,
+		Alerts:   nil,
+		Webhooks: nil,
+		Email:    nil,
+	}
+
+	if err := s.save(ctx, defaultDoc); err != nil {
+		s.loggerFor(ctx).Error("Failed to write default data", zap.Error(err))
+		return document{}, fmt.Errorf("failed to write default data: %w", err)
 	}
 
-	return defaultData, nil
+	return defaultDoc, nil
 }
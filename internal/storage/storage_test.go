@@ -1,7 +1,9 @@
 package storage_test
 
 import (
+	"errors"
 	"os"
+	"slices"
 	"testing"
 
 	"go.uber.org/zap"
@@ -37,7 +39,7 @@ func TestLoadUserData(t *testing.T) {
 	storageService, cleanup := setupTestStorage(t)
 	defer cleanup()
 
-	userData, err := storageService.LoadUserData()
+	userData, err := storageService.LoadUserData(t.Context())
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -62,11 +64,11 @@ func TestSaveUserData(t *testing.T) {
 		Units:  "metric",
 	}
 
-	if err := storageService.SaveUserData(userData); err != nil {
+	if err := storageService.SaveUserData(t.Context(), userData); err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
 
-	loadedData, err := storageService.LoadUserData()
+	loadedData, err := storageService.LoadUserData(t.Context())
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -79,3 +81,121 @@ func TestSaveUserData(t *testing.T) {
 		t.Errorf("expected units to be 'metric', got %v", loadedData.Units)
 	}
 }
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+
+	storageService, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	if err := storageService.Close(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err := storageService.SaveUserData(t.Context(), shared.UserData{Cities: []string{}, Units: "metric"})
+	if !errors.Is(err, storage.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	storageService, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	if err := storageService.Check(t.Context()); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestAPIKeysKeepUserData(t *testing.T) {
+	t.Parallel()
+
+	storageService, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	if err := storageService.SaveUserData(t.Context(), shared.UserData{Cities: []string{"Halifax"}, Units: "imperial"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := storageService.SaveAPIKeys(t.Context(), []shared.APIKey{{ID: "abc", Name: "kiosk"}}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	userData, err := storageService.LoadUserData(t.Context())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(userData.Cities) != 1 || userData.Units != "imperial" {
+		t.Errorf("expected user data to survive saving keys, got %+v", userData)
+	}
+
+	keys, err := storageService.LoadAPIKeys(t.Context())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(keys) != 1 || keys[0].ID != "abc" {
+		t.Errorf("expected the saved key, got %+v", keys)
+	}
+}
+
+func TestUserProfilesAreSeparate(t *testing.T) {
+	t.Parallel()
+
+	storageService, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	alice := shared.WithUserID(t.Context(), "alice")
+
+	if err := storageService.SaveUserData(alice, shared.UserData{Cities: []string{"Berlin"}, Units: "imperial"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	aliceData, err := storageService.LoadUserData(alice)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(aliceData.Cities) != 1 || aliceData.Cities[0] != "Berlin" {
+		t.Errorf("expected alice's cities to be ['Berlin'], got %v", aliceData.Cities)
+	}
+
+	defaultData, err := storageService.LoadUserData(t.Context())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(defaultData.Cities) != 0 || defaultData.Units != "metric" {
+		t.Errorf("expected the default user to be untouched, got %+v", defaultData)
+	}
+}
+
+func TestSavedCitiesSpansProfiles(t *testing.T) {
+	t.Parallel()
+
+	storageService, cleanup := setupTestStorage(t)
+	defer cleanup()
+
+	for userID, cities := range map[string][]string{
+		"":      {"Halifax", "Berlin"},
+		"alice": {"berlin", "Tokyo"},
+		"bob":   {"Lima"},
+	} {
+		ctx := shared.WithUserID(t.Context(), userID)
+		if err := storageService.SaveUserData(ctx, shared.UserData{Cities: cities, Units: "metric"}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	cities, err := storageService.SavedCities(t.Context())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if want := []string{"Halifax", "Berlin", "Tokyo", "Lima"}; !slices.Equal(cities, want) {
+		t.Errorf("expected %v, got %v", want, cities)
+	}
+}
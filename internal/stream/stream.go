@@ -0,0 +1,495 @@
+// Package stream pushes changes of the current weather to clients as Server-Sent Events.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/logger"
+	"github.com/codyonesock/rest_weather/internal/weather"
+)
+
+const (
+	// MaxCities is the most cities a single stream can subscribe to.
+	MaxCities = 10
+	// historySize is how many updates are kept to resume streams from Last-Event-ID.
+	historySize = 256
+	// bufferSize is how many updates a subscriber may fall behind before it is dropped.
+	bufferSize = 16
+	// retryMillis tells EventSource clients how long to wait before reconnecting.
+	retryMillis = 5000
+)
+
+// err113 demands no dynamic errors!
+var (
+	ErrCitiesRequired       = errors.New("cities is required")
+	ErrTooManyCities        = errors.New("too many cities")
+	ErrStreamingUnsupported = errors.New("streaming unsupported")
+)
+
+// Update is a change of a city's current weather. IDs increase with every update.
+// City is named as the stream follows it, see CityKey.
+type Update struct {
+	ID   uint64
+	City string
+	Data *weather.CurrentWeatherResponse
+}
+
+// Subscription follows a set of cities that may change while it is open.
+type Subscription struct {
+	service *Service
+	cities  map[string]bool
+	updates chan Update
+}
+
+// Service refreshes the followed cities in the background and fans out the changes.
+type Service struct {
+	mu        sync.Mutex
+	Logger    *zap.Logger
+	Weather   *weather.Service
+	Interval  time.Duration
+	Heartbeat time.Duration
+
+	lastID      uint64
+	latest      map[string]Update
+	history     []Update
+	subscribers map[*Subscription]struct{}
+	done        chan struct{}
+}
+
+// NewStreamService creates a new instance of Service.
+// Cities are refreshed every interval and idle streams get a comment every heartbeat.
+func NewStreamService(l *zap.Logger, weatherService *weather.Service, interval, heartbeat time.Duration) *Service {
+	return &Service{
+		mu:          sync.Mutex{},
+		Logger:      l,
+		Weather:     weatherService,
+		Interval:    interval,
+		Heartbeat:   heartbeat,
+		lastID:      0,
+		latest:      map[string]Update{},
+		history:     []Update{},
+		subscribers: map[*Subscription]struct{}{},
+		done:        make(chan struct{}),
+	}
+}
+
+// Run refreshes the subscribed cities every Interval until ctx is done, then ends all streams.
+func (s *Service) Run(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refresh(ctx, s.subscribedCities())
+		}
+	}
+}
+
+// Subscribers returns the number of open streams.
+func (s *Service) Subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.subscribers)
+}
+
+// ServeSSE streams updates of the cities in ?cities= until the client disconnects or the service stops.
+// A Last-Event-ID header (or ?last_event_id=) replays the updates missed since, otherwise the
+// stream starts with the latest known conditions. Errors are only returned before the stream starts.
+func (s *Service) ServeSSE(w http.ResponseWriter, r *http.Request) error {
+	cities, err := parseCities(r.URL.Query().Get("cities"))
+	if err != nil {
+		return err
+	}
+
+	if _, ok := w.(http.Flusher); !ok {
+		return ErrStreamingUnsupported
+	}
+
+	userData, err := s.Weather.UserData(r.Context())
+	if err != nil {
+		return fmt.Errorf("failed to load user data: %w", err)
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	sub := s.Subscribe()
+	defer sub.Close()
+
+	backlog, resumed := sub.resume(lastEventID, cities)
+	if !resumed {
+		backlog = sub.Follow(r.Context(), cities...)
+	}
+
+	// Streams outlive the server's write timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return fmt.Errorf("failed to clear write deadline: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	log := logger.FromContext(r.Context(), s.Logger)
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", retryMillis); err != nil {
+		log.Debug("Stream closed", zap.Error(err))
+		return nil
+	}
+
+	for _, update := range backlog {
+		if err := writeUpdate(w, update, userData.Units); err != nil {
+			log.Debug("Stream closed", zap.Error(err))
+			return nil
+		}
+	}
+
+	_ = rc.Flush()
+
+	heartbeat := time.NewTicker(s.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-s.done:
+			return nil
+		case update, ok := <-sub.Updates():
+			if !ok {
+				log.Warn("Dropping slow stream", zap.Strings("cities", cities))
+				return nil
+			}
+
+			err = writeUpdate(w, update, userData.Units)
+		case <-heartbeat.C:
+			_, err = io.WriteString(w, ": heartbeat\n\n")
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+
+		if err != nil {
+			log.Debug("Stream closed", zap.Error(err))
+			return nil
+		}
+	}
+}
+
+// parseCities splits a comma separated city list, dropping blanks and duplicates.
+func parseCities(raw string) ([]string, error) {
+	cities := []string{}
+	seen := map[string]bool{}
+
+	for city := range strings.SplitSeq(raw, ",") {
+		if city = CityKey(city); city != "" && !seen[city] {
+			seen[city] = true
+			cities = append(cities, city)
+		}
+	}
+
+	switch {
+	case len(cities) == 0:
+		return nil, ErrCitiesRequired
+	case len(cities) > MaxCities:
+		return nil, ErrTooManyCities
+	default:
+		return cities, nil
+	}
+}
+
+// writeUpdate writes update as a weather event with its data in units.
+func writeUpdate(w io.Writer, update Update, units string) error {
+	data, err := json.Marshal(weather.NewWeatherReport(update.City, units, update.Data))
+	if err != nil {
+		return fmt.Errorf("failed to encode update: %w", err)
+	}
+
+	if _, err := fmt.Fprintf(w, "id: %d\nevent: weather\ndata: %s\n\n", update.ID, data); err != nil {
+		return fmt.Errorf("failed to write update: %w", err)
+	}
+
+	return nil
+}
+
+// Subscribe opens a subscription that follows no cities yet. It must be closed when done.
+func (s *Service) Subscribe() *Subscription {
+	sub := &Subscription{service: s, cities: map[string]bool{}, updates: make(chan Update, bufferSize)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscribers[sub] = struct{}{}
+
+	return sub
+}
+
+// Done is closed when the service stops and open subscriptions should end.
+func (s *Service) Done() <-chan struct{} {
+	return s.done
+}
+
+// Updates delivers the changes of the followed cities. It is closed when the
+// subscription falls too far behind or is closed.
+func (sub *Subscription) Updates() <-chan Update {
+	return sub.updates
+}
+
+// CityKey returns city as the stream follows it: trimmed and in lower case, so every client
+// asking for a city, in any case, shares its refreshes and updates.
+func CityKey(city string) string {
+	return strings.ToLower(strings.TrimSpace(city))
+}
+
+// Follow adds cities and returns their latest known updates. Cities nothing is
+// known about yet are fetched in the background with ctx and delivered on Updates.
+func (sub *Subscription) Follow(ctx context.Context, cities ...string) []Update {
+	s := sub.service
+
+	s.mu.Lock()
+
+	latest, missing := []Update{}, []string{}
+	seen := map[string]bool{}
+
+	for _, city := range cities {
+		if city = CityKey(city); seen[city] {
+			continue
+		}
+
+		seen[city] = true
+		sub.cities[city] = true
+
+		if update, ok := s.latest[city]; ok {
+			latest = append(latest, update)
+		} else {
+			missing = append(missing, city)
+		}
+	}
+
+	s.mu.Unlock()
+
+	if len(missing) > 0 {
+		go s.refresh(ctx, missing)
+	}
+
+	return latest
+}
+
+// Unfollow removes cities, forgetting those that nothing follows anymore.
+func (sub *Subscription) Unfollow(cities ...string) {
+	sub.service.mu.Lock()
+	defer sub.service.mu.Unlock()
+
+	sub.unfollow(cities)
+}
+
+// unfollow removes cities. s.mu must be held.
+func (sub *Subscription) unfollow(cities []string) {
+	for _, city := range cities {
+		city = CityKey(city)
+		delete(sub.cities, city)
+
+		if !sub.service.isSubscribed(city) {
+			delete(sub.service.latest, city)
+		}
+	}
+}
+
+// Cities returns the followed cities in order.
+func (sub *Subscription) Cities() []string {
+	sub.service.mu.Lock()
+	defer sub.service.mu.Unlock()
+
+	return slices.Sorted(maps.Keys(sub.cities))
+}
+
+// Latest returns the latest known update of every followed city.
+func (sub *Subscription) Latest() []Update {
+	s := sub.service
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest := []Update{}
+
+	for _, city := range slices.Sorted(maps.Keys(sub.cities)) {
+		if update, ok := s.latest[city]; ok {
+			latest = append(latest, update)
+		}
+	}
+
+	return latest
+}
+
+// Close ends the subscription and unfollows its cities.
+func (sub *Subscription) Close() {
+	s := sub.service
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscribers[sub]; ok {
+		delete(s.subscribers, sub)
+		close(sub.updates)
+	}
+
+	sub.unfollow(slices.Collect(maps.Keys(sub.cities)))
+}
+
+// resume follows cities and returns the updates after lastEventID, if they are still in the history.
+func (sub *Subscription) resume(lastEventID string, cities []string) ([]Update, bool) {
+	id, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	s := sub.service
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.history) == 0 || id > s.lastID || id+1 < s.history[0].ID {
+		return nil, false
+	}
+
+	for _, city := range cities {
+		sub.cities[CityKey(city)] = true
+	}
+
+	missed := []Update{}
+
+	for _, update := range s.history {
+		if update.ID > id && sub.cities[update.City] {
+			missed = append(missed, update)
+		}
+	}
+
+	return missed, true
+}
+
+// isSubscribed reports whether any subscription follows city. s.mu must be held.
+func (s *Service) isSubscribed(city string) bool {
+	for sub := range s.subscribers {
+		if sub.cities[city] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// subscribedCities returns every city followed by at least one subscription.
+func (s *Service) subscribedCities() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := map[string]bool{}
+	cities := []string{}
+
+	for sub := range s.subscribers {
+		for city := range sub.cities {
+			if !seen[city] {
+				seen[city] = true
+				cities = append(cities, city)
+			}
+		}
+	}
+
+	return cities
+}
+
+// refresh fetches the current weather of cities concurrently and publishes the changes.
+func (s *Service) refresh(ctx context.Context, cities []string) {
+	var wg sync.WaitGroup
+
+	for _, city := range cities {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			data, err := s.Weather.CurrentWeather(ctx, city)
+			if err != nil {
+				s.Logger.Warn("Error refreshing streamed city", zap.String("city", city), zap.Error(err))
+				return
+			}
+
+			s.publish(city, data)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// publish sends data to the subscriptions of city if the conditions changed since the last update.
+// Subscriptions that can't keep up are dropped; streams can resume with Last-Event-ID.
+func (s *Service) publish(city string, data *weather.CurrentWeatherResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if previous, ok := s.latest[city]; ok && !changed(previous.Data, data) {
+		return
+	}
+
+	s.lastID++
+	update := Update{ID: s.lastID, City: city, Data: data}
+
+	s.latest[city] = update
+	s.history = append(s.history, update)
+
+	if len(s.history) > historySize {
+		s.history = s.history[len(s.history)-historySize:]
+	}
+
+	for sub := range s.subscribers {
+		if !sub.cities[city] {
+			continue
+		}
+
+		select {
+		case sub.updates <- update:
+		default:
+			delete(s.subscribers, sub)
+			close(sub.updates)
+		}
+	}
+}
+
+// changed reports whether the conditions differ; the observation time alone doesn't count.
+func changed(previous, current *weather.CurrentWeatherResponse) bool {
+	before, after := previous.CurrentWeather, current.CurrentWeather
+	if before.Temperature != after.Temperature || before.Windspeed != after.Windspeed {
+		return true
+	}
+
+	if (before.Weathercode == nil) != (after.Weathercode == nil) {
+		return true
+	}
+
+	return before.Weathercode != nil && *before.Weathercode != *after.Weathercode
+}
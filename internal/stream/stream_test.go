@@ -0,0 +1,215 @@
+package stream_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/stream"
+	"github.com/codyonesock/rest_weather/internal/weather"
+	"github.com/codyonesock/rest_weather/internal/weathertest"
+)
+
+type event struct {
+	id     string
+	report weather.WeatherReport
+}
+
+// setupStream serves a stream service backed by a fake upstream.
+func setupStream(t *testing.T) (*stream.Service, *httptest.Server, *weathertest.Upstream) {
+	t.Helper()
+
+	upstream := weathertest.NewUpstream(t)
+	weatherService, _ := upstream.NewWeatherService(t)
+	streamService := stream.NewStreamService(zap.NewNop(), weatherService, 10*time.Millisecond, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go streamService.Run(ctx)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := streamService.ServeSSE(w, r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return streamService, server, upstream
+}
+
+// connect opens a stream, the returned cancel closes it.
+func connect(t *testing.T, server *httptest.Server, query, lastEventID string) (*bufio.Reader, context.CancelFunc) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?"+query, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", got)
+	}
+
+	return bufio.NewReader(resp.Body), cancel
+}
+
+// nextEvent reads up to the next weather event, skipping comments and the retry field.
+func nextEvent(t *testing.T, br *bufio.Reader) event {
+	t.Helper()
+
+	var e event
+
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatalf("expected an event, got %v", err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			e.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e.report); err != nil {
+				t.Fatalf("expected JSON data, got %v", err)
+			}
+		case line == "" && e.id != "":
+			return e
+		}
+	}
+}
+
+func TestServeSSEPushesChanges(t *testing.T) {
+	t.Parallel()
+
+	_, server, upstream := setupStream(t)
+	br, _ := connect(t, server, "cities=halifax", "")
+
+	first := nextEvent(t, br)
+	if first.id != "1" || first.report.City != "halifax" || first.report.Temperature != 12.5 {
+		t.Errorf("expected event 1 with 12.5 °C in halifax, got %+v", first)
+	}
+
+	if first.report.Condition != "Rain" {
+		t.Errorf("expected condition Rain, got %q", first.report.Condition)
+	}
+
+	upstream.SetTemperature(14)
+
+	second := nextEvent(t, br)
+	if second.id != "2" || second.report.Temperature != 14 {
+		t.Errorf("expected event 2 with 14 °C, got %+v", second)
+	}
+}
+
+func TestServeSSEResumesFromLastEventID(t *testing.T) {
+	t.Parallel()
+
+	_, server, upstream := setupStream(t)
+	br, _ := connect(t, server, "cities=halifax", "")
+
+	nextEvent(t, br)
+	upstream.SetTemperature(14)
+	nextEvent(t, br)
+
+	resumed, _ := connect(t, server, "cities=halifax", "1")
+	if got := nextEvent(t, resumed); got.id != "2" || got.report.Temperature != 14 {
+		t.Errorf("expected the missed event 2, got %+v", got)
+	}
+
+	fresh, _ := connect(t, server, "cities=halifax", "")
+	if got := nextEvent(t, fresh); got.id != "2" {
+		t.Errorf("expected the latest event 2, got %+v", got)
+	}
+}
+
+func TestCitiesAreSharedAcrossCase(t *testing.T) {
+	t.Parallel()
+
+	streamService, server, _ := setupStream(t)
+	br, _ := connect(t, server, "cities=Halifax,HALIFAX", "")
+
+	if got := nextEvent(t, br); got.id != "1" || got.report.City != "halifax" {
+		t.Errorf("expected event 1 in halifax, got %+v", got)
+	}
+
+	sub := streamService.Subscribe()
+	t.Cleanup(sub.Close)
+
+	latest := sub.Follow(context.Background(), " halifax ")
+	if len(latest) != 1 || latest[0].ID != 1 {
+		t.Errorf("expected the latest update of the city already followed, got %+v", latest)
+	}
+}
+
+func TestServeSSECleansUpOnDisconnect(t *testing.T) {
+	t.Parallel()
+
+	streamService, server, _ := setupStream(t)
+	br, disconnect := connect(t, server, "cities=halifax,berlin", "")
+
+	nextEvent(t, br)
+
+	if got := streamService.Subscribers(); got != 1 {
+		t.Errorf("expected 1 subscriber, got %d", got)
+	}
+
+	disconnect()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for streamService.Subscribers() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the subscriber to be removed, got %d", streamService.Subscribers())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServeSSERejectsInvalidCities(t *testing.T) {
+	t.Parallel()
+
+	streamService, _, _ := setupStream(t)
+
+	for _, tc := range []struct {
+		query string
+		want  error
+	}{
+		{"", stream.ErrCitiesRequired},
+		{"cities=,%20,", stream.ErrCitiesRequired},
+		{"cities=a,b,c,d,e,f,g,h,i,j,k", stream.ErrTooManyCities},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/stream/weather?"+tc.query, nil)
+
+		if err := streamService.ServeSSE(httptest.NewRecorder(), req); !errors.Is(err, tc.want) {
+			t.Errorf("expected %v for %q, got %v", tc.want, tc.query, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package tracing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/codyonesock/rest_weather/internal/tracing"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func setupTracing(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return recorder
+}
+
+func TestMiddlewarePropagatesTraceparent(t *testing.T) {
+	recorder := setupTracing(t)
+
+	var outgoing http.Header
+
+	r := chi.NewRouter()
+	r.Use(tracing.Middleware)
+	r.Get("/weather/{city}", func(_ http.ResponseWriter, r *http.Request) {
+		outgoing = http.Header{}
+		tracing.InjectHeaders(r.Context(), outgoing)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/weather/halifax", nil)
+	req.Header.Set("Traceparent", traceparent)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+
+	if spans[0].Name() != "GET /weather/{city}" {
+		t.Errorf("expected span to be named after the route, got %v", spans[0].Name())
+	}
+
+	if spans[0].SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected incoming trace id to be continued, got %v", spans[0].SpanContext().TraceID())
+	}
+
+	if !strings.Contains(outgoing.Get("Traceparent"), "4bf92f3577b34da6a3ce929d0e0e4736") {
+		t.Errorf("expected outgoing traceparent to carry the trace id, got %v", outgoing.Get("Traceparent"))
+	}
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := tracing.Setup(t.Context(), "carrier-pigeon", ""); err == nil {
+		t.Error("expected an error for an unknown exporter")
+	}
+}
+
+func TestSetupStdoutExporter(t *testing.T) {
+	shutdown, err := tracing.Setup(t.Context(), tracing.ExporterStdout, "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := shutdown(t.Context()); err != nil {
+		t.Errorf("expected no error on shutdown, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package weather
+
+import (
+	"context"
+	"sync"
+)
+
+type batchKey struct{}
+
+// batch shares upstream lookups between the callers of one request.
+type batch struct {
+	mu    sync.Mutex
+	calls map[string]*batchCall
+}
+
+// batchCall is a lookup that is in flight or done.
+type batchCall struct {
+	done chan struct{}
+	val  any
+	err  error
+}
+
+// WithBatch returns a context in which geocode and weather lookups are shared: each city is
+// geocoded and each weather URL fetched at most once, however many concurrent callers ask for it.
+// It's meant for a single request that resolves many cities, such as a GraphQL query.
+func WithBatch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, batchKey{}, &batch{mu: sync.Mutex{}, calls: map[string]*batchCall{}})
+}
+
+// batched runs fn once per key within a WithBatch context, and every time outside one.
+// Callers of a key that's in flight wait for its result.
+func batched[T any](ctx context.Context, key string, fn func() (T, error)) (T, error) {
+	b, ok := ctx.Value(batchKey{}).(*batch)
+	if !ok {
+		return fn()
+	}
+
+	b.mu.Lock()
+	call, found := b.calls[key]
+
+	if !found {
+		call = &batchCall{done: make(chan struct{}), val: nil, err: nil}
+		b.calls[key] = call
+	}
+	b.mu.Unlock()
+
+	if found {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err() //nolint:wrapcheck // the caller's own context
+		}
+	} else {
+		call.val, call.err = fn()
+		close(call.done)
+	}
+
+	val, _ := call.val.(T)
+
+	return val, call.err
+}
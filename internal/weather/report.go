@@ -0,0 +1,283 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Unit types a user can choose, open-meteo answers in metric.
+const (
+	UnitsMetric   = "metric"
+	UnitsImperial = "imperial"
+)
+
+const (
+	kmPerMile = 1.609344
+	// observedAtFormat is the ISO 8601 format of open-meteo's current_weather.time.
+	observedAtFormat = "2006-01-02T15:04"
+)
+
+// WeatherReport is the v2 model of the current weather, converted to the user's units.
+type WeatherReport struct {
+	City            string  `json:"city"`
+	Units           string  `json:"units"`
+	Temperature     float64 `json:"temperature"`
+	TemperatureUnit string  `json:"temperature_unit"`
+	WindSpeed       float64 `json:"wind_speed"`
+	WindSpeedUnit   string  `json:"wind_speed_unit"`
+	Condition       string  `json:"condition,omitempty"`
+	// ObservedAt is when open-meteo observed the conditions, zero if unknown.
+	ObservedAt time.Time `json:"observed_at,omitzero"`
+}
+
+// ForecastDay is a single day of a ForecastReport.
+// Condition is empty unless the forecast URL asks for weather_code.
+type ForecastDay struct {
+	Date      string  `json:"date"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	Condition string  `json:"condition,omitempty"`
+}
+
+// ForecastReport is the v2 model of the daily forecast, converted to the user's units.
+type ForecastReport struct {
+	City            string        `json:"city"`
+	Units           string        `json:"units"`
+	TemperatureUnit string        `json:"temperature_unit"`
+	Days            []ForecastDay `json:"days"`
+}
+
+// NewWeatherReport converts an open-meteo response (°C, km/h) into a WeatherReport in units.
+func NewWeatherReport(city, units string, data *CurrentWeatherResponse) *WeatherReport {
+	report := &WeatherReport{
+		City:            city,
+		Units:           UnitsMetric,
+		Temperature:     data.CurrentWeather.Temperature,
+		TemperatureUnit: "°C",
+		WindSpeed:       data.CurrentWeather.Windspeed,
+		WindSpeedUnit:   "km/h",
+		Condition:       "",
+		ObservedAt:      time.Time{},
+	}
+
+	if data.CurrentWeather.Weathercode != nil {
+		report.Condition = Condition(*data.CurrentWeather.Weathercode)
+	}
+
+	if observedAt, err := time.Parse(observedAtFormat, data.CurrentWeather.Time); err == nil {
+		report.ObservedAt = observedAt
+	}
+
+	if units == UnitsImperial {
+		report.Units = UnitsImperial
+		report.Temperature = fahrenheit(report.Temperature)
+		report.TemperatureUnit = "°F"
+		report.WindSpeed = round(report.WindSpeed / kmPerMile)
+		report.WindSpeedUnit = "mph"
+	}
+
+	return report
+}
+
+// NewForecastReport converts an open-meteo response (°C) into a ForecastReport in units.
+func NewForecastReport(city, units string, data *ForecastResponse) *ForecastReport {
+	report := &ForecastReport{
+		City:            city,
+		Units:           UnitsMetric,
+		TemperatureUnit: "°C",
+		Days:            make([]ForecastDay, 0, len(data.Daily.Dates)),
+	}
+
+	if units == UnitsImperial {
+		report.Units = UnitsImperial
+		report.TemperatureUnit = "°F"
+	}
+
+	for i, date := range data.Daily.Dates {
+		if i >= len(data.Daily.MinTemps) || i >= len(data.Daily.MaxTemps) {
+			break
+		}
+
+		day := ForecastDay{Date: date, Min: data.Daily.MinTemps[i], Max: data.Daily.MaxTemps[i], Condition: ""}
+		if i < len(data.Daily.WeatherCodes) {
+			day.Condition = Condition(data.Daily.WeatherCodes[i])
+		}
+
+		if report.Units == UnitsImperial {
+			day.Min, day.Max = fahrenheit(day.Min), fahrenheit(day.Max)
+		}
+
+		report.Days = append(report.Days, day)
+	}
+
+	return report
+}
+
+// CurrentWeatherReport returns the current weather of city in the user's units.
+func (s *Service) CurrentWeatherReport(ctx context.Context, city string) (*WeatherReport, error) {
+	data, err := s.CurrentWeather(ctx, city)
+	if err != nil {
+		return nil, err
+	}
+
+	units, err := s.userUnits(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWeatherReport(city, units, data), nil
+}
+
+// ForecastReport returns the daily forecast of city in the user's units.
+func (s *Service) ForecastReport(ctx context.Context, city string) (*ForecastReport, error) {
+	data, err := s.Forecast(ctx, city)
+	if err != nil {
+		return nil, err
+	}
+
+	units, err := s.userUnits(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewForecastReport(city, units, data), nil
+}
+
+// userUnits returns the units saved for the user of ctx.
+func (s *Service) userUnits(ctx context.Context) (string, error) {
+	userData, err := s.Storage.LoadUserData(ctx)
+	if err != nil {
+		s.loggerFor(ctx).Error("Error loading user data", zap.Error(err))
+		return "", fmt.Errorf("failed to load user data: %w", err)
+	}
+
+	return userData.Units, nil
+}
+
+// Condition describes a WMO weather interpretation code as used by open-meteo.
+//
+//nolint:mnd // WMO codes
+func Condition(code int) string {
+	switch code {
+	case 0:
+		return "Clear sky"
+	case 1:
+		return "Mainly clear"
+	case 2:
+		return "Partly cloudy"
+	case 3:
+		return "Overcast"
+	case 45, 48:
+		return "Fog"
+	case 51, 53, 55:
+		return "Drizzle"
+	case 56, 57:
+		return "Freezing drizzle"
+	case 61, 63, 65:
+		return "Rain"
+	case 66, 67:
+		return "Freezing rain"
+	case 71, 73, 75, 77:
+		return "Snow"
+	case 80, 81, 82:
+		return "Rain showers"
+	case 85, 86:
+		return "Snow showers"
+	case 95:
+		return "Thunderstorm"
+	case 96, 99:
+		return "Thunderstorm with hail"
+	default:
+		return "Unknown"
+	}
+}
+
+// Temperature converts an open-meteo temperature (°C) to units.
+func Temperature(celsius float64, units string) float64 {
+	if units == UnitsImperial {
+		return fahrenheit(celsius)
+	}
+
+	return celsius
+}
+
+// WindSpeed converts an open-meteo wind speed (km/h) to units.
+func WindSpeed(kmh float64, units string) float64 {
+	if units == UnitsImperial {
+		return round(kmh / kmPerMile)
+	}
+
+	return kmh
+}
+
+// fahrenheit converts °C to °F.
+func fahrenheit(celsius float64) float64 {
+	return round(celsius*9/5 + 32) //nolint:mnd // conversion formula
+}
+
+// round rounds to one decimal, the precision open-meteo reports.
+func round(v float64) float64 {
+	return math.Round(v*10) / 10 //nolint:mnd // one decimal
+}
+
+// CityReport is the current weather and forecast of a saved city. Err is set if either failed.
+type CityReport struct {
+	City     string
+	Weather  *WeatherReport
+	Forecast *ForecastReport
+	Err      error
+}
+
+// SavedCityReports fetches a CityReport for every saved city of the user in ctx, concurrently.
+// A failing city doesn't fail the others; its report has Err set.
+func (s *Service) SavedCityReports(ctx context.Context) ([]CityReport, error) {
+	userData, err := s.UserData(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]CityReport, len(userData.Cities))
+
+	var wg sync.WaitGroup
+
+	for i, city := range userData.Cities {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			reports[i] = s.cityReport(ctx, city, userData.Units)
+		}()
+	}
+
+	wg.Wait()
+
+	return reports, nil
+}
+
+// cityReport fetches the current weather and forecast of city in units.
+func (s *Service) cityReport(ctx context.Context, city, units string) CityReport {
+	report := CityReport{City: city, Weather: nil, Forecast: nil, Err: nil}
+
+	weatherData, err := s.CurrentWeather(ctx, city)
+	if err != nil {
+		report.Err = err
+		return report
+	}
+
+	forecastData, err := s.Forecast(ctx, city)
+	if err != nil {
+		report.Err = err
+		return report
+	}
+
+	report.Weather = NewWeatherReport(city, units, weatherData)
+	report.Forecast = NewForecastReport(city, units, forecastData)
+
+	return report
+}
@@ -10,12 +10,19 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
+	"github.com/codyonesock/rest_weather/internal/cache"
+	"github.com/codyonesock/rest_weather/internal/logger"
+	"github.com/codyonesock/rest_weather/internal/metrics"
 	"github.com/codyonesock/rest_weather/internal/shared"
 	"github.com/codyonesock/rest_weather/internal/storage"
+	"github.com/codyonesock/rest_weather/internal/tracing"
 )
 
 // GeocodeResponse is a struct based on geocode data returned from open-meteo.
@@ -26,11 +33,20 @@ type GeocodeResponse struct {
 	} `json:"results"`
 }
 
+// Coordinates is the lat/lon of a city, as cached from the geocode API.
+type Coordinates struct {
+	Latitude  float64
+	Longitude float64
+}
+
 // CurrentWeatherResponse is a struct based on current weather data returned from open-meteo.
 type CurrentWeatherResponse struct {
 	CurrentWeather struct {
 		Temperature float64 `json:"temperature"`
 		Windspeed   float64 `json:"windspeed"`
+		Weathercode *int    `json:"weathercode,omitempty"`
+		// Time is when the conditions were observed, in GMT (e.g. 2026-10-18T12:00).
+		Time string `json:"time,omitempty"`
 	} `json:"current_weather"`
 }
 
@@ -40,16 +56,30 @@ type ForecastResponse struct {
 		Dates    []string  `json:"time"`
 		MaxTemps []float64 `json:"temperature_2m_max"`
 		MinTemps []float64 `json:"temperature_2m_min"`
+		// WeatherCodes are WMO codes, only present if weather_code is in the forecast URL's daily list.
+		WeatherCodes []int `json:"weather_code,omitempty"`
+		// MaxWindSpeeds are km/h, only present if wind_speed_10m_max is in the forecast URL's daily list.
+		MaxWindSpeeds []float64 `json:"wind_speed_10m_max,omitempty"`
 	} `json:"daily"`
 }
 
 // Service handles dependencies and config.
+// The API URLs can be swapped at runtime with UpdateAPIURLs.
 type Service struct {
+	mu                    sync.RWMutex
 	Logger                *zap.Logger
 	Storage               storage.ServiceInterface
 	CurrentWeatherAPIURL  string
 	ForecastWeatherAPIURL string
 	GeocodeAPIURL         string
+
+	// Client sends the upstream requests; tests point it at an httptest server.
+	Client *http.Client
+
+	// Optional dependencies, nil disables them.
+	Metrics      *metrics.Service
+	GeocodeCache *cache.Cache[Coordinates]
+	WeatherCache *cache.Cache[[]byte]
 }
 
 // NewWeatherService create a new instance of Service.
@@ -61,97 +91,139 @@ func NewWeatherService(
 	geocodeAPIURL string,
 ) *Service {
 	return &Service{
+		mu:                    sync.RWMutex{},
 		Logger:                l,
 		Storage:               si,
 		CurrentWeatherAPIURL:  currentWeatherAPIURL,
 		ForecastWeatherAPIURL: forecastWeatherAPIURL,
 		GeocodeAPIURL:         geocodeAPIURL,
+		Client:                http.DefaultClient,
+		Metrics:               nil,
+		GeocodeCache:          nil,
+		WeatherCache:          nil,
 	}
 }
 
+// loggerFor returns the request-scoped logger in ctx, falling back to s.Logger.
+func (s *Service) loggerFor(ctx context.Context) *zap.Logger {
+	return logger.FromContext(ctx, s.Logger)
+}
+
+// UpdateAPIURLs atomically replaces the upstream API URLs, e.g. after a config reload.
+func (s *Service) UpdateAPIURLs(currentWeatherAPIURL, forecastWeatherAPIURL, geocodeAPIURL string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.CurrentWeatherAPIURL = currentWeatherAPIURL
+	s.ForecastWeatherAPIURL = forecastWeatherAPIURL
+	s.GeocodeAPIURL = geocodeAPIURL
+}
+
+// APIURLs returns a consistent snapshot of the upstream API URLs.
+func (s *Service) APIURLs() (string, string, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.CurrentWeatherAPIURL, s.ForecastWeatherAPIURL, s.GeocodeAPIURL
+}
+
 const contextTimeout = 5 * time.Second
 
+var tracer = tracing.Tracer("github.com/codyonesock/rest_weather/internal/weather")
+
 // err113 demands no dynamic errors!
 var (
 	ErrInvalidURL       = errors.New("invalid URL")
 	ErrNoResultsForCity = errors.New("no results for city")
 	ErrCityRequired     = errors.New("city is required")
 	ErrInvalidUnit      = errors.New("invalid unit type")
+	ErrUpstreamStatus   = errors.New("unexpected upstream status")
 )
 
-// GetCurrentWeatherByCity returns the current weather (temperature and weather speed).
-func (s *Service) GetCurrentWeatherByCity(
-	w http.ResponseWriter,
-	city string,
-) (*CurrentWeatherResponse, error) {
+// CurrentWeather fetches the current weather for city without writing a response.
+func (s *Service) CurrentWeather(ctx context.Context, city string) (*CurrentWeatherResponse, error) {
+	currentWeatherAPIURL, _, _ := s.APIURLs()
+
 	var weatherData CurrentWeatherResponse
-	if err := s.getWeatherData(city, s.CurrentWeatherAPIURL, &weatherData); err != nil {
-		s.Logger.Error("Failed to get weather data", zap.Error(err))
+	if err := s.getWeatherData(ctx, city, currentWeatherAPIURL, &weatherData, false); err != nil {
+		s.loggerFor(ctx).Error("Failed to get weather data", zap.Error(err))
 		return nil, fmt.Errorf("failed to get weather data for city %s: %w", city, err)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-
-	if err := json.NewEncoder(w).Encode(weatherData); err != nil {
-		s.Logger.Error("Error encoding weatherData", zap.Error(err))
-		return nil, fmt.Errorf("failed to encode weatherData: %w", err)
-	}
-
 	return &weatherData, nil
 }
 
-// GetForecastByCity returns a 7 day forecast (dates, min/max temps) using the lat/lon of the city entered.
-func (s *Service) GetForecastByCity(
-	w http.ResponseWriter,
-	city string,
-) (*ForecastResponse, error) {
+// Forecast fetches the daily forecast for city without writing a response.
+func (s *Service) Forecast(ctx context.Context, city string) (*ForecastResponse, error) {
+	_, forecastWeatherAPIURL, _ := s.APIURLs()
+
 	var forecastData ForecastResponse
-	if err := s.getWeatherData(city, s.ForecastWeatherAPIURL, &forecastData); err != nil {
-		s.Logger.Error("Failed to get forecast data", zap.Error(err))
+	if err := s.getWeatherData(ctx, city, forecastWeatherAPIURL, &forecastData, false); err != nil {
+		s.loggerFor(ctx).Error("Failed to get forecast data", zap.Error(err))
 		return nil, fmt.Errorf("failed to get forecast data for city %s: %w", city, err)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	return &forecastData, nil
+}
+
+// Refresh fetches the current weather and forecast of city past the cache and caches them,
+// so the next requests for city don't wait on the upstream.
+func (s *Service) Refresh(ctx context.Context, city string) (*CurrentWeatherResponse, *ForecastResponse, error) {
+	currentWeatherAPIURL, forecastWeatherAPIURL, _ := s.APIURLs()
+
+	var weatherData CurrentWeatherResponse
+	if err := s.getWeatherData(ctx, city, currentWeatherAPIURL, &weatherData, true); err != nil {
+		return nil, nil, fmt.Errorf("failed to refresh weather data for city %s: %w", city, err)
+	}
 
-	if err := json.NewEncoder(w).Encode(forecastData); err != nil {
-		s.Logger.Error("Error encoding forecastData", zap.Error(err))
-		return nil, fmt.Errorf("failed to encode forecastData: %w", err)
+	var forecastData ForecastResponse
+	if err := s.getWeatherData(ctx, city, forecastWeatherAPIURL, &forecastData, true); err != nil {
+		return nil, nil, fmt.Errorf("failed to refresh forecast data for city %s: %w", city, err)
 	}
 
-	return &forecastData, nil
+	return &weatherData, &forecastData, nil
 }
 
-// GetUserData returns user data that's read from a local json file.
-func (s *Service) GetUserData(w http.ResponseWriter) (*shared.UserData, error) {
-	userData, err := s.Storage.LoadUserData()
+// UserData loads the user data without writing a response.
+func (s *Service) UserData(ctx context.Context) (*shared.UserData, error) {
+	userData, err := s.Storage.LoadUserData(ctx)
 	if err != nil {
-		s.Logger.Error("Error loading user data", zap.Error(err))
+		s.loggerFor(ctx).Error("Error loading user data", zap.Error(err))
 		return nil, fmt.Errorf("failed to load user data: %w", err)
 	}
 
+	return &userData, nil
+}
+
+// AddCity will add the passed in cities to your user data.
+func (s *Service) AddCity(ctx context.Context, w http.ResponseWriter, city string) error {
+	userData, err := s.AddCities(ctx, strings.Split(city, ","))
+	if err != nil {
+		return err
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
 	if err := json.NewEncoder(w).Encode(userData); err != nil {
-		s.Logger.Error("Error encoding user data", zap.Error(err))
-		return nil, fmt.Errorf("failed to encode user data: %w", err)
+		s.loggerFor(ctx).Error("Error encoding response", zap.Error(err))
+		return fmt.Errorf("failed to encode response: %w", err)
 	}
 
-	return &userData, nil
+	return nil
 }
 
-// AddCity will add the passed in cities to your user data.
-func (s *Service) AddCity(w http.ResponseWriter, city string) error {
-	if city == "" {
-		return fmt.Errorf("%w", ErrCityRequired)
+// AddCities saves cities that aren't saved yet and returns the updated user data.
+func (s *Service) AddCities(ctx context.Context, cities []string) (*shared.UserData, error) {
+	if !hasCity(cities) {
+		return nil, fmt.Errorf("%w", ErrCityRequired)
 	}
 
-	userData, err := s.Storage.LoadUserData()
+	userData, err := s.Storage.LoadUserData(ctx)
 	if err != nil {
-		s.Logger.Error("Error loading user data", zap.Error(err))
-		return fmt.Errorf("failed to load user data: %w", err)
+		s.loggerFor(ctx).Error("Error loading user data", zap.Error(err))
+		return nil, fmt.Errorf("failed to load user data: %w", err)
 	}
 
-	cities := strings.Split(city, ",")
 	for _, newCity := range cities {
 		newCity = strings.TrimSpace(newCity)
 		if newCity == "" {
@@ -172,34 +244,44 @@ func (s *Service) AddCity(w http.ResponseWriter, city string) error {
 		}
 	}
 
-	if err := s.Storage.SaveUserData(userData); err != nil {
-		s.Logger.Error("Error saving user data", zap.Error(err))
-		return fmt.Errorf("failed to save user data: %w", err)
+	if err := s.Storage.SaveUserData(ctx, userData); err != nil {
+		s.loggerFor(ctx).Error("Error saving user data", zap.Error(err))
+		return nil, fmt.Errorf("failed to save user data: %w", err)
+	}
+
+	return &userData, nil
+}
+
+// DeleteCity will remove the passed in cities from your user data.
+func (s *Service) DeleteCity(ctx context.Context, w http.ResponseWriter, city string) error {
+	userData, err := s.DeleteCities(ctx, strings.Split(city, ","))
+	if err != nil {
+		return err
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 
-	if err := json.NewEncoder(w).Encode(userData); err != nil {
-		s.Logger.Error("Error encoding response", zap.Error(err))
+	if err := json.NewEncoder(w).Encode(userData.Cities); err != nil {
+		s.loggerFor(ctx).Error("Error encoding response", zap.Error(err))
 		return fmt.Errorf("failed to encode response: %w", err)
 	}
 
 	return nil
 }
 
-// DeleteCity will remove the passed in cities from your user data.
-func (s *Service) DeleteCity(w http.ResponseWriter, city string) error {
-	if city == "" {
-		return fmt.Errorf("%w", ErrCityRequired)
+// DeleteCities removes cities from the saved ones and returns the updated user data.
+// Cities that aren't saved are ignored.
+func (s *Service) DeleteCities(ctx context.Context, cities []string) (*shared.UserData, error) {
+	if !hasCity(cities) {
+		return nil, fmt.Errorf("%w", ErrCityRequired)
 	}
 
-	userData, err := s.Storage.LoadUserData()
+	userData, err := s.Storage.LoadUserData(ctx)
 	if err != nil {
-		s.Logger.Error("Error loading user data", zap.Error(err))
-		return fmt.Errorf("failed to load user data: %w", err)
+		s.loggerFor(ctx).Error("Error loading user data", zap.Error(err))
+		return nil, fmt.Errorf("failed to load user data: %w", err)
 	}
 
-	cities := strings.Split(city, ",")
 	for _, cityToRemove := range cities {
 		cityToRemove = strings.TrimSpace(cityToRemove)
 		if cityToRemove == "" {
@@ -218,86 +300,146 @@ func (s *Service) DeleteCity(w http.ResponseWriter, city string) error {
 		}
 
 		if !cityFound {
-			s.Logger.Warn("City not found", zap.String("city", cityToRemove))
+			s.loggerFor(ctx).Warn("City not found", zap.String("city", cityToRemove))
 		}
 	}
 
-	if err := s.Storage.SaveUserData(userData); err != nil {
-		s.Logger.Error("Error saving user data", zap.Error(err))
-		return fmt.Errorf("failed to save user data: %w", err)
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-
-	if err := json.NewEncoder(w).Encode(userData.Cities); err != nil {
-		s.Logger.Error("Error encoding response", zap.Error(err))
-		return fmt.Errorf("failed to encode response: %w", err)
+	if err := s.Storage.SaveUserData(ctx, userData); err != nil {
+		s.loggerFor(ctx).Error("Error saving user data", zap.Error(err))
+		return nil, fmt.Errorf("failed to save user data: %w", err)
 	}
 
-	return nil
+	return &userData, nil
 }
 
 // UpdateUserUnits allows you to update the global unit type. The options are metric and imperial.
 func (s *Service) UpdateUserUnits(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
 	var reqBody struct {
 		Units string `json:"units"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
-		s.Logger.Error("Invalid request body", zap.Error(err))
+		s.loggerFor(ctx).Error("Invalid request body", zap.Error(err))
 		return fmt.Errorf("invalid request body: %w", err)
 	}
 
-	if reqBody.Units != "metric" && reqBody.Units != "imperial" {
-		s.Logger.Warn("Invalid unit type", zap.String("units", reqBody.Units))
-		return fmt.Errorf("%w: %s", ErrInvalidUnit, reqBody.Units)
-	}
-
-	userData, err := s.Storage.LoadUserData()
+	userData, err := s.SetUnits(ctx, reqBody.Units)
 	if err != nil {
-		s.Logger.Error("Error loading user data", zap.Error(err))
-		return fmt.Errorf("failed to load user data: %w", err)
-	}
-
-	userData.Units = reqBody.Units
-	if err := s.Storage.SaveUserData(userData); err != nil {
-		s.Logger.Error("Error saving user data", zap.Error(err))
-		return fmt.Errorf("failed to save user data: %w", err)
+		return err
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 
 	if err := json.NewEncoder(w).Encode(map[string]string{"units": userData.Units}); err != nil {
-		s.Logger.Error("Error encoding response", zap.Error(err))
+		s.loggerFor(ctx).Error("Error encoding response", zap.Error(err))
 		return fmt.Errorf("failed to encode response: %w", err)
 	}
 
 	return nil
 }
 
-// doRequest validates a url, sets up a context, and performs an HTTP request.
-func (s *Service) doRequest(method, rawURL string, body io.Reader) (*http.Response, error) {
+// SetUnits saves the unit type, metric or imperial, and returns the updated user data.
+func (s *Service) SetUnits(ctx context.Context, units string) (*shared.UserData, error) {
+	if units != UnitsMetric && units != UnitsImperial {
+		s.loggerFor(ctx).Warn("Invalid unit type", zap.String("units", units))
+		return nil, fmt.Errorf("%w: %s", ErrInvalidUnit, units)
+	}
+
+	userData, err := s.Storage.LoadUserData(ctx)
+	if err != nil {
+		s.loggerFor(ctx).Error("Error loading user data", zap.Error(err))
+		return nil, fmt.Errorf("failed to load user data: %w", err)
+	}
+
+	userData.Units = units
+	if err := s.Storage.SaveUserData(ctx, userData); err != nil {
+		s.loggerFor(ctx).Error("Error saving user data", zap.Error(err))
+		return nil, fmt.Errorf("failed to save user data: %w", err)
+	}
+
+	return &userData, nil
+}
+
+// hasCity reports whether cities has a non-blank entry.
+func hasCity(cities []string) bool {
+	for _, city := range cities {
+		if strings.TrimSpace(city) != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// doRequest validates a url, sets up a context, performs an HTTP request and returns the body.
+func (s *Service) doRequest(ctx context.Context, method, rawURL string, body io.Reader) ([]byte, error) {
 	validatedURL, err := s.validateURL(rawURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to validate URL: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), contextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, contextTimeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, method, validatedURL, body)
 	if err != nil {
-		s.Logger.Error("Failed to create HTTP request", zap.String("url", rawURL), zap.Error(err))
+		s.loggerFor(ctx).Error("Failed to create HTTP request", zap.String("url", rawURL), zap.Error(err))
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	ctx, span := tracer.Start(ctx, method+" "+req.URL.Host, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	span.SetAttributes(attribute.String("http.request.method", method), attribute.String("server.address", req.URL.Host))
+	req = req.WithContext(ctx)
+	tracing.InjectHeaders(ctx, req.Header)
+
+	if requestID := logger.RequestIDFromContext(ctx); requestID != "" {
+		req.Header.Set(logger.RequestIDHeader, requestID)
+	}
+
+	start := time.Now()
+
+	res, err := s.Client.Do(req)
 	if err != nil {
-		s.Logger.Error("Failed to perform HTTP request", zap.String("url", validatedURL), zap.Error(err))
+		s.Metrics.ObserveUpstream(req.URL.Host, time.Since(start), true)
+		s.loggerFor(ctx).Error("Failed to perform HTTP request", zap.String("url", validatedURL), zap.Error(err))
+		tracing.RecordError(span, err)
+
 		return nil, fmt.Errorf("failed to perform HTTP request: %w", err)
 	}
 
-	return res, nil
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			s.loggerFor(ctx).Error("Error closing response body", zap.Error(err))
+		}
+	}()
+
+	span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+
+	// The body is read before returning since the request context is canceled on return.
+	resBody, err := io.ReadAll(res.Body)
+	failed := err != nil || res.StatusCode >= http.StatusBadRequest
+	s.Metrics.ObserveUpstream(req.URL.Host, time.Since(start), failed)
+
+	if err != nil {
+		s.loggerFor(ctx).Error("Failed to read response body", zap.String("url", validatedURL), zap.Error(err))
+		tracing.RecordError(span, err)
+
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		err := fmt.Errorf("%w: %d", ErrUpstreamStatus, res.StatusCode)
+		s.loggerFor(ctx).Error("Unexpected upstream status", zap.String("url", validatedURL), zap.Int("status", res.StatusCode))
+		tracing.RecordError(span, err)
+
+		return nil, err
+	}
+
+	return resBody, nil
 }
 
 // validateStreamURL will validate a url.
@@ -311,59 +453,103 @@ func (s *Service) validateURL(rawURL string) (string, error) {
 }
 
 // GetGeocode returns the geocode for a city. It's primarily used by open-meteo endpoints which only accept lat/lon.
-func (s *Service) getGeocode(city string) (float64, float64, error) {
-	geoURL := fmt.Sprintf(s.GeocodeAPIURL, url.QueryEscape(city))
+func (s *Service) getGeocode(ctx context.Context, city string) (float64, float64, error) {
+	ctx, span := tracer.Start(ctx, "weather.getGeocode")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("city", city))
+
+	cacheKey := strings.ToLower(city)
+	if coords, ok := s.GeocodeCache.Get(cacheKey); ok {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		return coords.Latitude, coords.Longitude, nil
+	}
 
-	res, err := s.doRequest(http.MethodGet, geoURL, nil)
+	coords, err := batched(ctx, "geocode:"+cacheKey, func() (Coordinates, error) {
+		return s.fetchGeocode(ctx, city)
+	})
 	if err != nil {
-		s.Logger.Error("Failed to fetch geocode", zap.String("city", city), zap.Error(err))
-		return 0, 0, fmt.Errorf("failed to get geocode: %w", err)
+		tracing.RecordError(span, err)
+		return 0, 0, err
 	}
 
-	defer func() {
-		if err := res.Body.Close(); err != nil {
-			s.Logger.Error("Error closing response body", zap.Error(err))
-		}
-	}()
+	s.GeocodeCache.Set(cacheKey, coords)
+
+	return coords.Latitude, coords.Longitude, nil
+}
+
+// fetchGeocode asks the geocode API for the coordinates of city.
+func (s *Service) fetchGeocode(ctx context.Context, city string) (Coordinates, error) {
+	_, _, geocodeAPIURL := s.APIURLs()
+	geoURL := fmt.Sprintf(geocodeAPIURL, url.QueryEscape(city))
+
+	resBody, err := s.doRequest(ctx, http.MethodGet, geoURL, nil)
+	if err != nil {
+		s.loggerFor(ctx).Error("Failed to fetch geocode", zap.String("city", city), zap.Error(err))
+		return Coordinates{}, fmt.Errorf("failed to get geocode: %w", err)
+	}
 
 	var geoData GeocodeResponse
-	if err := json.NewDecoder(res.Body).Decode(&geoData); err != nil || len(geoData.Results) == 0 {
-		s.Logger.Error("No geocode results", zap.String("city", city), zap.Error(err))
-		return 0, 0, fmt.Errorf("%w: %s", ErrNoResultsForCity, city)
+	if err := json.Unmarshal(resBody, &geoData); err != nil || len(geoData.Results) == 0 {
+		s.loggerFor(ctx).Error("No geocode results", zap.String("city", city), zap.Error(err))
+		return Coordinates{}, fmt.Errorf("%w: %s", ErrNoResultsForCity, city)
 	}
 
-	return geoData.Results[0].Latitude, geoData.Results[0].Longitude, nil
+	return Coordinates{Latitude: geoData.Results[0].Latitude, Longitude: geoData.Results[0].Longitude}, nil
 }
 
 // GetWeatherData returns weather data based on the passed in url and struct.
-func (s *Service) getWeatherData(city string, url string, respStruct interface{}) error {
+// With skipCache the upstream is always asked and the cache only written.
+func (s *Service) getWeatherData(ctx context.Context, city string, url string, respStruct interface{}, skipCache bool) error {
 	if city == "" {
 		return ErrCityRequired
 	}
 
-	lat, lon, err := s.getGeocode(city)
+	ctx, span := tracer.Start(ctx, "weather.getWeatherData")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("city", city))
+
+	lat, lon, err := s.getGeocode(ctx, city)
 	if err != nil {
+		tracing.RecordError(span, err)
 		return fmt.Errorf("failed to get geocode: %w", err)
 	}
 
 	weatherURL := fmt.Sprintf(url, lat, lon)
 
-	res, err := s.doRequest(http.MethodGet, weatherURL, nil)
-	if err != nil {
-		s.Logger.Error("Failed to get weather data", zap.String("url", weatherURL), zap.Error(err))
-		return fmt.Errorf("failed to get weather data: %w", err)
+	var (
+		resBody []byte
+		ok      bool
+	)
+
+	if !skipCache {
+		resBody, ok = s.WeatherCache.Get(weatherURL)
+		span.SetAttributes(attribute.Bool("cache.hit", ok))
 	}
 
-	defer func() {
-		if err := res.Body.Close(); err != nil {
-			s.Logger.Error("Error closing response body", zap.Error(err))
+	if !ok {
+		resBody, err = batched(ctx, "weather:"+weatherURL, func() ([]byte, error) {
+			return s.doRequest(ctx, http.MethodGet, weatherURL, nil)
+		})
+		if err != nil {
+			s.loggerFor(ctx).Error("Failed to get weather data", zap.String("url", weatherURL), zap.Error(err))
+			tracing.RecordError(span, err)
+
+			return fmt.Errorf("failed to get weather data: %w", err)
 		}
-	}()
+	}
+
+	if err := json.Unmarshal(resBody, respStruct); err != nil {
+		s.loggerFor(ctx).Error("Failed to decode weather data", zap.String("url", weatherURL), zap.Error(err))
+		tracing.RecordError(span, err)
 
-	if err := json.NewDecoder(res.Body).Decode(respStruct); err != nil {
-		s.Logger.Error("Failed to decode weather data", zap.String("url", weatherURL), zap.Error(err))
 		return fmt.Errorf("failed to decode weather data: %w", err)
 	}
 
+	if !ok {
+		s.WeatherCache.Set(weatherURL, resBody)
+	}
+
 	return nil
 }
@@ -2,7 +2,7 @@ package weather_test
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -10,6 +10,7 @@ import (
 
 	"github.com/codyonesock/rest_weather/internal/shared"
 	"github.com/codyonesock/rest_weather/internal/weather"
+	"github.com/codyonesock/rest_weather/internal/weathertest"
 	"go.uber.org/zap"
 )
 
@@ -18,11 +19,11 @@ type MockStorage struct {
 	SaveUserDataFunc func(shared.UserData) error
 }
 
-func (m *MockStorage) LoadUserData() (shared.UserData, error) {
+func (m *MockStorage) LoadUserData(_ context.Context) (shared.UserData, error) {
 	return m.LoadUserDataFunc()
 }
 
-func (m *MockStorage) SaveUserData(data shared.UserData) error {
+func (m *MockStorage) SaveUserData(_ context.Context, data shared.UserData) error {
 	return m.SaveUserDataFunc(data)
 }
 
@@ -44,50 +45,37 @@ func setupMockWeatherService() (*weather.Service, *MockStorage) {
 	return weatherService, mockStorage
 }
 
-func TestGetCurrentWeatherByCity(t *testing.T) {
+func TestCurrentWeather(t *testing.T) {
 	t.Parallel()
 
-	weatherService, _ := setupMockWeatherService()
+	weatherService, _ := weathertest.NewUpstream(t).NewWeatherService(t)
 
-	rec := httptest.NewRecorder()
-
-	_, err := weatherService.GetCurrentWeatherByCity(rec, "halifax")
+	weatherData, err := weatherService.CurrentWeather(t.Context(), "halifax")
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
 
-	if rec.Code != http.StatusOK {
-		t.Errorf("expected status code %d, got %d", http.StatusOK, rec.Code)
+	if weatherData.CurrentWeather.Temperature != 12.5 {
+		t.Errorf("expected temperature to be 12.5, got %v", weatherData.CurrentWeather.Temperature)
 	}
 }
 
-func TestGetForecastByCity(t *testing.T) {
+func TestForecast(t *testing.T) {
 	t.Parallel()
 
-	weatherService, _ := setupMockWeatherService()
+	weatherService, _ := weathertest.NewUpstream(t).NewWeatherService(t)
 
-	rec := httptest.NewRecorder()
-
-	_, err := weatherService.GetForecastByCity(rec, "halifax")
+	forecastData, err := weatherService.Forecast(t.Context(), "halifax")
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
 
-	if rec.Code != http.StatusOK {
-		t.Errorf("expected status code %d, got %d", http.StatusOK, rec.Code)
-	}
-
-	var response map[string]interface{}
-	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
-		t.Fatalf("failed to decode response: %v", err)
-	}
-
-	if _, ok := response["daily"]; !ok {
-		t.Errorf("expected 'daily' in response, got %v", response)
+	if len(forecastData.Daily.Dates) != 2 || forecastData.Daily.Dates[0] != "2026-10-18" {
+		t.Errorf("expected two forecast days from 2026-10-18, got %v", forecastData.Daily.Dates)
 	}
 }
 
-func TestGetUserData(t *testing.T) {
+func TestUserData(t *testing.T) {
 	t.Parallel()
 
 	weatherService, mockStorage := setupMockWeatherService()
@@ -99,22 +87,11 @@ func TestGetUserData(t *testing.T) {
 		}, nil
 	}
 
-	rec := httptest.NewRecorder()
-
-	_, err := weatherService.GetUserData(rec)
+	response, err := weatherService.UserData(t.Context())
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
 
-	if rec.Code != http.StatusOK {
-		t.Errorf("expected status code %d, got %d", http.StatusOK, rec.Code)
-	}
-
-	var response shared.UserData
-	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
-		t.Fatalf("failed to decode response: %v", err)
-	}
-
 	if len(response.Cities) != 2 || response.Cities[0] != "Halifax" || response.Cities[1] != "Berlin" {
 		t.Errorf("expected cities to be ['Halifax', 'Berlin'], got %v", response.Cities)
 	}
@@ -145,7 +122,7 @@ func TestAddCity(t *testing.T) {
 
 	rec := httptest.NewRecorder()
 
-	err := weatherService.AddCity(rec, "Berlin")
+	err := weatherService.AddCity(t.Context(), rec, "Berlin")
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -176,7 +153,7 @@ func TestDeleteCity(t *testing.T) {
 
 	rec := httptest.NewRecorder()
 
-	err := weatherService.DeleteCity(rec, "Berlin")
+	err := weatherService.DeleteCity(t.Context(), rec, "Berlin")
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -218,3 +195,39 @@ func TestUpdateUserUnits(t *testing.T) {
 		t.Errorf("expected status code %d, got %d", http.StatusOK, rec.Code)
 	}
 }
+
+func TestNewWeatherReport(t *testing.T) {
+	t.Parallel()
+
+	var data weather.CurrentWeatherResponse
+	data.CurrentWeather.Temperature = 20
+	data.CurrentWeather.Windspeed = 16.09344
+
+	metric := weather.NewWeatherReport("halifax", "metric", &data)
+	if metric.Temperature != 20 || metric.TemperatureUnit != "°C" || metric.WindSpeedUnit != "km/h" {
+		t.Errorf("expected 20 °C in km/h, got %+v", metric)
+	}
+
+	imperial := weather.NewWeatherReport("halifax", "imperial", &data)
+	if imperial.Temperature != 68 || imperial.WindSpeed != 10 || imperial.WindSpeedUnit != "mph" {
+		t.Errorf("expected 68 °F and 10 mph, got %+v", imperial)
+	}
+}
+
+func TestNewForecastReport(t *testing.T) {
+	t.Parallel()
+
+	var data weather.ForecastResponse
+	data.Daily.Dates = []string{"2026-10-18", "2026-10-19"}
+	data.Daily.MinTemps = []float64{0, 10}
+	data.Daily.MaxTemps = []float64{10, 20}
+
+	report := weather.NewForecastReport("halifax", "imperial", &data)
+	if len(report.Days) != 2 {
+		t.Fatalf("expected 2 days, got %d", len(report.Days))
+	}
+
+	if day := report.Days[1]; day.Date != "2026-10-19" || day.Min != 50 || day.Max != 68 {
+		t.Errorf("expected 2026-10-19 from 50 to 68 °F, got %+v", day)
+	}
+}
@@ -0,0 +1,179 @@
+// Package weathertest fakes the open-meteo APIs, so tests don't depend on the network.
+package weathertest
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/storage"
+	"github.com/codyonesock/rest_weather/internal/weather"
+)
+
+// UnknownCity is the one city the geocode API has no results for.
+const UnknownCity = "nowhere"
+
+// Current is the current weather the upstream reports.
+type Current struct {
+	Temperature float64 `json:"temperature"`
+	Windspeed   float64 `json:"windspeed"`
+	Weathercode int     `json:"weathercode"`
+	Time        string  `json:"time"`
+}
+
+// Forecast is the daily forecast the upstream reports.
+type Forecast struct {
+	Dates        []string  `json:"time"`
+	MinTemps     []float64 `json:"temperature_2m_min"`
+	MaxTemps     []float64 `json:"temperature_2m_max"`
+	WeatherCodes []int     `json:"weather_code"`
+}
+
+// Upstream serves the geocode, current weather and forecast APIs over TLS.
+// Every city but UnknownCity is at the same coordinates and has the weather set on the upstream.
+type Upstream struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	current  Current
+	forecast Forecast
+	delay    time.Duration
+
+	requests    map[string]int
+	inFlight    int
+	maxInFlight int
+}
+
+// NewUpstream starts an upstream reporting rain at 12.5 °C, and a rainy then a clear day.
+// It's closed when the test ends.
+func NewUpstream(t testing.TB) *Upstream {
+	t.Helper()
+
+	u := &Upstream{
+		Server: nil,
+		mu:     sync.Mutex{},
+		current: Current{
+			Temperature: 12.5,
+			Windspeed:   9.4,
+			Weathercode: 61,
+			Time:        "2026-10-18T12:00",
+		},
+		forecast: Forecast{
+			Dates:        []string{"2026-10-18", "2026-10-19"},
+			MinTemps:     []float64{8.1, 5},
+			MaxTemps:     []float64{14.2, 16},
+			WeatherCodes: []int{61, 0},
+		},
+		delay:       0,
+		requests:    map[string]int{},
+		inFlight:    0,
+		maxInFlight: 0,
+	}
+
+	u.Server = httptest.NewTLSServer(http.HandlerFunc(u.serve))
+	t.Cleanup(u.Close)
+
+	return u
+}
+
+// SetTemperature changes the current temperature.
+func (u *Upstream) SetTemperature(temperature float64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.current.Temperature = temperature
+}
+
+// SetForecast changes the daily forecast.
+func (u *Upstream) SetForecast(forecast Forecast) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.forecast = forecast
+}
+
+// SetDelay makes every response wait d.
+func (u *Upstream) SetDelay(d time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.delay = d
+}
+
+// MaxInFlight returns the most requests that were served at once.
+func (u *Upstream) MaxInFlight() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.maxInFlight
+}
+
+// Requests returns how many requests each path got.
+func (u *Upstream) Requests() map[string]int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return maps.Clone(u.requests)
+}
+
+// NewWeatherService returns a weather service backed by u, saving user data in a temporary file.
+func (u *Upstream) NewWeatherService(t testing.TB) (*weather.Service, *storage.Service) {
+	t.Helper()
+
+	logger := zap.NewNop()
+	storageService := storage.NewStorageService(filepath.Join(t.TempDir(), "userdata.json"), logger)
+	weatherService := weather.NewWeatherService(logger, storageService,
+		u.URL+"/current?latitude=%f&longitude=%f",
+		u.URL+"/forecast?latitude=%f&longitude=%f",
+		u.URL+"/geocode?name=%s",
+	)
+	weatherService.Client = u.Client()
+
+	return weatherService, storageService
+}
+
+func (u *Upstream) serve(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	u.requests[r.URL.Path]++
+	u.inFlight++
+	u.maxInFlight = max(u.maxInFlight, u.inFlight)
+	current, forecast, delay := u.current, u.forecast, u.delay
+	u.mu.Unlock()
+
+	defer func() {
+		u.mu.Lock()
+		u.inFlight--
+		u.mu.Unlock()
+	}()
+
+	time.Sleep(delay)
+
+	var body any
+
+	switch r.URL.Path {
+	case "/geocode":
+		results := []map[string]float64{{"latitude": 44.65, "longitude": -63.57}}
+		if r.URL.Query().Get("name") == UnknownCity {
+			results = []map[string]float64{}
+		}
+
+		body = map[string]any{"results": results}
+	case "/current":
+		body = map[string]any{"current_weather": current}
+	case "/forecast":
+		body = map[string]any{"daily": forecast}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,1009 @@
+// Package webhooks pushes alert and weather change events to URLs subscribed by users.
+//
+// Deliveries are signed with the subscription's secret, retried with exponential backoff and
+// logged, so a receiver can check what was sent and ask for a delivery again.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/logger"
+	"github.com/codyonesock/rest_weather/internal/shared"
+	"github.com/codyonesock/rest_weather/internal/storage"
+	"github.com/codyonesock/rest_weather/internal/stream"
+	"github.com/codyonesock/rest_weather/internal/weather"
+)
+
+// Events a webhook can subscribe to.
+const (
+	EventAlertFired     = "alert.fired"
+	EventWeatherChanged = "weather.changed"
+)
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Headers of a delivery. The signature is "sha256=" and the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the webhook's secret, see Sign.
+const (
+	HeaderID        = "X-Webhook-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	// MaxWebhooks is how many webhooks a user may have.
+	MaxWebhooks = 10
+	// maxCities is how many cities a webhook may watch, as many as a stream.
+	maxCities = stream.MaxCities
+	// maxDeliveries is the length of a user's delivery log.
+	maxDeliveries = 100
+	// workers is how many deliveries are sent at once.
+	workers = 4
+	// queueSize is how many deliveries may wait for a worker.
+	queueSize = 1024
+	// minSecretLength is the shortest secret accepted, generated secrets are longer.
+	minSecretLength = 16
+	secretBytes     = 32
+)
+
+// err113 demands no dynamic errors!
+var (
+	ErrInvalidBody        = errors.New("invalid request body")
+	ErrInvalidURL         = errors.New("url must be an absolute http or https URL")
+	ErrUnknownHost        = errors.New("url host can't be resolved")
+	ErrPrivateAddress     = errors.New("url must not point at a loopback, private or link-local address")
+	ErrEventsRequired     = errors.New("events is required")
+	ErrInvalidEvent       = errors.New("invalid event")
+	ErrCitiesRequired     = errors.New("cities is required for weather.changed")
+	ErrTooManyCities      = errors.New("too many cities")
+	ErrSecretTooShort     = errors.New("secret is too short")
+	ErrTooManyWebhooks    = errors.New("too many webhooks")
+	ErrWebhookNotFound    = errors.New("webhook not found")
+	ErrDeliveryNotFound   = errors.New("delivery not found")
+	ErrUnexpectedResponse = errors.New("unexpected response status")
+)
+
+// WebhookRequest is the body to create or replace a webhook.
+// An empty Secret generates one on create and keeps the current one on replace.
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Cities []string `json:"cities"`
+	Secret string   `json:"secret"`
+}
+
+// Payload is the body of a delivery.
+type Payload struct {
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+// job is a delivery waiting for a worker.
+type job struct {
+	userID     string
+	deliveryID string
+}
+
+// Service handles dependencies and config.
+// Handlers and delivery workers load and save the same document, so each change holds mu.
+type Service struct {
+	mu      sync.Mutex
+	Logger  *zap.Logger
+	Weather *weather.Service
+	Stream  *stream.Service
+	Store   storage.WebhookStore
+	Client  *http.Client
+
+	// Deliveries get MaxAttempts, retried after Backoff and then twice as long each time.
+	MaxAttempts int
+	Backoff     time.Duration
+
+	// AllowPrivate lets webhooks reach loopback, private and link-local addresses, e.g. a local
+	// receiver. Otherwise they're refused when a webhook is saved and again on every connection.
+	AllowPrivate bool
+
+	queue    chan job
+	replays  chan job
+	resync   chan struct{}
+	inFlight map[string]bool
+}
+
+// NewWebhooksService creates a new instance of Service whose requests time out after timeout.
+func NewWebhooksService(
+	l *zap.Logger,
+	weatherService *weather.Service,
+	streamService *stream.Service,
+	store storage.WebhookStore,
+	timeout time.Duration,
+	maxAttempts int,
+	backoff time.Duration,
+) *Service {
+	s := &Service{
+		mu:           sync.Mutex{},
+		Logger:       l,
+		Weather:      weatherService,
+		Stream:       streamService,
+		Store:        store,
+		Client:       nil,
+		MaxAttempts:  maxAttempts,
+		Backoff:      backoff,
+		AllowPrivate: false,
+		queue:        make(chan job, queueSize),
+		replays:      make(chan job, queueSize),
+		resync:       make(chan struct{}, 1),
+		inFlight:     map[string]bool{},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // always a *http.Transport
+	// Through a proxy, the proxy's address would be checked instead of the receiver's.
+	transport.Proxy = nil
+	dialer := &net.Dialer{ //nolint:exhaustruct // optional fields
+		Timeout: timeout,
+		Control: s.checkDial,
+	}
+	transport.DialContext = dialer.DialContext
+
+	s.Client = &http.Client{ //nolint:exhaustruct // optional fields
+		Transport: transport,
+		Timeout:   timeout,
+		// A redirect is a failed delivery rather than a request to somewhere else.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	return s
+}
+
+// Sign returns the signature of a delivery of body at timestamp, as sent in HeaderSignature.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ListWebhooks writes the webhooks of the user, without their secrets.
+func (s *Service) ListWebhooks(w http.ResponseWriter, r *http.Request) error {
+	webhooks, err := s.load(r.Context())
+	if err != nil {
+		return err
+	}
+
+	for i := range webhooks.Subscriptions {
+		webhooks.Subscriptions[i].Secret = ""
+	}
+
+	return shared.WriteJSON(w, http.StatusOK, webhooks.Subscriptions)
+}
+
+// GetWebhook writes the webhook with id, without its secret.
+func (s *Service) GetWebhook(w http.ResponseWriter, r *http.Request, id string) error {
+	webhooks, err := s.load(r.Context())
+	if err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(webhooks.Subscriptions, func(hook shared.Webhook) bool { return hook.ID == id })
+	if i == -1 {
+		return fmt.Errorf("%w: %s", ErrWebhookNotFound, id)
+	}
+
+	hook := webhooks.Subscriptions[i]
+	hook.Secret = ""
+
+	return shared.WriteJSON(w, http.StatusOK, hook)
+}
+
+// CreateWebhook adds a webhook from the request body. The response is the only one with the secret.
+func (s *Service) CreateWebhook(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	hook, err := s.decodeWebhook(r)
+	if err != nil {
+		return err
+	}
+
+	hook.ID = shared.NewID()
+	hook.CreatedAt = time.Now().UTC()
+
+	if hook.Secret == "" {
+		hook.Secret = shared.RandomHex(secretBytes)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhooks, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(webhooks.Subscriptions) >= MaxWebhooks {
+		return fmt.Errorf("%w, at most %d", ErrTooManyWebhooks, MaxWebhooks)
+	}
+
+	webhooks.Subscriptions = append(webhooks.Subscriptions, hook)
+	if err := s.save(ctx, webhooks); err != nil {
+		return err
+	}
+
+	s.signalResync()
+	logger.FromContext(ctx, s.Logger).Info("Webhook created", zap.String("id", hook.ID), zap.Strings("events", hook.Events))
+
+	return shared.WriteJSON(w, http.StatusCreated, hook)
+}
+
+// UpdateWebhook replaces the webhook with id. Pending deliveries go to the new URL.
+func (s *Service) UpdateWebhook(w http.ResponseWriter, r *http.Request, id string) error {
+	ctx := r.Context()
+
+	hook, err := s.decodeWebhook(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhooks, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(webhooks.Subscriptions, func(hook shared.Webhook) bool { return hook.ID == id })
+	if i == -1 {
+		return fmt.Errorf("%w: %s", ErrWebhookNotFound, id)
+	}
+
+	current := webhooks.Subscriptions[i]
+	hook.ID, hook.CreatedAt = id, current.CreatedAt
+
+	if hook.Secret == "" {
+		hook.Secret = current.Secret
+	}
+
+	webhooks.Subscriptions[i] = hook
+	if err := s.save(ctx, webhooks); err != nil {
+		return err
+	}
+
+	s.signalResync()
+	logger.FromContext(ctx, s.Logger).Info("Webhook updated", zap.String("id", id))
+
+	hook.Secret = ""
+
+	return shared.WriteJSON(w, http.StatusOK, hook)
+}
+
+// DeleteWebhook removes the webhook with id. Its deliveries stay in the log; pending ones fail.
+func (s *Service) DeleteWebhook(w http.ResponseWriter, r *http.Request, id string) error {
+	ctx := r.Context()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhooks, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(webhooks.Subscriptions, func(hook shared.Webhook) bool { return hook.ID == id })
+	if i == -1 {
+		return fmt.Errorf("%w: %s", ErrWebhookNotFound, id)
+	}
+
+	webhooks.Subscriptions = slices.Delete(webhooks.Subscriptions, i, i+1)
+	if err := s.save(ctx, webhooks); err != nil {
+		return err
+	}
+
+	s.signalResync()
+	logger.FromContext(ctx, s.Logger).Info("Webhook deleted", zap.String("id", id))
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// ListDeliveries writes the delivery log of the user, newest first.
+// ?webhook_id= limits it to one webhook.
+func (s *Service) ListDeliveries(w http.ResponseWriter, r *http.Request) error {
+	webhooks, err := s.load(r.Context())
+	if err != nil {
+		return err
+	}
+
+	deliveries := webhooks.Deliveries
+	if id := r.URL.Query().Get("webhook_id"); id != "" {
+		deliveries = slices.DeleteFunc(deliveries, func(d shared.WebhookDelivery) bool { return d.WebhookID != id })
+	}
+
+	slices.Reverse(deliveries)
+
+	return shared.WriteJSON(w, http.StatusOK, deliveries)
+}
+
+// ReplayDelivery sends the payload of the delivery with id again, as a new delivery.
+func (s *Service) ReplayDelivery(w http.ResponseWriter, r *http.Request, id string) error {
+	ctx := r.Context()
+
+	s.mu.Lock()
+
+	webhooks, err := s.load(ctx)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	i := slices.IndexFunc(webhooks.Deliveries, func(d shared.WebhookDelivery) bool { return d.ID == id })
+	if i == -1 {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrDeliveryNotFound, id)
+	}
+
+	original := webhooks.Deliveries[i]
+	if !slices.ContainsFunc(webhooks.Subscriptions, func(hook shared.Webhook) bool { return hook.ID == original.WebhookID }) {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrWebhookNotFound, original.WebhookID)
+	}
+
+	replay := newDelivery(original.WebhookID, original.Event, original.Payload, time.Now().UTC())
+	webhooks.Deliveries = appendDeliveries(webhooks.Deliveries, replay)
+
+	err = s.save(ctx, webhooks)
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	log := logger.FromContext(ctx, s.Logger)
+	log.Info("Webhook delivery replayed", zap.String("id", id), zap.String("replay", replay.ID))
+
+	// Handed to Run so the replay is queued, and maybe scheduled, for as long as the service runs
+	// rather than the request. If Run can't take it, it's pending and resumed on the next start.
+	select {
+	case s.replays <- job{userID: shared.UserIDFromContext(ctx), deliveryID: replay.ID}:
+	default:
+		log.Warn("Webhook replay not queued, it's sent on the next start", zap.String("replay", replay.ID))
+	}
+
+	return shared.WriteJSON(w, http.StatusAccepted, replay)
+}
+
+// NotifyAlert sends event to the alert.fired webhooks of the user in ctx.
+func (s *Service) NotifyAlert(ctx context.Context, event shared.AlertEvent) {
+	s.dispatch(ctx, EventAlertFired, event, func(shared.Webhook) bool { return true })
+}
+
+// Run sends deliveries and watches the cities of weather.changed webhooks until ctx is done.
+// Deliveries still pending from before, or interrupted by the shutdown, are sent again.
+func (s *Service) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for range workers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		s.queueReplays(ctx)
+	}()
+
+	s.resume(ctx)
+	s.watch(ctx)
+
+	wg.Wait()
+}
+
+// work sends queued deliveries until ctx is done.
+func (s *Service) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-s.queue:
+			s.deliver(shared.WithUserID(ctx, j.userID), j.deliveryID)
+		}
+	}
+}
+
+// queueReplays queues the deliveries replayed through the API until ctx is done.
+func (s *Service) queueReplays(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-s.replays:
+			s.enqueue(ctx, j)
+		}
+	}
+}
+
+// resume queues the pending deliveries of every user, those waiting for a retry at their next attempt.
+func (s *Service) resume(ctx context.Context) {
+	users, err := s.Store.WebhookUsers(ctx)
+	if err != nil {
+		s.Logger.Error("Failed to list webhook users", zap.Error(err))
+		return
+	}
+
+	for _, userID := range users {
+		webhooks, err := s.load(shared.WithUserID(ctx, userID))
+		if err != nil {
+			continue
+		}
+
+		for _, d := range webhooks.Deliveries {
+			if d.Status != StatusPending {
+				continue
+			}
+
+			j := job{userID: userID, deliveryID: d.ID}
+			if d.NextAttemptAt != nil {
+				s.schedule(ctx, j, *d.NextAttemptAt)
+			} else {
+				s.enqueue(ctx, j)
+			}
+		}
+	}
+}
+
+// watch follows the cities of the weather.changed webhooks on the stream until ctx is done,
+// subscribing again whenever the stream drops the subscription for falling behind.
+func (s *Service) watch(ctx context.Context) {
+	for {
+		sub := s.Stream.Subscribe()
+		dropped := s.follow(ctx, sub)
+
+		sub.Close()
+
+		if !dropped {
+			return
+		}
+
+		s.Logger.Warn("Webhook weather subscription fell behind, subscribing again")
+	}
+}
+
+// follow dispatches the changes seen by sub, returning true if the stream dropped it.
+// The first update of a city is its baseline rather than a change.
+func (s *Service) follow(ctx context.Context, sub *stream.Subscription) bool {
+	known := map[string]bool{}
+	s.syncCities(ctx, sub, known)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-s.Stream.Done():
+			return false
+		case <-s.resync:
+			s.syncCities(ctx, sub, known)
+		case update, ok := <-sub.Updates():
+			if !ok {
+				return true
+			}
+
+			if !known[update.City] {
+				known[update.City] = true
+				continue
+			}
+
+			s.weatherChanged(ctx, update)
+		}
+	}
+}
+
+// syncCities makes sub follow the cities of the weather.changed webhooks.
+func (s *Service) syncCities(ctx context.Context, sub *stream.Subscription, known map[string]bool) {
+	users, err := s.Store.WebhookUsers(ctx)
+	if err != nil {
+		s.Logger.Error("Failed to list webhook users", zap.Error(err))
+		return
+	}
+
+	watched := map[string]bool{}
+
+	for _, userID := range users {
+		webhooks, err := s.load(shared.WithUserID(ctx, userID))
+		if err != nil {
+			continue
+		}
+
+		for _, hook := range webhooks.Subscriptions {
+			if slices.Contains(hook.Events, EventWeatherChanged) {
+				for _, city := range hook.Cities {
+					watched[city] = true
+				}
+			}
+		}
+	}
+
+	stale, added := []string{}, []string{}
+
+	for _, city := range sub.Cities() {
+		if !watched[city] {
+			stale = append(stale, city)
+			delete(known, city)
+		}
+
+		delete(watched, city)
+	}
+
+	for city := range watched {
+		added = append(added, city)
+	}
+
+	sub.Unfollow(stale...)
+
+	for _, update := range sub.Follow(ctx, added...) {
+		known[update.City] = true
+	}
+}
+
+// weatherChanged sends update to the weather.changed webhooks watching its city, in their user's units.
+func (s *Service) weatherChanged(ctx context.Context, update stream.Update) {
+	users, err := s.Store.WebhookUsers(ctx)
+	if err != nil {
+		s.Logger.Error("Failed to list webhook users", zap.Error(err))
+		return
+	}
+
+	for _, userID := range users {
+		userCtx := shared.WithUserID(ctx, userID)
+
+		userData, err := s.Weather.UserData(userCtx)
+		if err != nil {
+			s.Logger.Error("Failed to load user units for webhooks", zap.String("user", userID), zap.Error(err))
+			continue
+		}
+
+		report := weather.NewWeatherReport(update.City, userData.Units, update.Data)
+		s.dispatch(userCtx, EventWeatherChanged, report, func(hook shared.Webhook) bool {
+			return slices.Contains(hook.Cities, update.City)
+		})
+	}
+}
+
+// dispatch logs a delivery of event to each webhook of the user in ctx that subscribes to it
+// and matches, and queues them.
+func (s *Service) dispatch(ctx context.Context, event string, data any, match func(shared.Webhook) bool) {
+	log := logger.FromContext(ctx, s.Logger)
+	now := time.Now().UTC()
+
+	payload, err := json.Marshal(Payload{Event: event, OccurredAt: now, Data: data})
+	if err != nil {
+		log.Error("Failed to encode webhook payload", zap.String("event", event), zap.Error(err))
+		return
+	}
+
+	s.mu.Lock()
+
+	webhooks, err := s.load(ctx)
+	if err != nil {
+		s.mu.Unlock()
+		return
+	}
+
+	created := []string{}
+
+	for _, hook := range webhooks.Subscriptions {
+		if slices.Contains(hook.Events, event) && match(hook) {
+			d := newDelivery(hook.ID, event, payload, now)
+			webhooks.Deliveries = appendDeliveries(webhooks.Deliveries, d)
+			created = append(created, d.ID)
+		}
+	}
+
+	if len(created) > 0 {
+		err = s.save(ctx, webhooks)
+	}
+
+	s.mu.Unlock()
+
+	if len(created) == 0 || err != nil {
+		return
+	}
+
+	for _, id := range created {
+		s.enqueue(ctx, job{userID: shared.UserIDFromContext(ctx), deliveryID: id})
+	}
+}
+
+// enqueue hands j to the workers without waiting for one. While the queue is full, j is tried
+// again every Backoff.
+func (s *Service) enqueue(ctx context.Context, j job) {
+	select {
+	case s.queue <- j:
+	default:
+		logger.FromContext(ctx, s.Logger).Warn("Webhook queue is full, delaying delivery",
+			zap.String("delivery", j.deliveryID))
+		s.schedule(ctx, j, time.Now().Add(s.Backoff))
+	}
+}
+
+// schedule queues j at the given time, unless ctx is done by then. The delivery stays pending
+// meanwhile, so if the server stops first it's resumed on the next start.
+func (s *Service) schedule(ctx context.Context, j job, at time.Time) {
+	time.AfterFunc(time.Until(at), func() {
+		if ctx.Err() == nil {
+			s.enqueue(ctx, j)
+		}
+	})
+}
+
+// deliver makes an attempt at the delivery with id. A failed attempt that may succeed later is
+// scheduled after the backoff rather than waited for, so the worker moves on.
+func (s *Service) deliver(ctx context.Context, id string) {
+	if !s.claim(id) {
+		return
+	}
+
+	next, retry := s.attempt(ctx, id)
+
+	// Released first, or a short backoff could queue the retry while it's still claimed.
+	s.release(id)
+
+	if retry {
+		s.schedule(ctx, job{userID: shared.UserIDFromContext(ctx), deliveryID: id}, next)
+	}
+}
+
+// attempt sends the delivery with id once and records the attempt in the log. It returns when
+// to attempt it again, and whether to.
+func (s *Service) attempt(ctx context.Context, id string) (time.Time, bool) {
+	log := logger.FromContext(ctx, s.Logger).With(zap.String("delivery", id))
+
+	hook, delivery, err := s.lookup(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrWebhookNotFound) {
+			s.record(ctx, id, func(d *shared.WebhookDelivery) {
+				d.Status, d.Error, d.NextAttemptAt = StatusFailed, err.Error(), nil
+			})
+		}
+
+		return time.Time{}, false
+	}
+
+	// A stale job, the delivery was attempted since it was queued and waits for its retry.
+	if delivery.Status != StatusPending || delivery.NextAttemptAt != nil && time.Now().Before(*delivery.NextAttemptAt) {
+		return time.Time{}, false
+	}
+
+	code, err := s.send(ctx, hook, delivery)
+	if ctx.Err() != nil {
+		// Shutting down; the delivery is attempted again on the next start.
+		return time.Time{}, false
+	}
+
+	retry := err != nil && retryable(code, err) && delivery.Attempts+1 < s.MaxAttempts
+	next := time.Now().UTC().Add(shared.Backoff(s.Backoff, delivery.Attempts+1))
+
+	s.record(ctx, id, func(d *shared.WebhookDelivery) {
+		d.Attempts++
+		d.StatusCode, d.Error, d.NextAttemptAt = code, "", nil
+
+		switch {
+		case err == nil:
+			d.Status = StatusDelivered
+		case retry:
+			d.Error, d.NextAttemptAt = err.Error(), &next
+		default:
+			d.Status, d.Error = StatusFailed, err.Error()
+		}
+	})
+
+	switch {
+	case err == nil:
+		log.Debug("Webhook delivered", zap.String("webhook", hook.ID), zap.Int("attempts", delivery.Attempts+1))
+	case !retry:
+		log.Warn("Webhook delivery failed", zap.String("webhook", hook.ID), zap.Error(err))
+	}
+
+	return next, retry
+}
+
+// send POSTs the payload of d to hook, returning the response status.
+func (s *Service) send(ctx context.Context, hook shared.Webhook, d shared.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rest_weather-webhooks")
+	req.Header.Set(HeaderID, d.ID)
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, d.Payload))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send: %w", err)
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("%w: %d", ErrUnexpectedResponse, resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// retryable reports whether a failed attempt may succeed later: the request didn't get an
+// answer, the receiver failed, or it asked to slow down. A refused address stays refused.
+func retryable(code int, err error) bool {
+	if errors.Is(err, ErrPrivateAddress) {
+		return false
+	}
+
+	return !errors.Is(err, ErrUnexpectedResponse) ||
+		code >= http.StatusInternalServerError ||
+		code == http.StatusRequestTimeout ||
+		code == http.StatusTooManyRequests
+}
+
+// claim marks the delivery with id as being sent, returning false if it already is.
+// A pending delivery can be queued twice when it's resumed while being dispatched.
+func (s *Service) claim(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inFlight[id] {
+		return false
+	}
+
+	s.inFlight[id] = true
+
+	return true
+}
+
+func (s *Service) release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inFlight, id)
+}
+
+// lookup returns the delivery with id and its webhook.
+func (s *Service) lookup(ctx context.Context, id string) (shared.Webhook, shared.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhooks, err := s.load(ctx)
+	if err != nil {
+		return shared.Webhook{}, shared.WebhookDelivery{}, err
+	}
+
+	i := slices.IndexFunc(webhooks.Deliveries, func(d shared.WebhookDelivery) bool { return d.ID == id })
+	if i == -1 {
+		return shared.Webhook{}, shared.WebhookDelivery{}, fmt.Errorf("%w: %s", ErrDeliveryNotFound, id)
+	}
+
+	delivery := webhooks.Deliveries[i]
+
+	j := slices.IndexFunc(webhooks.Subscriptions, func(hook shared.Webhook) bool { return hook.ID == delivery.WebhookID })
+	if j == -1 {
+		return shared.Webhook{}, delivery, fmt.Errorf("%w: %s", ErrWebhookNotFound, delivery.WebhookID)
+	}
+
+	return webhooks.Subscriptions[j], delivery, nil
+}
+
+// record applies fn to the delivery with id, if it's still in the log.
+func (s *Service) record(ctx context.Context, id string, fn func(d *shared.WebhookDelivery)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhooks, err := s.load(ctx)
+	if err != nil {
+		return
+	}
+
+	i := slices.IndexFunc(webhooks.Deliveries, func(d shared.WebhookDelivery) bool { return d.ID == id })
+	if i == -1 {
+		return
+	}
+
+	fn(&webhooks.Deliveries[i])
+	webhooks.Deliveries[i].UpdatedAt = time.Now().UTC()
+
+	_ = s.save(ctx, webhooks)
+}
+
+// signalResync asks the watcher to follow the current cities.
+func (s *Service) signalResync() {
+	select {
+	case s.resync <- struct{}{}:
+	default:
+	}
+}
+
+// decodeWebhook reads and validates a webhook from the request body.
+func (s *Service) decodeWebhook(r *http.Request) (shared.Webhook, error) {
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return shared.Webhook{}, fmt.Errorf("%w: %w", ErrInvalidBody, err)
+	}
+
+	hook := shared.Webhook{
+		ID:        "",
+		URL:       strings.TrimSpace(req.URL),
+		Events:    []string{},
+		Cities:    nil,
+		Secret:    req.Secret,
+		CreatedAt: time.Time{},
+	}
+
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return hook, ErrInvalidURL
+	}
+
+	for _, event := range req.Events {
+		if event != EventAlertFired && event != EventWeatherChanged {
+			return hook, fmt.Errorf("%w: %q", ErrInvalidEvent, event)
+		}
+
+		if !slices.Contains(hook.Events, event) {
+			hook.Events = append(hook.Events, event)
+		}
+	}
+
+	if len(hook.Events) == 0 {
+		return hook, ErrEventsRequired
+	}
+
+	// Cities are matched against updates, so they're named as the stream names them.
+	for _, city := range req.Cities {
+		if city = stream.CityKey(city); city != "" && !slices.Contains(hook.Cities, city) {
+			hook.Cities = append(hook.Cities, city)
+		}
+	}
+
+	if slices.Contains(hook.Events, EventWeatherChanged) && len(hook.Cities) == 0 {
+		return hook, ErrCitiesRequired
+	}
+
+	if len(hook.Cities) > maxCities {
+		return hook, fmt.Errorf("%w, at most %d", ErrTooManyCities, maxCities)
+	}
+
+	if hook.Secret != "" && len(hook.Secret) < minSecretLength {
+		return hook, fmt.Errorf("%w, at least %d characters", ErrSecretTooShort, minSecretLength)
+	}
+
+	if err := s.checkHost(r.Context(), u.Hostname()); err != nil {
+		return hook, err
+	}
+
+	return hook, nil
+}
+
+// checkHost refuses a host resolving to an address webhooks may not reach.
+func (s *Service) checkHost(ctx context.Context, host string) error {
+	if s.AllowPrivate {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnknownHost, err)
+	}
+
+	for _, addr := range addrs {
+		if private(addr.IP) {
+			return fmt.Errorf("%w: %s", ErrPrivateAddress, addr.IP)
+		}
+	}
+
+	return nil
+}
+
+// checkDial refuses connections to addresses webhooks may not reach. The host was checked when
+// the webhook was saved, but may resolve somewhere else by the time a delivery is sent.
+func (s *Service) checkDial(_, address string, _ syscall.RawConn) error {
+	if s.AllowPrivate {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrPrivateAddress, err)
+	}
+
+	if ip := net.ParseIP(host); ip == nil || private(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+
+	return nil
+}
+
+// private reports whether ip is loopback, private, link-local, multicast or unspecified.
+func private(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+func (s *Service) load(ctx context.Context) (shared.Webhooks, error) {
+	webhooks, err := s.Store.LoadWebhooks(ctx)
+	if err != nil {
+		logger.FromContext(ctx, s.Logger).Error("Error loading webhooks", zap.Error(err))
+		return shared.Webhooks{}, fmt.Errorf("failed to load webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+func (s *Service) save(ctx context.Context, webhooks shared.Webhooks) error {
+	if err := s.Store.SaveWebhooks(ctx, webhooks); err != nil {
+		logger.FromContext(ctx, s.Logger).Error("Error saving webhooks", zap.Error(err))
+		return fmt.Errorf("failed to save webhooks: %w", err)
+	}
+
+	return nil
+}
+
+// newDelivery returns a pending delivery of payload to the webhook with id.
+func newDelivery(webhookID, event string, payload json.RawMessage, now time.Time) shared.WebhookDelivery {
+	return shared.WebhookDelivery{
+		ID:            shared.NewID(),
+		WebhookID:     webhookID,
+		Event:         event,
+		Payload:       payload,
+		Status:        StatusPending,
+		Attempts:      0,
+		StatusCode:    0,
+		Error:         "",
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		NextAttemptAt: nil,
+	}
+}
+
+// appendDeliveries appends d to the log, dropping the oldest beyond maxDeliveries.
+func appendDeliveries(deliveries []shared.WebhookDelivery, d shared.WebhookDelivery) []shared.WebhookDelivery {
+	deliveries = append(deliveries, d)
+	if len(deliveries) > maxDeliveries {
+		deliveries = deliveries[len(deliveries)-maxDeliveries:]
+	}
+
+	return deliveries
+}
@@ -0,0 +1,416 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/shared"
+	"github.com/codyonesock/rest_weather/internal/stream"
+	"github.com/codyonesock/rest_weather/internal/weather"
+	"github.com/codyonesock/rest_weather/internal/weathertest"
+	"github.com/codyonesock/rest_weather/internal/webhooks"
+)
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+// receiver records the deliveries it gets and answers with the next of its statuses,
+// the last one repeating.
+type receiver struct {
+	mu         sync.Mutex
+	statuses   []int
+	deliveries []*http.Request
+	bodies     [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.deliveries = append(rc.deliveries, r)
+	rc.bodies = append(rc.bodies, body)
+
+	status := rc.statuses[0]
+	if len(rc.statuses) > 1 {
+		rc.statuses = rc.statuses[1:]
+	}
+
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) received() ([]*http.Request, [][]byte) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return append([]*http.Request{}, rc.deliveries...), append([][]byte{}, rc.bodies...)
+}
+
+func (rc *receiver) respond(statuses ...int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.statuses = statuses
+}
+
+// setupWebhooks runs a webhooks service backed by a fast refreshing stream until the test ends.
+// It may deliver to the receiver, which listens on loopback. configure, if not nil, changes the
+// service before it starts.
+func setupWebhooks(
+	t *testing.T, configure func(*webhooks.Service),
+) (*webhooks.Service, *receiver, string, *weathertest.Upstream) {
+	t.Helper()
+
+	upstream := weathertest.NewUpstream(t)
+	weatherService, storageService := upstream.NewWeatherService(t)
+
+	rc := &receiver{mu: sync.Mutex{}, statuses: []int{http.StatusOK}, deliveries: nil, bodies: nil}
+
+	receiverServer := httptest.NewServer(rc)
+	t.Cleanup(receiverServer.Close)
+
+	logger := zap.NewNop()
+	streamService := stream.NewStreamService(logger, weatherService, 10*time.Millisecond, time.Hour)
+	service := webhooks.NewWebhooksService(logger, weatherService, streamService, storageService,
+		time.Second, 3, time.Millisecond)
+	service.AllowPrivate = true
+
+	if configure != nil {
+		configure(service)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		streamService.Run(ctx)
+	}()
+
+	go func() {
+		defer wg.Done()
+		service.Run(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+
+	return service, rc, receiverServer.URL, upstream
+}
+
+func createWebhook(t *testing.T, service *webhooks.Service, body string) shared.Webhook {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	if err := service.CreateWebhook(rec, httptest.NewRequest(http.MethodPost, "/user/webhooks", strings.NewReader(body))); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var hook shared.Webhook
+	if err := json.NewDecoder(rec.Body).Decode(&hook); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	return hook
+}
+
+func listDeliveries(t *testing.T, service *webhooks.Service) []shared.WebhookDelivery {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	if err := service.ListDeliveries(rec, httptest.NewRequest(http.MethodGet, "/user/webhooks/deliveries", nil)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var deliveries []shared.WebhookDelivery
+	if err := json.NewDecoder(rec.Body).Decode(&deliveries); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	return deliveries
+}
+
+// waitForDelivery waits until the newest delivery is no longer pending and returns it.
+func waitForDelivery(t *testing.T, service *webhooks.Service, count int) shared.WebhookDelivery {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		deliveries := listDeliveries(t, service)
+		if len(deliveries) >= count && deliveries[0].Status != webhooks.StatusPending {
+			return deliveries[0]
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d finished deliveries, got %+v", count, deliveries)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAlertDeliveryIsSignedAndRetried(t *testing.T) {
+	t.Parallel()
+
+	service, rc, receiverURL, _ := setupWebhooks(t, nil)
+	rc.respond(http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusNoContent)
+
+	hook := createWebhook(t, service, fmt.Sprintf(`{"url": %q, "events": ["alert.fired"], "secret": %q}`, receiverURL, secret))
+	if hook.Secret != secret {
+		t.Errorf("expected the secret in the create response, got %q", hook.Secret)
+	}
+
+	service.NotifyAlert(context.Background(), shared.AlertEvent{ //nolint:exhaustruct // optional fields
+		ID: "event-1", RuleID: "rule-1", City: "halifax", Metric: "temperature", Operator: "above", Threshold: 20, Value: 25,
+	})
+
+	delivery := waitForDelivery(t, service, 1)
+	if delivery.Status != webhooks.StatusDelivered || delivery.Attempts != 3 || delivery.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected delivery on the third attempt, got %+v", delivery)
+	}
+
+	requests, bodies := rc.received()
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(requests))
+	}
+
+	for i, r := range requests {
+		timestamp, err := strconv.ParseInt(r.Header.Get(webhooks.HeaderTimestamp), 10, 64)
+		if err != nil {
+			t.Fatalf("expected a timestamp, got %v", err)
+		}
+
+		if got, want := r.Header.Get(webhooks.HeaderSignature), webhooks.Sign(secret, timestamp, bodies[i]); got != want {
+			t.Errorf("expected signature %s, got %s", want, got)
+		}
+
+		if r.Header.Get(webhooks.HeaderID) != delivery.ID || r.Header.Get(webhooks.HeaderEvent) != webhooks.EventAlertFired {
+			t.Errorf("expected the delivery's headers, got %v", r.Header)
+		}
+	}
+
+	var payload struct {
+		Event string            `json:"event"`
+		Data  shared.AlertEvent `json:"data"`
+	}
+	if err := json.Unmarshal(bodies[0], &payload); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if payload.Event != webhooks.EventAlertFired || payload.Data.RuleID != "rule-1" || payload.Data.Value != 25 {
+		t.Errorf("expected the alert event as payload, got %+v", payload)
+	}
+}
+
+func TestRetriesDontHoldWorkers(t *testing.T) {
+	t.Parallel()
+
+	service, rc, receiverURL, _ := setupWebhooks(t, func(service *webhooks.Service) {
+		service.Backoff = time.Hour
+	})
+	rc.respond(http.StatusServiceUnavailable)
+
+	createWebhook(t, service, fmt.Sprintf(`{"url": %q, "events": ["alert.fired"]}`, receiverURL))
+
+	// More failing deliveries than there are workers.
+	for i := range 10 {
+		service.NotifyAlert(context.Background(), shared.AlertEvent{ID: strconv.Itoa(i)}) //nolint:exhaustruct // optional fields
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !allAttempted(listDeliveries(t, service), 10) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected every delivery to be attempted while others wait to retry, got %+v", listDeliveries(t, service))
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for _, d := range listDeliveries(t, service) {
+		if d.Status != webhooks.StatusPending || d.Attempts != 1 || time.Until(*d.NextAttemptAt) < 59*time.Minute {
+			t.Errorf("expected a pending delivery retried in an hour, got %+v", d)
+		}
+	}
+}
+
+// allAttempted reports whether there are n deliveries, each attempted and waiting for its retry.
+func allAttempted(deliveries []shared.WebhookDelivery, n int) bool {
+	if len(deliveries) != n {
+		return false
+	}
+
+	for _, d := range deliveries {
+		if d.NextAttemptAt == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestFailedDeliveryCanBeReplayed(t *testing.T) {
+	t.Parallel()
+
+	service, rc, receiverURL, _ := setupWebhooks(t, nil)
+	rc.respond(http.StatusBadRequest, http.StatusOK)
+
+	createWebhook(t, service, fmt.Sprintf(`{"url": %q, "events": ["alert.fired"]}`, receiverURL))
+	service.NotifyAlert(context.Background(), shared.AlertEvent{ID: "event-1"}) //nolint:exhaustruct // optional fields
+
+	// Client errors other than 408 and 429 aren't retried.
+	failed := waitForDelivery(t, service, 1)
+	if failed.Status != webhooks.StatusFailed || failed.Attempts != 1 || failed.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a failed delivery after one attempt, got %+v", failed)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/user/webhooks/deliveries/"+failed.ID+"/replay", nil)
+
+	if err := service.ReplayDelivery(rec, req, failed.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("expected status 202, got %d", rec.Code)
+	}
+
+	replay := waitForDelivery(t, service, 2)
+	if replay.ID == failed.ID || replay.Status != webhooks.StatusDelivered || string(replay.Payload) != string(failed.Payload) {
+		t.Errorf("expected a new successful delivery of the same payload, got %+v", replay)
+	}
+
+	err := service.ReplayDelivery(httptest.NewRecorder(), req, "missing")
+	if !errors.Is(err, webhooks.ErrDeliveryNotFound) {
+		t.Errorf("expected %v, got %v", webhooks.ErrDeliveryNotFound, err)
+	}
+}
+
+func TestWeatherChangesAreDelivered(t *testing.T) {
+	t.Parallel()
+
+	service, rc, receiverURL, upstream := setupWebhooks(t, nil)
+
+	createWebhook(t, service, fmt.Sprintf(`{"url": %q, "events": ["weather.changed"], "cities": ["Halifax"]}`, receiverURL))
+
+	// Let the stream take the first reading as the baseline before the conditions change.
+	time.Sleep(50 * time.Millisecond)
+
+	if requests, _ := rc.received(); len(requests) != 0 {
+		t.Fatalf("expected no delivery for the baseline, got %d", len(requests))
+	}
+
+	upstream.SetTemperature(15)
+
+	delivery := waitForDelivery(t, service, 1)
+
+	var payload struct {
+		Event string                `json:"event"`
+		Data  weather.WeatherReport `json:"data"`
+	}
+	if err := json.Unmarshal(delivery.Payload, &payload); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if payload.Event != webhooks.EventWeatherChanged || payload.Data.City != "halifax" || payload.Data.Temperature != 15 {
+		t.Errorf("expected the new conditions of halifax, got %+v", payload)
+	}
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	t.Parallel()
+
+	service, _, _, _ := setupWebhooks(t, nil)
+
+	for _, tc := range []struct {
+		body string
+		want error
+	}{
+		{`{"url": `, webhooks.ErrInvalidBody},
+		{`{"url": "ftp://example.com", "events": ["alert.fired"]}`, webhooks.ErrInvalidURL},
+		{`{"url": "/hook", "events": ["alert.fired"]}`, webhooks.ErrInvalidURL},
+		{`{"url": "https://example.com/hook"}`, webhooks.ErrEventsRequired},
+		{`{"url": "https://example.com/hook", "events": ["city.added"]}`, webhooks.ErrInvalidEvent},
+		{`{"url": "https://example.com/hook", "events": ["weather.changed"]}`, webhooks.ErrCitiesRequired},
+		{`{"url": "https://example.com/hook", "events": ["alert.fired"], "secret": "short"}`, webhooks.ErrSecretTooShort},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/user/webhooks", strings.NewReader(tc.body))
+		if err := service.CreateWebhook(httptest.NewRecorder(), req); !errors.Is(err, tc.want) {
+			t.Errorf("expected %v for %s, got %v", tc.want, tc.body, err)
+		}
+	}
+}
+
+func refusePrivate(service *webhooks.Service) {
+	service.AllowPrivate = false
+}
+
+func TestCreateWebhookRefusesPrivateAddresses(t *testing.T) {
+	t.Parallel()
+
+	service, _, _, _ := setupWebhooks(t, refusePrivate)
+
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://[::1]/hook",
+		"http://10.0.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/hook",
+	} {
+		body := fmt.Sprintf(`{"url": %q, "events": ["alert.fired"]}`, url)
+
+		req := httptest.NewRequest(http.MethodPost, "/user/webhooks", strings.NewReader(body))
+		if err := service.CreateWebhook(httptest.NewRecorder(), req); !errors.Is(err, webhooks.ErrPrivateAddress) {
+			t.Errorf("expected %v for %s, got %v", webhooks.ErrPrivateAddress, url, err)
+		}
+	}
+}
+
+func TestDeliveryRefusesPrivateAddresses(t *testing.T) {
+	t.Parallel()
+
+	service, rc, receiverURL, _ := setupWebhooks(t, refusePrivate)
+	ctx := context.Background()
+
+	// As if the host resolved to a public address when the webhook was saved and to loopback now.
+	err := service.Store.SaveWebhooks(ctx, shared.Webhooks{
+		Subscriptions: []shared.Webhook{{
+			ID: "hook-1", URL: receiverURL, Events: []string{webhooks.EventAlertFired}, Cities: nil, Secret: secret, CreatedAt: time.Now(),
+		}},
+		Deliveries: nil,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	service.NotifyAlert(ctx, shared.AlertEvent{ID: "event-1"}) //nolint:exhaustruct // optional fields
+
+	delivery := waitForDelivery(t, service, 1)
+	if delivery.Status != webhooks.StatusFailed || delivery.Attempts != 1 ||
+		!strings.Contains(delivery.Error, webhooks.ErrPrivateAddress.Error()) {
+		t.Errorf("expected a refused delivery that isn't retried, got %+v", delivery)
+	}
+
+	if requests, _ := rc.received(); len(requests) != 0 {
+		t.Errorf("expected the receiver not to be reached, got %d requests", len(requests))
+	}
+}
@@ -0,0 +1,304 @@
+// Package ws serves live weather over WebSocket with a small JSON protocol.
+//
+// Clients send subscribe, unsubscribe and set_units messages; the server answers with
+// an update per changed city and an error for every message it can't apply.
+package ws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/logger"
+	"github.com/codyonesock/rest_weather/internal/stream"
+	"github.com/codyonesock/rest_weather/internal/weather"
+)
+
+// Message types of the protocol.
+const (
+	TypeSubscribe   = "subscribe"
+	TypeUnsubscribe = "unsubscribe"
+	TypeSetUnits    = "set_units"
+	TypeUpdate      = "update"
+	TypeError       = "error"
+)
+
+const (
+	// readLimit is the largest message a client may send.
+	readLimit = 4096
+	// outgoingSize is how many replies may queue before the connection stops reading.
+	outgoingSize = 16
+	writeTimeout = 10 * time.Second
+)
+
+// err113 demands no dynamic errors!
+var (
+	ErrUnknownType          = errors.New("unknown message type")
+	ErrCitiesRequired       = errors.New("cities is required")
+	ErrTooManySubscriptions = errors.New("too many subscriptions")
+	ErrInvalidUnits         = errors.New("units must be metric or imperial")
+)
+
+// Message is a message of the protocol in either direction.
+type Message struct {
+	Type    string                 `json:"type"`
+	Cities  []string               `json:"cities,omitempty"`
+	Units   string                 `json:"units,omitempty"`
+	City    string                 `json:"city,omitempty"`
+	Weather *weather.WeatherReport `json:"weather,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+// Service handles dependencies and config.
+type Service struct {
+	Logger           *zap.Logger
+	Weather          *weather.Service
+	Stream           *stream.Service
+	MaxSubscriptions int
+	PingInterval     time.Duration
+
+	// OriginPatterns are the hosts allowed to connect from other origins, e.g. "app.example.com".
+	OriginPatterns []string
+}
+
+// client is the state of a single connection.
+type client struct {
+	mu    sync.Mutex
+	units string
+	sub   *stream.Subscription
+	out   chan Message
+}
+
+// NewWSService creates a new instance of Service.
+// Connections follow at most maxSubscriptions cities and are pinged every pingInterval.
+func NewWSService(
+	l *zap.Logger,
+	weatherService *weather.Service,
+	streamService *stream.Service,
+	maxSubscriptions int,
+	pingInterval time.Duration,
+) *Service {
+	return &Service{
+		Logger:           l,
+		Weather:          weatherService,
+		Stream:           streamService,
+		MaxSubscriptions: maxSubscriptions,
+		PingInterval:     pingInterval,
+		OriginPatterns:   nil,
+	}
+}
+
+// ServeWS upgrades the request and serves the protocol until either side closes.
+// A failed upgrade has already been answered when the error is returned.
+func (s *Service) ServeWS(w http.ResponseWriter, r *http.Request) error {
+	// Connections outlive the server's read and write timeouts.
+	rc := http.NewResponseController(w)
+	for _, setDeadline := range []func(time.Time) error{rc.SetReadDeadline, rc.SetWriteDeadline} {
+		if err := setDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return fmt.Errorf("failed to clear deadline: %w", err)
+		}
+	}
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{ //nolint:exhaustruct // optional fields
+		OriginPatterns: s.OriginPatterns,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to accept websocket: %w", err)
+	}
+	defer conn.CloseNow() //nolint:errcheck // already closed on the normal path
+
+	conn.SetReadLimit(readLimit)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	userData, err := s.Weather.UserData(ctx)
+	if err != nil {
+		_ = conn.Close(websocket.StatusInternalError, "failed to load user data")
+		return fmt.Errorf("failed to load user data: %w", err)
+	}
+
+	c := &client{mu: sync.Mutex{}, units: userData.Units, sub: s.Stream.Subscribe(), out: make(chan Message, outgoingSize)}
+	defer c.sub.Close()
+
+	go func() {
+		defer cancel()
+		s.readLoop(ctx, conn, c)
+	}()
+
+	return s.writeLoop(ctx, conn, c)
+}
+
+// readLoop applies the client's messages until the connection fails.
+// It blocks while the replies queue is full, so a client that doesn't read can't make it grow.
+func (s *Service) readLoop(ctx context.Context, conn *websocket.Conn, c *client) {
+	for {
+		var msg Message
+		if err := wsjson.Read(ctx, conn, &msg); err != nil {
+			return
+		}
+
+		replies, err := s.handle(ctx, c, msg)
+		if err != nil {
+			replies = []Message{errorMessage(err)}
+		}
+
+		for _, reply := range replies {
+			select {
+			case c.out <- reply:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// writeLoop writes replies, updates of the followed cities and pings until ctx is done.
+// A connection that falls too far behind on updates is closed.
+func (s *Service) writeLoop(ctx context.Context, conn *websocket.Conn, c *client) error {
+	log := logger.FromContext(ctx, s.Logger)
+
+	ping := time.NewTicker(s.PingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.Stream.Done():
+			_ = conn.Close(websocket.StatusGoingAway, "server shutting down")
+
+			return nil
+		case update, ok := <-c.sub.Updates():
+			if !ok {
+				log.Warn("Dropping slow websocket", zap.Strings("cities", c.sub.Cities()))
+				_ = conn.Close(websocket.StatusTryAgainLater, "falling behind on updates")
+
+				return nil
+			}
+
+			err = write(ctx, conn, c.update(update))
+		case reply := <-c.out:
+			err = write(ctx, conn, reply)
+		case <-ping.C:
+			pingCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+			err = conn.Ping(pingCtx)
+
+			cancel()
+		}
+
+		if err != nil {
+			log.Debug("Websocket closed", zap.Error(err))
+			return nil
+		}
+	}
+}
+
+// handle applies msg and returns the replies to send.
+func (s *Service) handle(ctx context.Context, c *client, msg Message) ([]Message, error) {
+	switch msg.Type {
+	case TypeSubscribe:
+		cities := cleanCities(msg.Cities)
+		if len(cities) == 0 {
+			return nil, ErrCitiesRequired
+		}
+
+		following := map[string]bool{}
+		for _, city := range append(c.sub.Cities(), cities...) {
+			following[city] = true
+		}
+
+		if len(following) > s.MaxSubscriptions {
+			return nil, fmt.Errorf("%w, at most %d cities", ErrTooManySubscriptions, s.MaxSubscriptions)
+		}
+
+		return c.updates(c.sub.Follow(ctx, cities...)), nil
+	case TypeUnsubscribe:
+		cities := cleanCities(msg.Cities)
+		if len(cities) == 0 {
+			return nil, ErrCitiesRequired
+		}
+
+		c.sub.Unfollow(cities...)
+
+		return nil, nil
+	case TypeSetUnits:
+		if msg.Units != weather.UnitsMetric && msg.Units != weather.UnitsImperial {
+			return nil, ErrInvalidUnits
+		}
+
+		c.mu.Lock()
+		c.units = msg.Units
+		c.mu.Unlock()
+
+		// Resend what the client follows in the new units.
+		return c.updates(c.sub.Latest()), nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownType, msg.Type)
+	}
+}
+
+// update renders an update in the client's units.
+func (c *client) update(update stream.Update) Message {
+	c.mu.Lock()
+	units := c.units
+	c.mu.Unlock()
+
+	return Message{
+		Type:    TypeUpdate,
+		Cities:  nil,
+		Units:   "",
+		City:    update.City,
+		Weather: weather.NewWeatherReport(update.City, units, update.Data),
+		Error:   "",
+	}
+}
+
+// updates renders every update in the client's units.
+func (c *client) updates(updates []stream.Update) []Message {
+	messages := make([]Message, 0, len(updates))
+	for _, update := range updates {
+		messages = append(messages, c.update(update))
+	}
+
+	return messages
+}
+
+// errorMessage reports err to the client.
+func errorMessage(err error) Message {
+	return Message{Type: TypeError, Cities: nil, Units: "", City: "", Weather: nil, Error: err.Error()}
+}
+
+// cleanCities names cities as the stream does and drops blanks.
+func cleanCities(cities []string) []string {
+	cleaned := []string{}
+
+	for _, city := range cities {
+		if city = stream.CityKey(city); city != "" {
+			cleaned = append(cleaned, city)
+		}
+	}
+
+	return cleaned
+}
+
+// write sends msg, giving up after writeTimeout.
+func write(ctx context.Context, conn *websocket.Conn, msg Message) error {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	if err := wsjson.Write(ctx, conn, msg); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	return nil
+}
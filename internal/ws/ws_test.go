@@ -0,0 +1,137 @@
+package ws_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"go.uber.org/zap"
+
+	"github.com/codyonesock/rest_weather/internal/stream"
+	"github.com/codyonesock/rest_weather/internal/weather"
+	"github.com/codyonesock/rest_weather/internal/weathertest"
+	"github.com/codyonesock/rest_weather/internal/ws"
+)
+
+// setupConn serves a ws service backed by a fake upstream and returns a connection to it.
+func setupConn(t *testing.T, maxSubscriptions int) (*websocket.Conn, *weathertest.Upstream) {
+	t.Helper()
+
+	upstream := weathertest.NewUpstream(t)
+	weatherService, _ := upstream.NewWeatherService(t)
+	logger := zap.NewNop()
+
+	streamService := stream.NewStreamService(logger, weatherService, 10*time.Millisecond, time.Hour)
+	wsService := ws.NewWSService(logger, weatherService, streamService, maxSubscriptions, time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	go streamService.Run(ctx)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := wsService.ServeWS(w, r); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close(websocket.StatusNormalClosure, "") })
+
+	return conn, upstream
+}
+
+// message builds a client message.
+func message(typ string, cities []string, units string) ws.Message {
+	return ws.Message{Type: typ, Cities: cities, Units: units, City: "", Weather: nil, Error: ""}
+}
+
+func send(t *testing.T, conn *websocket.Conn, msg ws.Message) {
+	t.Helper()
+
+	if err := wsjson.Write(context.Background(), conn, msg); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func receive(t *testing.T, conn *websocket.Conn) ws.Message {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var msg ws.Message
+	if err := wsjson.Read(ctx, conn, &msg); err != nil {
+		t.Fatalf("expected a message, got %v", err)
+	}
+
+	return msg
+}
+
+func TestSubscribeAndSetUnits(t *testing.T) {
+	t.Parallel()
+
+	conn, upstream := setupConn(t, 10)
+
+	send(t, conn, message(ws.TypeSubscribe, []string{"halifax"}, ""))
+
+	msg := receive(t, conn)
+	if msg.Type != ws.TypeUpdate || msg.City != "halifax" || msg.Weather.Temperature != 12.5 {
+		t.Fatalf("expected an update with 12.5 °C in halifax, got %+v", msg)
+	}
+
+	send(t, conn, message(ws.TypeSetUnits, nil, weather.UnitsImperial))
+
+	if msg := receive(t, conn); msg.Weather == nil || msg.Weather.Temperature != 54.5 || msg.Weather.TemperatureUnit != "°F" {
+		t.Errorf("expected the update resent in °F, got %+v", msg)
+	}
+
+	upstream.SetTemperature(14)
+
+	if msg := receive(t, conn); msg.Weather == nil || msg.Weather.Temperature != 57.2 {
+		t.Errorf("expected the change in °F, got %+v", msg)
+	}
+}
+
+func TestSubscriptionLimit(t *testing.T) {
+	t.Parallel()
+
+	conn, _ := setupConn(t, 2)
+
+	send(t, conn, message(ws.TypeSubscribe, []string{"halifax", "berlin", "tokyo"}, ""))
+
+	if msg := receive(t, conn); msg.Type != ws.TypeError || !strings.Contains(msg.Error, ws.ErrTooManySubscriptions.Error()) {
+		t.Errorf("expected a too many subscriptions error, got %+v", msg)
+	}
+}
+
+func TestInvalidMessages(t *testing.T) {
+	t.Parallel()
+
+	conn, _ := setupConn(t, 10)
+
+	for _, tc := range []struct {
+		msg  ws.Message
+		want error
+	}{
+		{message("forecast", nil, ""), ws.ErrUnknownType},
+		{message(ws.TypeSubscribe, []string{" "}, ""), ws.ErrCitiesRequired},
+		{message(ws.TypeUnsubscribe, nil, ""), ws.ErrCitiesRequired},
+		{message(ws.TypeSetUnits, nil, "kelvin"), ws.ErrInvalidUnits},
+	} {
+		send(t, conn, tc.msg)
+
+		if msg := receive(t, conn); msg.Type != ws.TypeError || !strings.Contains(msg.Error, tc.want.Error()) {
+			t.Errorf("expected error %q for %+v, got %+v", tc.want, tc.msg, msg)
+		}
+	}
+}